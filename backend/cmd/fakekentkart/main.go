@@ -0,0 +1,72 @@
+// Command fakekentkart serves a stand-in Kentkart API for offline
+// development. Run the server against it with
+// KENTKART_BASE_URL=http://localhost:8081.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/rfurkan37/transport-app/backend/internal/kentkartfake"
+)
+
+func main() {
+	addr := flag.String("addr", ":8081", "address to listen on")
+	region := flag.String("region", kentkartfake.DefaultRegion, "region to answer for")
+	recordings := flag.String("recordings", "", "directory of recorded nearest/bus responses named <stop_id>.json")
+	delay := flag.Duration("delay", 0, "delay every response by this long")
+	status := flag.Int("status", 0, "answer stops without a recording with this HTTP error status")
+	code := flag.Int("code", 0, "answer stops without a recording with this Kentkart result code")
+	malformed := flag.Bool("malformed", false, "answer stops without a recording with malformed JSON")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: fakekentkart [flags]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	fake := kentkartfake.New()
+	fake.SetRegion(*region)
+	if *recordings != "" {
+		n, err := fake.LoadRecordings(*recordings)
+		if err != nil {
+			log.Fatalf("Failed to load recordings: %v", err)
+		}
+		log.Printf("Loaded recordings for %d stops from %s\n", n, *recordings)
+	}
+
+	fallback := kentkartfake.Arrivals()
+	switch {
+	case *status != 0:
+		fallback = kentkartfake.HTTPError(*status)
+	case *code != 0:
+		fallback = kentkartfake.ResultCode(*code, "scripted failure")
+	case *malformed:
+		fallback = kentkartfake.Malformed()
+	}
+	fake.SetDefault(fallback)
+
+	var handler http.Handler = fake
+	if *delay > 0 {
+		handler = delayed(fake, *delay)
+	}
+
+	log.Printf("Fake Kentkart for region %s listening on %s\n", *region, *addr)
+	if err := http.ListenAndServe(*addr, handler); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// delayed holds every response back by d, or until the client gives up.
+func delayed(next http.Handler, d time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(d):
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -2,49 +2,133 @@
 package main
 
 import (
+	"context"
+	"expvar"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
 
+	"github.com/rfurkan37/transport-app/backend/internal/feed"
 	"github.com/rfurkan37/transport-app/backend/internal/handler"
+	"github.com/rfurkan37/transport-app/backend/internal/model"
+	"github.com/rfurkan37/transport-app/backend/internal/router"
 	"github.com/rfurkan37/transport-app/backend/internal/service"
 	"github.com/rs/cors"
 )
 
 func main() {
-	// Determine data directory
-	dataDir := os.Getenv("GTFS_DATA_DIR")
-	if dataDir == "" {
-		dataDir = filepath.Join("..", "..", "data", "kocaeli_transport_data")
+	if len(os.Args) > 1 && os.Args[1] == "validate" {
+		os.Exit(runValidate(os.Args[2:]))
 	}
 
-	// Load GTFS data
+	dataDir := feedPath()
+
+	// Footpath radius between nearby stops
+	loadOpts := service.DefaultLoadOptions()
+	if radius := os.Getenv("FOOTPATH_RADIUS"); radius != "" {
+		r, err := strconv.ParseFloat(radius, 64)
+		if err != nil {
+			log.Fatalf("Invalid FOOTPATH_RADIUS: %v", err)
+		}
+		loadOpts.FootpathRadius = r
+	}
+
+	// Load GTFS data and build the journey planner
 	log.Printf("Loading GTFS data from %s...\n", dataDir)
-	gtfsData, err := service.LoadGTFS(dataDir)
+	feeds := feed.NewManager(dataDir, loadOpts, router.DefaultOptions())
+	snap, err := feeds.Load()
 	if err != nil {
 		log.Fatalf("Failed to load GTFS data: %v", err)
 	}
 	log.Println("GTFS data loaded successfully!")
+	log.Printf("Feed validation: %d errors, %d warnings (see /admin/validation)\n", snap.Validation.Errors, snap.Validation.Warnings)
+	log.Printf("Journey planner ready with %d connections\n", snap.Planner.Connections())
 
-	// Create services and handler
-	kentkartClient := service.NewKentkartClient()
-	h := handler.New(gtfsData, kentkartClient)
+	// Reload the feed when it changes on disk or on SIGHUP
+	if pollInterval := durationEnv("FEED_POLL_INTERVAL", time.Minute); pollInterval > 0 {
+		go feeds.Watch(context.Background(), pollInterval)
+	}
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			log.Println("SIGHUP received, reloading feed")
+			if err := feeds.ReloadAsync(false); err != nil {
+				log.Println(err)
+			}
+		}
+	}()
+
+	// Create services and handler. Real-time data comes from the provider
+	// configured for each agency.
+	kentkartClient := service.NewKentkartClient(os.Getenv("KENTKART_BASE_URL"), os.Getenv("KENTKART_REGION"))
+	providers := map[string]service.RealtimeProvider{
+		service.ProviderKentkart: {Arrivals: kentkartClient, Vehicles: kentkartClient},
+		service.ProviderSchedule: {Arrivals: service.ScheduleProvider{}},
+	}
+	tripUpdatesURL, vehiclePositionsURL := os.Getenv("GTFS_RT_TRIP_UPDATES_URL"), os.Getenv("GTFS_RT_VEHICLE_POSITIONS_URL")
+	if tripUpdatesURL != "" || vehiclePositionsURL != "" {
+		providers[service.ProviderGTFSRT] = service.NewGTFSRealtime(tripUpdatesURL, vehiclePositionsURL).Provider()
+	}
+	arrivals := service.NewLiveCache[[]model.StopArrival]("arrivals",
+		durationEnv("ARRIVALS_CACHE_TTL", 20*time.Second),
+		durationEnv("ARRIVALS_MAX_STALE", 5*time.Minute))
+	vehicles := service.NewLiveCache[[]model.Vehicle]("vehicles",
+		durationEnv("VEHICLES_CACHE_TTL", 10*time.Second),
+		durationEnv("VEHICLES_MAX_STALE", time.Minute))
+	providerConfig := os.Getenv("REALTIME_PROVIDERS")
+	if providerConfig == "" {
+		providerConfig = service.ProviderKentkart
+	}
+	realtime, err := service.NewRealtime(providerConfig, providers, arrivals, vehicles)
+	if err != nil {
+		log.Fatalf("Invalid REALTIME_PROVIDERS: %v", err)
+	}
+	h := handler.New(feeds, realtime)
 
 	// Set up routes
 	mux := http.NewServeMux()
 	mux.HandleFunc("/health", h.Health)
 	mux.HandleFunc("/stops", h.Stops)
+	mux.HandleFunc("/stops/nearest", h.NearestStops)
 	mux.HandleFunc("/stops/arrivals", h.Arrivals)
+	mux.HandleFunc("/stops/departures", h.Departures)
+	mux.HandleFunc("/stops/transfers", h.Transfers)
+	mux.HandleFunc("/vehicles", h.Vehicles)
+	mux.HandleFunc("GET /stations/{id}", h.Station)
 	mux.HandleFunc("/routes", h.Routes)
+	mux.HandleFunc("/search", h.Search)
+	mux.HandleFunc("/route", h.Route)
+	mux.HandleFunc("/route/profile", h.RouteProfile)
 	mux.HandleFunc("/route/shape", h.RouteShape)
+	mux.HandleFunc("/shapes", h.Shapes)
+	mux.HandleFunc("/places", h.Places)
+	mux.HandleFunc("/places/nearest", h.NearestPlaces)
+	mux.HandleFunc("/isochrone", h.Isochrone)
+	mux.HandleFunc("/service/active", h.ActiveServices)
+	mux.HandleFunc("/fares", h.Fares)
+	mux.Handle("/debug/vars", expvar.Handler())
 
-	// Enable CORS
+	// Admin endpoints need ADMIN_TOKEN as a bearer token, and are not
+	// served at all without one.
+	adminToken := os.Getenv("ADMIN_TOKEN")
+	if adminToken != "" {
+		mux.Handle("/admin/validation", handler.RequireToken(adminToken, http.HandlerFunc(h.Validation)))
+		mux.Handle("/admin/reload", handler.RequireToken(adminToken, http.HandlerFunc(h.Reload)))
+	} else {
+		log.Println("ADMIN_TOKEN not set, admin endpoints disabled")
+	}
+
+	// Enable CORS. The API uses no cookies, so no origin gets credentials.
 	corsHandler := cors.New(cors.Options{
-		AllowedOrigins:   []string{"*"},
-		AllowedMethods:   []string{"GET", "POST", "OPTIONS"},
-		AllowedHeaders:   []string{"*"},
-		AllowCredentials: true,
+		AllowedOrigins: []string{"*"},
+		AllowedMethods: []string{"GET", "POST", "OPTIONS"},
+		AllowedHeaders: []string{"*"},
 	}).Handler(mux)
 
 	// Start server
@@ -56,12 +140,53 @@ func main() {
 	log.Printf("Starting server on :%s\n", port)
 	log.Println("Endpoints:")
 	log.Println("  GET /health              - Health check")
-	log.Println("  GET /stops               - List all stops (or nearby with lat/lon/radius)")
+	log.Println("  GET /stops               - List all stops (or nearby with lat/lon/radius, or bbox)")
+	log.Println("  GET /stops/nearest       - Nearest stops to a location")
 	log.Println("  GET /stops/arrivals      - Real-time arrivals for a stop")
+	log.Println("  GET /stops/departures    - Scheduled departures for a stop")
+	log.Println("  GET /stops/transfers     - Walking transfers from a stop")
+	log.Println("  GET /vehicles            - Live positions of buses heading to a stop or on a route")
+	log.Println("  GET /stations/{id}       - Platforms, entrances and pathways of a station")
 	log.Println("  GET /routes              - List all routes")
+	log.Println("  GET /search              - Find stops and routes by name")
+	log.Println("  GET /route               - Plan a journey between two coordinates")
+	log.Println("  GET /route/profile       - All optimal journeys in a departure window")
 	log.Println("  GET /route/shape         - Get shape points for a route")
+	log.Println("  GET /shapes              - Route geometries clipped to a bbox")
+	log.Println("  GET /places              - List card top-up places (or within a bbox)")
+	log.Println("  GET /places/nearest      - Nearest card top-up places to a location")
+	log.Println("  GET /isochrone           - Area reachable from a point in N minutes")
+	log.Println("  GET /service/active      - Service IDs running on a date")
+	log.Println("  GET /fares               - Fares that apply to a route")
+	if adminToken != "" {
+		log.Println("  GET /admin/validation    - Feed validation report")
+		log.Println("  POST /admin/reload       - Reload the feed in the background")
+	}
+	log.Println("  GET /debug/vars          - Runtime and real-time cache metrics")
 
 	if err := http.ListenAndServe(":"+port, corsHandler); err != nil {
 		log.Fatal(err)
 	}
 }
+
+// feedPath returns the GTFS feed location from GTFS_DATA_DIR, defaulting to
+// the bundled Kocaeli data.
+func feedPath() string {
+	if dir := os.Getenv("GTFS_DATA_DIR"); dir != "" {
+		return dir
+	}
+	return filepath.Join("..", "..", "data", "kocaeli_transport_data")
+}
+
+// durationEnv reads a duration such as "30s" from an environment variable.
+func durationEnv(name string, def time.Duration) time.Duration {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Fatalf("Invalid %s: %v", name, err)
+	}
+	return d
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/rfurkan37/transport-app/backend/internal/service"
+)
+
+// runValidate implements "server validate [-o report.json] [feed]". It loads
+// a feed, prints its validation report as JSON and returns the exit status:
+// 0 if the feed is valid, 1 if it has errors and 2 if it could not be loaded.
+func runValidate(args []string) int {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	output := fs.String("o", "", "write the report to this file instead of stdout")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: server validate [-o report.json] [feed directory or zip]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	feed := feedPath()
+	if fs.NArg() > 0 {
+		feed = fs.Arg(0)
+	}
+
+	// Keep the loaders' progress output out of the report.
+	stdout := os.Stdout
+	os.Stdout = os.Stderr
+	data, err := service.LoadGTFS(feed, service.DefaultLoadOptions())
+	os.Stdout = stdout
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load GTFS data: %v\n", err)
+		return 2
+	}
+
+	report := service.ValidateGTFS(data)
+	out := os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		defer f.Close()
+		out = f
+	}
+
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	fmt.Fprintf(os.Stderr, "%d errors, %d warnings\n", report.Errors, report.Warnings)
+	if !report.Valid {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,176 @@
+// Package feed manages the loaded GTFS feed and swaps in new versions of it
+// while the server is running.
+package feed
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rfurkan37/transport-app/backend/internal/model"
+	"github.com/rfurkan37/transport-app/backend/internal/router"
+	"github.com/rfurkan37/transport-app/backend/internal/service"
+)
+
+// Snapshot is one loaded version of the feed. It is never modified after
+// being built, so requests can keep using a snapshot after it is replaced.
+type Snapshot struct {
+	GTFS       *model.GTFSData
+	Planner    *router.Router
+	Validation *model.ValidationReport
+	LoadedAt   time.Time
+}
+
+// ErrReloading is returned when a reload is requested while one is running.
+var ErrReloading = errors.New("reload already in progress")
+
+// Status describes the current snapshot and the last reload attempt.
+type Status struct {
+	Path        string    `json:"path"`
+	LoadedAt    time.Time `json:"loaded_at"`
+	Reloading   bool      `json:"reloading"`
+	LastAttempt time.Time `json:"last_attempt,omitzero"`
+	LastError   string    `json:"last_error,omitempty"`
+
+	// Validation report of the last feed rejected by a reload
+	Rejected *model.ValidationReport `json:"rejected_validation,omitempty"`
+}
+
+// Manager holds the current snapshot of a feed and reloads it from disk.
+type Manager struct {
+	path       string
+	loadOpts   service.LoadOptions
+	routerOpts router.Options
+
+	current   atomic.Pointer[Snapshot]
+	reloading atomic.Bool
+
+	mu          sync.Mutex // guards the fields below
+	lastAttempt time.Time
+	lastErr     error
+	rejected    *model.ValidationReport
+}
+
+// NewManager creates a manager for the feed at path. Call Load before
+// using Current.
+func NewManager(path string, loadOpts service.LoadOptions, routerOpts router.Options) *Manager {
+	return &Manager{path: path, loadOpts: loadOpts, routerOpts: routerOpts}
+}
+
+// Path returns the feed directory or archive being served.
+func (m *Manager) Path() string {
+	return m.path
+}
+
+// Current returns the snapshot in use. Callers should fetch it once per
+// request so every lookup sees the same version of the feed.
+func (m *Manager) Current() *Snapshot {
+	return m.current.Load()
+}
+
+// Load builds the first snapshot. Unlike Reload it accepts a feed that fails
+// validation, so the server can still start on imperfect data.
+func (m *Manager) Load() (*Snapshot, error) {
+	snap, err := m.build()
+	if err != nil {
+		return nil, err
+	}
+	m.current.Store(snap)
+	return snap, nil
+}
+
+// Reload loads the feed again and swaps it in if it passes validation, or
+// despite validation errors when force is set. The previous snapshot stays
+// in use when loading or validation fails. It returns ErrReloading if
+// another reload is running.
+func (m *Manager) Reload(force bool) (*Snapshot, error) {
+	if !m.reloading.CompareAndSwap(false, true) {
+		return nil, ErrReloading
+	}
+	defer m.reloading.Store(false)
+	return m.reload(force)
+}
+
+// ReloadAsync starts a reload in the background. It returns ErrReloading if
+// one is already running.
+func (m *Manager) ReloadAsync(force bool) error {
+	if !m.reloading.CompareAndSwap(false, true) {
+		return ErrReloading
+	}
+	go func() {
+		defer m.reloading.Store(false)
+		m.reload(force)
+	}()
+	return nil
+}
+
+func (m *Manager) reload(force bool) (*Snapshot, error) {
+	snap, err := m.build()
+	var rejected *model.ValidationReport
+	if err == nil && !snap.Validation.Valid {
+		if force {
+			log.Printf("Feed reload forced despite %d validation errors, first: %s\n", snap.Validation.Errors, firstError(snap.Validation))
+		} else {
+			err = fmt.Errorf("validation failed with %d errors, first: %s (POST /admin/reload?force=true loads it anyway)", snap.Validation.Errors, firstError(snap.Validation))
+			rejected = snap.Validation
+		}
+	}
+
+	m.mu.Lock()
+	m.lastAttempt, m.lastErr, m.rejected = time.Now(), err, rejected
+	m.mu.Unlock()
+
+	if err != nil {
+		log.Printf("Feed reload failed, keeping snapshot from %s: %v\n", m.Current().LoadedAt.Format(time.RFC3339), err)
+		return nil, err
+	}
+	m.current.Store(snap)
+	log.Printf("Feed reloaded: %d stops, %d trips, %d connections\n", len(snap.GTFS.Stops), len(snap.GTFS.Trips), snap.Planner.Connections())
+	return snap, nil
+}
+
+// Status reports the current snapshot and the outcome of the last reload.
+func (m *Manager) Status() Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s := Status{
+		Path:        m.path,
+		Reloading:   m.reloading.Load(),
+		LastAttempt: m.lastAttempt,
+		Rejected:    m.rejected,
+	}
+	if snap := m.Current(); snap != nil {
+		s.LoadedAt = snap.LoadedAt
+	}
+	if m.lastErr != nil {
+		s.LastError = m.lastErr.Error()
+	}
+	return s
+}
+
+// firstError describes the first error of a validation report.
+func firstError(report *model.ValidationReport) string {
+	for _, issue := range report.Issues {
+		if issue.Severity == model.SeverityError {
+			return fmt.Sprintf("%s: %s", issue.Table, issue.Message)
+		}
+	}
+	return "none listed"
+}
+
+func (m *Manager) build() (*Snapshot, error) {
+	data, err := service.LoadGTFS(m.path, m.loadOpts)
+	if err != nil {
+		return nil, err
+	}
+	return &Snapshot{
+		GTFS:       data,
+		Planner:    router.New(data, m.routerOpts),
+		Validation: service.ValidateGTFS(data),
+		LoadedAt:   time.Now(),
+	}, nil
+}
@@ -0,0 +1,70 @@
+package feed
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Watch polls the feed for changes every interval until ctx is done. A
+// change is reloaded once the files have stayed the same for a full
+// interval, so a feed that is still being copied is not picked up half
+// written.
+func (m *Manager) Watch(ctx context.Context, interval time.Duration) {
+	loaded, _ := fingerprint(m.path)
+	prev := loaded
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		fp, err := fingerprint(m.path)
+		if err != nil {
+			log.Printf("Feed watch: %v\n", err)
+			continue
+		}
+		if fp != loaded && fp == prev {
+			log.Printf("Feed watch: %s changed, reloading\n", m.path)
+			// A broken feed is not retried until its files change again.
+			if _, err := m.Reload(false); err != ErrReloading {
+				loaded = fp
+			}
+		}
+		prev = fp
+	}
+}
+
+// fingerprint summarizes the size and modification time of a feed archive,
+// or of every file in a feed directory.
+func fingerprint(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return fmt.Sprintf("%d:%d", info.Size(), info.ModTime().UnixNano()), nil
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return "", err
+	}
+	var size, latest int64
+	for _, e := range entries {
+		fi, err := os.Stat(filepath.Join(path, e.Name()))
+		if err != nil || fi.IsDir() {
+			continue
+		}
+		size += fi.Size()
+		latest = max(latest, fi.ModTime().UnixNano())
+	}
+	return fmt.Sprintf("%d:%d:%d", len(entries), size, latest), nil
+}
@@ -0,0 +1,104 @@
+package geo
+
+import "math"
+
+// BBox is a bounding box in decimal degrees.
+type BBox struct {
+	MinLon, MinLat float64
+	MaxLon, MaxLat float64
+}
+
+// Bounds returns the bounding box of a list of [lon, lat] positions.
+func Bounds(coords [][2]float64) BBox {
+	b := BBox{MinLon: math.Inf(1), MinLat: math.Inf(1), MaxLon: math.Inf(-1), MaxLat: math.Inf(-1)}
+	for _, c := range coords {
+		b.MinLon, b.MaxLon = math.Min(b.MinLon, c[0]), math.Max(b.MaxLon, c[0])
+		b.MinLat, b.MaxLat = math.Min(b.MinLat, c[1]), math.Max(b.MaxLat, c[1])
+	}
+	return b
+}
+
+// Contains reports whether a coordinate lies inside the box.
+func (b BBox) Contains(lat, lon float64) bool {
+	return lat >= b.MinLat && lat <= b.MaxLat && lon >= b.MinLon && lon <= b.MaxLon
+}
+
+// Distance returns the distance in meters from a coordinate to the nearest
+// point of the box, 0 inside it.
+func (b BBox) Distance(lat, lon float64) float64 {
+	return HaversineDistance(lat, lon, math.Max(b.MinLat, math.Min(lat, b.MaxLat)), math.Max(b.MinLon, math.Min(lon, b.MaxLon)))
+}
+
+// Intersects reports whether two boxes overlap.
+func (b BBox) Intersects(o BBox) bool {
+	return b.MinLon <= o.MaxLon && o.MinLon <= b.MaxLon && b.MinLat <= o.MaxLat && o.MinLat <= b.MaxLat
+}
+
+// ClipLineString clips a line of [lon, lat] positions to the box and returns
+// the parts that fall inside it.
+func ClipLineString(coords [][2]float64, b BBox) [][][2]float64 {
+	var parts [][][2]float64
+	var current [][2]float64
+
+	for i := 1; i < len(coords); i++ {
+		p, q, ok := clipSegment(coords[i-1], coords[i], b)
+		if !ok {
+			if len(current) > 1 {
+				parts = append(parts, current)
+			}
+			current = nil
+			continue
+		}
+		if len(current) == 0 || current[len(current)-1] != p {
+			if len(current) > 1 {
+				parts = append(parts, current)
+			}
+			current = [][2]float64{p}
+		}
+		current = append(current, q)
+	}
+
+	if len(current) > 1 {
+		parts = append(parts, current)
+	}
+	return parts
+}
+
+// clipSegment clips the segment p-q to the box with the Liang-Barsky
+// algorithm, reporting false if no part of it is inside.
+func clipSegment(p, q [2]float64, b BBox) ([2]float64, [2]float64, bool) {
+	dx, dy := q[0]-p[0], q[1]-p[1]
+	t0, t1 := 0.0, 1.0
+
+	edges := [4][2]float64{
+		{-dx, p[0] - b.MinLon},
+		{dx, b.MaxLon - p[0]},
+		{-dy, p[1] - b.MinLat},
+		{dy, b.MaxLat - p[1]},
+	}
+	for _, e := range edges {
+		pe, qe := e[0], e[1]
+		if pe == 0 {
+			if qe < 0 {
+				return p, q, false
+			}
+			continue
+		}
+		t := qe / pe
+		if pe < 0 {
+			if t > t1 {
+				return p, q, false
+			}
+			t0 = math.Max(t0, t)
+		} else {
+			if t < t0 {
+				return p, q, false
+			}
+			t1 = math.Min(t1, t)
+		}
+	}
+
+	start := [2]float64{p[0] + t0*dx, p[1] + t0*dy}
+	end := [2]float64{p[0] + t1*dx, p[1] + t1*dy}
+	return start, end, true
+}
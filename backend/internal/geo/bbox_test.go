@@ -0,0 +1,56 @@
+package geo
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestClipLineString(t *testing.T) {
+	box := BBox{MinLon: 0, MinLat: 0, MaxLon: 10, MaxLat: 10}
+
+	tests := []struct {
+		name   string
+		coords [][2]float64
+		want   [][][2]float64
+	}{
+		{"inside", [][2]float64{{1, 1}, {2, 2}, {3, 1}}, [][][2]float64{{{1, 1}, {2, 2}, {3, 1}}}},
+		{"crossing", [][2]float64{{-5, 5}, {15, 5}}, [][][2]float64{{{0, 5}, {10, 5}}}},
+		{"entering", [][2]float64{{5, -5}, {5, 5}, {6, 6}}, [][][2]float64{{{5, 0}, {5, 5}, {6, 6}}}},
+		{"leaving and coming back", [][2]float64{{5, 5}, {5, 15}, {8, 15}, {8, 5}}, [][][2]float64{
+			{{5, 5}, {5, 10}},
+			{{8, 10}, {8, 5}},
+		}},
+		{"outside", [][2]float64{{-5, -5}, {-1, 20}}, nil},
+		{"past a corner", [][2]float64{{-5, 6}, {6, 17}}, nil},
+		{"along an edge", [][2]float64{{0, -5}, {0, 5}}, [][][2]float64{{{0, 0}, {0, 5}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClipLineString(tt.coords, box); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ClipLineString() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIntersects(t *testing.T) {
+	box := BBox{MinLon: 0, MinLat: 0, MaxLon: 10, MaxLat: 10}
+	tests := []struct {
+		name string
+		o    BBox
+		want bool
+	}{
+		{"overlapping", BBox{5, 5, 15, 15}, true},
+		{"inside", BBox{2, 2, 3, 3}, true},
+		{"touching", BBox{10, 0, 20, 10}, true},
+		{"beside", BBox{11, 0, 20, 10}, false},
+		{"above", BBox{0, 11, 10, 20}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := box.Intersects(tt.o); got != tt.want {
+				t.Errorf("Intersects(%+v) = %v, want %v", tt.o, got, tt.want)
+			}
+		})
+	}
+}
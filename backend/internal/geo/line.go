@@ -0,0 +1,37 @@
+package geo
+
+import "math"
+
+// ProjectOnLine finds the point of a line ([lon, lat] pairs) closest to a
+// coordinate. It returns the distance in meters along the line to that
+// point and from the coordinate to it. Segments are treated as straight in
+// a local equirectangular projection, which is accurate over the short
+// segments of route shapes.
+func ProjectOnLine(coords [][2]float64, lat, lon float64) (along, offset float64) {
+	if len(coords) == 0 {
+		return 0, math.Inf(1)
+	}
+	offset = HaversineDistance(lat, lon, coords[0][1], coords[0][0])
+
+	var walked float64
+	for i := 1; i < len(coords); i++ {
+		a, b := coords[i-1], coords[i]
+		length := HaversineDistance(a[1], a[0], b[1], b[0])
+
+		// Position of the coordinate along the segment, from 0 at a to
+		// 1 at b, with longitudes scaled to match latitudes.
+		cosLat := math.Cos(a[1] * math.Pi / 180)
+		bx, by := (b[0]-a[0])*cosLat, b[1]-a[1]
+		px, py := (lon-a[0])*cosLat, lat-a[1]
+		t := 0.0
+		if d := bx*bx + by*by; d > 0 {
+			t = math.Max(0, math.Min(1, (px*bx+py*by)/d))
+		}
+		pLat, pLon := a[1]+t*(b[1]-a[1]), a[0]+t*(b[0]-a[0])
+		if d := HaversineDistance(lat, lon, pLat, pLon); d < offset {
+			along, offset = walked+t*length, d
+		}
+		walked += length
+	}
+	return along, offset
+}
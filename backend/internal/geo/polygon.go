@@ -0,0 +1,30 @@
+package geo
+
+import "math"
+
+// Destination returns the point reached by travelling distance meters from
+// a coordinate along an initial bearing in degrees.
+func Destination(lat, lon, bearing, distance float64) (float64, float64) {
+	const earthRadius = 6371000 // meters
+
+	lat1 := lat * math.Pi / 180
+	lon1 := lon * math.Pi / 180
+	brng := bearing * math.Pi / 180
+	ang := distance / earthRadius
+
+	lat2 := math.Asin(math.Sin(lat1)*math.Cos(ang) + math.Cos(lat1)*math.Sin(ang)*math.Cos(brng))
+	lon2 := lon1 + math.Atan2(math.Sin(brng)*math.Sin(ang)*math.Cos(lat1), math.Cos(ang)-math.Sin(lat1)*math.Sin(lat2))
+
+	return lat2 * 180 / math.Pi, lon2 * 180 / math.Pi
+}
+
+// Circle approximates a circle of radius meters around a coordinate as a
+// closed ring of [lon, lat] positions with the given number of segments.
+func Circle(lat, lon, radius float64, segments int) [][2]float64 {
+	ring := make([][2]float64, 0, segments+1)
+	for i := 0; i < segments; i++ {
+		pLat, pLon := Destination(lat, lon, float64(i)*360/float64(segments), radius)
+		ring = append(ring, [2]float64{pLon, pLat})
+	}
+	return append(ring, ring[0])
+}
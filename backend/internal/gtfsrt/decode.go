@@ -0,0 +1,347 @@
+package gtfsrt
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Protocol buffer wire types
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errTruncated = errors.New("truncated message")
+
+// Decode parses a GTFS-Realtime FeedMessage in protocol buffer encoding.
+func Decode(b []byte) (*FeedMessage, error) {
+	msg := &FeedMessage{}
+	err := fields(b, func(d *decoder, num, wire int) error {
+		switch num {
+		case 1:
+			return d.message(wire, msg.decodeHeader)
+		case 2:
+			e := &FeedEntity{}
+			if err := d.message(wire, e.decode); err != nil {
+				return err
+			}
+			msg.Entities = append(msg.Entities, e)
+			return nil
+		}
+		return d.skip(wire)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("decoding GTFS-Realtime feed: %w", err)
+	}
+	return msg, nil
+}
+
+func (m *FeedMessage) decodeHeader(b []byte) error {
+	return fields(b, func(d *decoder, num, wire int) (err error) {
+		switch num {
+		case 1:
+			m.Version, err = d.string(wire)
+		case 3:
+			m.Timestamp, err = d.int64(wire)
+		default:
+			err = d.skip(wire)
+		}
+		return err
+	})
+}
+
+func (e *FeedEntity) decode(b []byte) error {
+	return fields(b, func(d *decoder, num, wire int) (err error) {
+		switch num {
+		case 1:
+			e.ID, err = d.string(wire)
+		case 2:
+			var v uint64
+			v, err = d.uvarint(wire)
+			e.IsDeleted = v != 0
+		case 3:
+			e.TripUpdate = &TripUpdate{}
+			err = d.message(wire, e.TripUpdate.decode)
+		case 4:
+			e.Vehicle = &VehiclePosition{CurrentStatus: InTransitTo}
+			err = d.message(wire, e.Vehicle.decode)
+		default:
+			err = d.skip(wire)
+		}
+		return err
+	})
+}
+
+func (t *TripUpdate) decode(b []byte) error {
+	return fields(b, func(d *decoder, num, wire int) (err error) {
+		switch num {
+		case 1:
+			err = d.message(wire, t.Trip.decode)
+		case 2:
+			u := &StopTimeUpdate{}
+			if err = d.message(wire, u.decode); err == nil {
+				t.StopTimeUpdates = append(t.StopTimeUpdates, u)
+			}
+		case 3:
+			err = d.message(wire, t.Vehicle.decode)
+		case 4:
+			t.Timestamp, err = d.int64(wire)
+		case 5:
+			t.Delay, err = d.int32(wire)
+			t.HasDelay = true
+		default:
+			err = d.skip(wire)
+		}
+		return err
+	})
+}
+
+func (t *TripDescriptor) decode(b []byte) error {
+	return fields(b, func(d *decoder, num, wire int) (err error) {
+		switch num {
+		case 1:
+			t.TripID, err = d.string(wire)
+		case 2:
+			t.StartTime, err = d.string(wire)
+		case 3:
+			t.StartDate, err = d.string(wire)
+		case 4:
+			t.ScheduleRelationship, err = d.int(wire)
+		case 5:
+			t.RouteID, err = d.string(wire)
+		case 6:
+			t.DirectionID, err = d.int(wire)
+		default:
+			err = d.skip(wire)
+		}
+		return err
+	})
+}
+
+func (v *VehicleDescriptor) decode(b []byte) error {
+	return fields(b, func(d *decoder, num, wire int) (err error) {
+		switch num {
+		case 1:
+			v.ID, err = d.string(wire)
+		case 2:
+			v.Label, err = d.string(wire)
+		case 3:
+			v.LicensePlate, err = d.string(wire)
+		default:
+			err = d.skip(wire)
+		}
+		return err
+	})
+}
+
+func (u *StopTimeUpdate) decode(b []byte) error {
+	return fields(b, func(d *decoder, num, wire int) (err error) {
+		switch num {
+		case 1:
+			u.StopSequence, err = d.int(wire)
+			u.HasStopSequence = true
+		case 2:
+			u.Arrival = &StopTimeEvent{}
+			err = d.message(wire, u.Arrival.decode)
+		case 3:
+			u.Departure = &StopTimeEvent{}
+			err = d.message(wire, u.Departure.decode)
+		case 4:
+			u.StopID, err = d.string(wire)
+		case 5:
+			u.ScheduleRelationship, err = d.int(wire)
+		default:
+			err = d.skip(wire)
+		}
+		return err
+	})
+}
+
+func (e *StopTimeEvent) decode(b []byte) error {
+	return fields(b, func(d *decoder, num, wire int) (err error) {
+		switch num {
+		case 1:
+			e.Delay, err = d.int32(wire)
+		case 2:
+			e.Time, err = d.int64(wire)
+		default:
+			err = d.skip(wire)
+		}
+		return err
+	})
+}
+
+func (v *VehiclePosition) decode(b []byte) error {
+	return fields(b, func(d *decoder, num, wire int) (err error) {
+		switch num {
+		case 1:
+			err = d.message(wire, v.Trip.decode)
+		case 2:
+			v.Position = &Position{}
+			err = d.message(wire, v.Position.decode)
+		case 3:
+			v.CurrentStopSequence, err = d.int(wire)
+		case 4:
+			v.CurrentStatus, err = d.int(wire)
+		case 5:
+			v.Timestamp, err = d.int64(wire)
+		case 7:
+			v.StopID, err = d.string(wire)
+		case 8:
+			err = d.message(wire, v.Vehicle.decode)
+		default:
+			err = d.skip(wire)
+		}
+		return err
+	})
+}
+
+func (p *Position) decode(b []byte) error {
+	return fields(b, func(d *decoder, num, wire int) (err error) {
+		switch num {
+		case 1:
+			p.Latitude, err = d.float(wire)
+		case 2:
+			p.Longitude, err = d.float(wire)
+		case 3:
+			p.Bearing, err = d.float(wire)
+		case 5:
+			p.Speed, err = d.float(wire)
+		default:
+			err = d.skip(wire)
+		}
+		return err
+	})
+}
+
+// decoder reads the fields of one protocol buffer message.
+type decoder struct {
+	b []byte
+}
+
+// fields calls fn for each field of a message, which must consume the
+// field's value.
+func fields(b []byte, fn func(d *decoder, num, wire int) error) error {
+	d := &decoder{b: b}
+	for len(d.b) > 0 {
+		key, err := d.varint()
+		if err != nil {
+			return err
+		}
+		num, wire := int(key>>3), int(key&7)
+		if num == 0 {
+			return errors.New("invalid field number 0")
+		}
+		if err := fn(d, num, wire); err != nil {
+			return fmt.Errorf("field %d: %w", num, err)
+		}
+	}
+	return nil
+}
+
+func (d *decoder) varint() (uint64, error) {
+	v, n := binary.Uvarint(d.b)
+	if n <= 0 {
+		return 0, errTruncated
+	}
+	d.b = d.b[n:]
+	return v, nil
+}
+
+func (d *decoder) uvarint(wire int) (uint64, error) {
+	if wire != wireVarint {
+		return 0, fmt.Errorf("wire type %d, want varint", wire)
+	}
+	return d.varint()
+}
+
+// int reads an enum or a uint32.
+func (d *decoder) int(wire int) (int, error) {
+	v, err := d.uvarint(wire)
+	return int(int32(v)), err
+}
+
+// int32 reads an int32, whose negative values are sign-extended to 64 bits.
+func (d *decoder) int32(wire int) (int32, error) {
+	v, err := d.uvarint(wire)
+	return int32(v), err
+}
+
+// int64 reads an int64 or uint64.
+func (d *decoder) int64(wire int) (int64, error) {
+	v, err := d.uvarint(wire)
+	return int64(v), err
+}
+
+func (d *decoder) bytes(wire int) ([]byte, error) {
+	if wire != wireBytes {
+		return nil, fmt.Errorf("wire type %d, want length-delimited", wire)
+	}
+	n, err := d.varint()
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(len(d.b)) {
+		return nil, errTruncated
+	}
+	b := d.b[:n]
+	d.b = d.b[n:]
+	return b, nil
+}
+
+func (d *decoder) string(wire int) (string, error) {
+	b, err := d.bytes(wire)
+	return string(b), err
+}
+
+func (d *decoder) message(wire int, decode func([]byte) error) error {
+	b, err := d.bytes(wire)
+	if err != nil {
+		return err
+	}
+	return decode(b)
+}
+
+func (d *decoder) float(wire int) (float64, error) {
+	if wire != wireFixed32 {
+		return 0, fmt.Errorf("wire type %d, want 32-bit", wire)
+	}
+	if len(d.b) < 4 {
+		return 0, errTruncated
+	}
+	v := math.Float32frombits(binary.LittleEndian.Uint32(d.b))
+	d.b = d.b[4:]
+	// Widen through the shortest decimal form, so 40.77 stays 40.77
+	// rather than becoming 40.77000045776367.
+	return strconv.ParseFloat(strconv.FormatFloat(float64(v), 'g', -1, 32), 64)
+}
+
+// skip consumes a field the decoder has no use for.
+func (d *decoder) skip(wire int) error {
+	switch wire {
+	case wireVarint:
+		_, err := d.varint()
+		return err
+	case wireFixed64:
+		if len(d.b) < 8 {
+			return errTruncated
+		}
+		d.b = d.b[8:]
+	case wireBytes:
+		_, err := d.bytes(wire)
+		return err
+	case wireFixed32:
+		if len(d.b) < 4 {
+			return errTruncated
+		}
+		d.b = d.b[4:]
+	default:
+		return fmt.Errorf("unsupported wire type %d", wire)
+	}
+	return nil
+}
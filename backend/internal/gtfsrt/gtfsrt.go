@@ -0,0 +1,108 @@
+// Package gtfsrt decodes GTFS-Realtime feeds. Only the fields of trip
+// updates and vehicle positions the server uses are read; the rest of the
+// protocol buffer is skipped.
+package gtfsrt
+
+// FeedMessage is a decoded GTFS-Realtime feed.
+type FeedMessage struct {
+	Version   string
+	Timestamp int64 // POSIX time the feed was created
+	Entities  []*FeedEntity
+}
+
+// FeedEntity is one update of a feed: a trip update, a vehicle position or
+// both. Alerts are skipped.
+type FeedEntity struct {
+	ID         string
+	IsDeleted  bool
+	TripUpdate *TripUpdate
+	Vehicle    *VehiclePosition
+}
+
+// Trip schedule relationships
+const (
+	TripScheduled   = 0
+	TripAdded       = 1
+	TripUnscheduled = 2
+	TripCanceled    = 3
+)
+
+// TripDescriptor identifies the trip an update is about.
+type TripDescriptor struct {
+	TripID               string
+	RouteID              string
+	DirectionID          int
+	StartTime            string // HH:MM:SS
+	StartDate            string // YYYYMMDD
+	ScheduleRelationship int
+}
+
+// VehicleDescriptor identifies a vehicle.
+type VehicleDescriptor struct {
+	ID           string
+	Label        string
+	LicensePlate string
+}
+
+// TripUpdate gives predicted times for the stops of a trip.
+type TripUpdate struct {
+	Trip            TripDescriptor
+	Vehicle         VehicleDescriptor
+	StopTimeUpdates []*StopTimeUpdate
+	Timestamp       int64
+	Delay           int32 // seconds, for stops without an update of their own
+	HasDelay        bool
+}
+
+// Stop time schedule relationships
+const (
+	StopScheduled = 0
+	StopSkipped   = 1
+	StopNoData    = 2
+)
+
+// StopTimeUpdate is the prediction for one stop of a trip, identified by
+// stop sequence, stop ID or both. Its delay also applies to the stops after
+// it that have no update of their own.
+type StopTimeUpdate struct {
+	StopSequence         int
+	HasStopSequence      bool
+	StopID               string
+	Arrival              *StopTimeEvent
+	Departure            *StopTimeEvent
+	ScheduleRelationship int
+}
+
+// StopTimeEvent is a predicted arrival or departure, as an absolute time, a
+// delay from the schedule, or both.
+type StopTimeEvent struct {
+	Delay int32 // seconds
+	Time  int64 // POSIX time, 0 if not given
+}
+
+// Vehicle stop statuses
+const (
+	IncomingAt  = 0
+	StoppedAt   = 1
+	InTransitTo = 2
+)
+
+// VehiclePosition is where a vehicle is.
+type VehiclePosition struct {
+	Trip                TripDescriptor
+	Vehicle             VehicleDescriptor
+	Position            *Position
+	CurrentStopSequence int
+	StopID              string
+	CurrentStatus       int
+	Timestamp           int64
+}
+
+// Position is a vehicle's location, bearing in degrees clockwise from north
+// and speed in meters per second.
+type Position struct {
+	Latitude  float64
+	Longitude float64
+	Bearing   float64
+	Speed     float64
+}
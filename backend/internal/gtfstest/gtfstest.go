@@ -0,0 +1,39 @@
+// Package gtfstest writes small hand-written GTFS feeds for tests.
+//
+//	feed := gtfstest.Base()
+//	feed["stops"] = "stop_id,stop_name,stop_lat,stop_lon\nS1,One,40.76,29.90\n..."
+//	dir := gtfstest.Write(t, feed)
+package gtfstest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Feed holds the CSV contents of a feed's tables by name, such as "stops".
+type Feed map[string]string
+
+// Base returns the tables every test feed shares: agency A in
+// Europe/Istanbul, service ALL running every day of 2020 to 2099, and no
+// shapes. Tests add their stops, routes, trips and stop times.
+func Base() Feed {
+	return Feed{
+		"agency":   "agency_id,agency_name,agency_url,agency_timezone\nA,Test Transit,https://example.com,Europe/Istanbul\n",
+		"calendar": "service_id,monday,tuesday,wednesday,thursday,friday,saturday,sunday,start_date,end_date\nALL,1,1,1,1,1,1,1,20200101,20991231\n",
+		"shapes":   "shape_id,shape_pt_lat,shape_pt_lon,shape_pt_sequence\n",
+	}
+}
+
+// Write writes a feed as .txt files into a temporary directory removed
+// when the test ends, and returns the directory.
+func Write(tb testing.TB, feed Feed) string {
+	tb.Helper()
+	dir := tb.TempDir()
+	for name, content := range feed {
+		if err := os.WriteFile(filepath.Join(dir, name+".txt"), []byte(content), 0o644); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	return dir
+}
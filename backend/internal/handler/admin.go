@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/rfurkan37/transport-app/backend/internal/feed"
+)
+
+// RequireToken serves next only to requests carrying token as a bearer
+// token in the Authorization header.
+func RequireToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Validation returns the validation report of the loaded feed.
+func (h *Handler) Validation(w http.ResponseWriter, r *http.Request) {
+	snap := h.feeds.Current()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(snap.Validation)
+}
+
+// Reload starts loading the feed again on POST; the new data is served once
+// it has loaded and passed validation, or despite validation errors with
+// force=true. GET reports the reload status.
+func (h *Handler) Reload(w http.ResponseWriter, r *http.Request) {
+	status := http.StatusOK
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		force := false
+		if s := r.URL.Query().Get("force"); s != "" {
+			var err error
+			if force, err = strconv.ParseBool(s); err != nil {
+				http.Error(w, "invalid force parameter", http.StatusBadRequest)
+				return
+			}
+		}
+		if err := h.feeds.ReloadAsync(force); err == feed.ErrReloading {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		status = http.StatusAccepted
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(h.feeds.Status())
+}
@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"github.com/rfurkan37/transport-app/backend/internal/service"
+)
+
+// Active services response types
+
+type activeServicesResponse struct {
+	Date       string   `json:"date"`
+	Weekday    string   `json:"weekday"`
+	Timezone   string   `json:"timezone"`
+	ServiceIDs []string `json:"service_ids"`
+	Added      []string `json:"added"`
+	Removed    []string `json:"removed"`
+	Count      int      `json:"count"`
+}
+
+// ActiveServices returns the service IDs running on a date, defaulting to
+// today in the agency time zone.
+func (h *Handler) ActiveServices(w http.ResponseWriter, r *http.Request) {
+	snap := h.feeds.Current()
+	w.Header().Set("Content-Type", "application/json")
+
+	date, _, err := parseDateTime(r, snap.GTFS)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	res := service.ResolveServices(snap.GTFS, date)
+	ids := make([]string, 0, len(res.Active))
+	for id := range res.Active {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	resp := activeServicesResponse{
+		Date:       res.Date.Format("20060102"),
+		Weekday:    res.Date.Weekday().String(),
+		Timezone:   res.Date.Location().String(),
+		ServiceIDs: ids,
+		Added:      res.Added,
+		Removed:    res.Removed,
+		Count:      len(ids),
+	}
+	if resp.Added == nil {
+		resp.Added = []string{}
+	}
+	if resp.Removed == nil {
+		resp.Removed = []string{}
+	}
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/rfurkan37/transport-app/backend/internal/model"
+	"github.com/rfurkan37/transport-app/backend/internal/service"
+)
+
+// Fares response types
+
+type faresResponse struct {
+	RouteID string            `json:"route_id"`
+	Fares   []model.RouteFare `json:"fares"`
+	Count   int               `json:"count"`
+}
+
+// Fares lists the fares that can apply to rides on a route, cheapest first.
+func (h *Handler) Fares(w http.ResponseWriter, r *http.Request) {
+	snap := h.feeds.Current()
+	w.Header().Set("Content-Type", "application/json")
+
+	routeID := r.URL.Query().Get("route_id")
+	if routeID == "" {
+		http.Error(w, "route_id parameter required", http.StatusBadRequest)
+		return
+	}
+	if _, ok := snap.GTFS.Routes[routeID]; !ok {
+		http.Error(w, "route not found", http.StatusNotFound)
+		return
+	}
+
+	fares := service.RouteFares(snap.GTFS, routeID)
+	json.NewEncoder(w).Encode(faresResponse{
+		RouteID: routeID,
+		Fares:   fares,
+		Count:   len(fares),
+	})
+}
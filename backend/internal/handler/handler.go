@@ -2,27 +2,38 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"math"
 	"net/http"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/rfurkan37/transport-app/backend/internal/feed"
 	"github.com/rfurkan37/transport-app/backend/internal/geo"
 	"github.com/rfurkan37/transport-app/backend/internal/model"
 	"github.com/rfurkan37/transport-app/backend/internal/service"
+	"github.com/rfurkan37/transport-app/backend/internal/spatial"
 )
 
 // Handler holds dependencies for HTTP handlers.
+// Each request reads the current feed snapshot once, so a reload never
+// changes the data under a request in flight.
 type Handler struct {
-	gtfs     *model.GTFSData
-	kentkart *service.KentkartClient
+	feeds    *feed.Manager
+	realtime *service.Realtime
 }
 
 // New creates a new Handler with the given dependencies.
-func New(gtfs *model.GTFSData, kentkart *service.KentkartClient) *Handler {
+func New(feeds *feed.Manager, realtime *service.Realtime) *Handler {
 	return &Handler{
-		gtfs:     gtfs,
-		kentkart: kentkart,
+		feeds:    feeds,
+		realtime: realtime,
 	}
 }
 
@@ -42,9 +53,42 @@ type stopsResponse struct {
 	Count int           `json:"count"`
 }
 
-// Stops returns all stops or nearby stops if lat/lon provided.
+type nearbyStop struct {
+	*model.Stop
+	Distance float64 `json:"distance_m"`
+	Bearing  float64 `json:"bearing"`
+}
+
+type nearbyStopsResponse struct {
+	Stops []nearbyStop `json:"stops"`
+	Count int          `json:"count"`
+}
+
+// Stops returns all stops, the stops inside a bbox, or, if lat/lon provided,
+// nearby stops sorted by distance.
 func (h *Handler) Stops(w http.ResponseWriter, r *http.Request) {
+	snap := h.feeds.Current()
 	w.Header().Set("Content-Type", "application/json")
+	lang := requestLanguage(r, snap.GTFS)
+
+	// Viewport filter
+	if bboxStr := r.URL.Query().Get("bbox"); bboxStr != "" {
+		bbox, err := parseBBox(bboxStr)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		results := snap.GTFS.StopIndex.BBox(bbox.MinLat, bbox.MinLon, bbox.MaxLat, bbox.MaxLon)
+		stops := make([]*model.Stop, 0, len(results))
+		for _, res := range results {
+			stops = append(stops, service.LocalizeStop(snap.GTFS, res.Item, lang))
+		}
+		json.NewEncoder(w).Encode(stopsResponse{
+			Stops: stops,
+			Count: len(stops),
+		})
+		return
+	}
 
 	lat := r.URL.Query().Get("lat")
 	lon := r.URL.Query().Get("lon")
@@ -52,51 +96,105 @@ func (h *Handler) Stops(w http.ResponseWriter, r *http.Request) {
 
 	// No location filter - return all stops
 	if lat == "" || lon == "" {
+		stops := snap.GTFS.StopsList
+		if lang != "" {
+			stops = make([]*model.Stop, len(snap.GTFS.StopsList))
+			for i, stop := range snap.GTFS.StopsList {
+				stops[i] = service.LocalizeStop(snap.GTFS, stop, lang)
+			}
+		}
 		json.NewEncoder(w).Encode(stopsResponse{
-			Stops: h.gtfs.StopsList,
-			Count: len(h.gtfs.StopsList),
+			Stops: stops,
+			Count: len(stops),
 		})
 		return
 	}
 
 	// Parse coordinates
-	latF, err := strconv.ParseFloat(lat, 64)
-	if err != nil {
-		http.Error(w, "invalid lat parameter", http.StatusBadRequest)
-		return
-	}
-	lonF, err := strconv.ParseFloat(lon, 64)
+	latF, lonF, err := parseLocation(r)
 	if err != nil {
-		http.Error(w, "invalid lon parameter", http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	radius := 500.0 // default 500m
 	if radiusStr != "" {
-		if radius, err = strconv.ParseFloat(radiusStr, 64); err != nil {
+		if radius, err = strconv.ParseFloat(radiusStr, 64); err != nil || !(radius > 0 && radius <= nearestRadius) {
 			http.Error(w, "invalid radius parameter", http.StatusBadRequest)
 			return
 		}
 	}
 
 	// Find nearby stops
-	nearby := h.findNearbyStops(latF, lonF, radius)
-	json.NewEncoder(w).Encode(stopsResponse{
+	nearby := findNearbyStops(snap.GTFS, latF, lonF, radius)
+	for i := range nearby {
+		nearby[i].Stop = service.LocalizeStop(snap.GTFS, nearby[i].Stop, lang)
+	}
+	json.NewEncoder(w).Encode(nearbyStopsResponse{
 		Stops: nearby,
 		Count: len(nearby),
 	})
 }
 
+// nearestRadius is how far from a location /stops/nearest and
+// /places/nearest look for results.
+const nearestRadius = 20000 // meters
+
+// NearestStops returns the k stops closest to a location.
+func (h *Handler) NearestStops(w http.ResponseWriter, r *http.Request) {
+	snap := h.feeds.Current()
+	w.Header().Set("Content-Type", "application/json")
+
+	lat, lon, err := parseLocation(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := checkCoverage(snap.GTFS.StopIndex.Bounds(), lat, lon); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	k := 1
+	if kStr := r.URL.Query().Get("k"); kStr != "" {
+		if k, err = strconv.Atoi(kStr); err != nil || k <= 0 || k > 100 {
+			http.Error(w, "invalid k parameter", http.StatusBadRequest)
+			return
+		}
+	}
+
+	lang := requestLanguage(r, snap.GTFS)
+	results := snap.GTFS.StopIndex.Nearest(lat, lon, k, nearestRadius)
+	nearest := make([]nearbyStop, 0, len(results))
+	for _, res := range results {
+		stop := newNearbyStop(lat, lon, res)
+		stop.Stop = service.LocalizeStop(snap.GTFS, stop.Stop, lang)
+		nearest = append(nearest, stop)
+	}
+	json.NewEncoder(w).Encode(nearbyStopsResponse{
+		Stops: nearest,
+		Count: len(nearest),
+	})
+}
+
 // Arrivals response types
 
 type arrivalsResponse struct {
-	StopID   string              `json:"stop_id"`
-	StopName string              `json:"stop_name"`
-	Arrivals []model.StopArrival `json:"arrivals"`
+	StopID    string              `json:"stop_id"`
+	StopName  string              `json:"stop_name"`
+	Arrivals  []model.StopArrival `json:"arrivals"`
+	FetchedAt time.Time           `json:"fetched_at"`
+	Stale     bool                `json:"stale"` // upstream unavailable; arrivals may be outdated
 }
 
-// Arrivals returns real-time arrivals for a stop.
+// realtimeTimeout bounds how long a request waits for real-time providers
+// before answering with stale data or an error.
+const realtimeTimeout = 8 * time.Second
+
+// Arrivals returns real-time arrivals for a stop, from the providers of the
+// agencies serving it.
 func (h *Handler) Arrivals(w http.ResponseWriter, r *http.Request) {
+	snap := h.feeds.Current()
 	w.Header().Set("Content-Type", "application/json")
 
 	stopID := r.URL.Query().Get("stop_id")
@@ -105,23 +203,130 @@ func (h *Handler) Arrivals(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	stop, ok := h.gtfs.Stops[stopID]
+	stop, ok := snap.GTFS.Stops[stopID]
 	if !ok {
 		http.Error(w, "stop not found", http.StatusNotFound)
 		return
 	}
 
-	arrivals, err := h.kentkart.GetStopArrivals(stopID, stop.Lat, stop.Lon)
+	lang := requestLanguage(r, snap.GTFS)
+	ctx, cancel := context.WithTimeout(r.Context(), realtimeTimeout)
+	defer cancel()
+	res, err := h.realtime.Arrivals(ctx, snap.GTFS, stop, lang)
 	if err != nil {
 		log.Printf("Error fetching arrivals for stop %s: %v", stopID, err)
-		http.Error(w, "failed to fetch arrivals", http.StatusInternalServerError)
+		status, msg := upstreamError(err)
+		http.Error(w, msg, status)
 		return
 	}
 
 	json.NewEncoder(w).Encode(arrivalsResponse{
-		StopID:   stop.ID,
-		StopName: stop.Name,
-		Arrivals: arrivals,
+		StopID:    stop.ID,
+		StopName:  service.Translate(snap.GTFS, lang, "stops", "stop_name", stop.ID, stop.Name),
+		Arrivals:  res.Arrivals,
+		FetchedAt: res.FetchedAt,
+		Stale:     res.Stale,
+	})
+}
+
+// Departures response types
+
+type departuresResponse struct {
+	StopID     string                     `json:"stop_id"`
+	StopName   string                     `json:"stop_name"`
+	Date       string                     `json:"date"`
+	Time       model.GTFSTime             `json:"time"`
+	Departures []model.ScheduledDeparture `json:"departures"`
+	Count      int                        `json:"count"`
+}
+
+// Departures returns upcoming scheduled departures for a stop.
+func (h *Handler) Departures(w http.ResponseWriter, r *http.Request) {
+	snap := h.feeds.Current()
+	w.Header().Set("Content-Type", "application/json")
+
+	stopID := r.URL.Query().Get("stop_id")
+	if stopID == "" {
+		http.Error(w, "stop_id parameter required", http.StatusBadRequest)
+		return
+	}
+
+	stop, ok := snap.GTFS.Stops[stopID]
+	if !ok {
+		http.Error(w, "stop not found", http.StatusNotFound)
+		return
+	}
+
+	date, t, err := parseDateTime(r, snap.GTFS)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	limit := 20
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if limit, err = strconv.Atoi(limitStr); err != nil || limit <= 0 {
+			http.Error(w, "invalid limit parameter", http.StatusBadRequest)
+			return
+		}
+	}
+
+	departures := service.ScheduledDepartures(snap.GTFS, stopID, date, t, limit)
+	json.NewEncoder(w).Encode(departuresResponse{
+		StopID:     stop.ID,
+		StopName:   stop.Name,
+		Date:       date.Format("20060102"),
+		Time:       t,
+		Departures: departures,
+		Count:      len(departures),
+	})
+}
+
+// Transfers response types
+
+type stopTransfer struct {
+	*model.Transfer
+	ToStopName string `json:"to_stop_name"`
+}
+
+type transfersResponse struct {
+	StopID    string         `json:"stop_id"`
+	StopName  string         `json:"stop_name"`
+	Transfers []stopTransfer `json:"transfers"`
+	Count     int            `json:"count"`
+}
+
+// Transfers returns the walking transfers available from a stop.
+func (h *Handler) Transfers(w http.ResponseWriter, r *http.Request) {
+	snap := h.feeds.Current()
+	w.Header().Set("Content-Type", "application/json")
+
+	stopID := r.URL.Query().Get("stop_id")
+	if stopID == "" {
+		http.Error(w, "stop_id parameter required", http.StatusBadRequest)
+		return
+	}
+
+	stop, ok := snap.GTFS.Stops[stopID]
+	if !ok {
+		http.Error(w, "stop not found", http.StatusNotFound)
+		return
+	}
+
+	transfers := make([]stopTransfer, 0, len(snap.GTFS.Transfers[stopID]))
+	for _, t := range snap.GTFS.Transfers[stopID] {
+		st := stopTransfer{Transfer: t}
+		if to, ok := snap.GTFS.Stops[t.ToStopID]; ok {
+			st.ToStopName = to.Name
+		}
+		transfers = append(transfers, st)
+	}
+
+	json.NewEncoder(w).Encode(transfersResponse{
+		StopID:    stop.ID,
+		StopName:  stop.Name,
+		Transfers: transfers,
+		Count:     len(transfers),
 	})
 }
 
@@ -134,11 +339,19 @@ type routesResponse struct {
 
 // Routes returns all routes.
 func (h *Handler) Routes(w http.ResponseWriter, r *http.Request) {
+	snap := h.feeds.Current()
 	w.Header().Set("Content-Type", "application/json")
 
+	routes := snap.GTFS.RoutesList
+	if lang := requestLanguage(r, snap.GTFS); lang != "" {
+		routes = make([]*model.Route, len(snap.GTFS.RoutesList))
+		for i, route := range snap.GTFS.RoutesList {
+			routes[i] = service.LocalizeRoute(snap.GTFS, route, lang)
+		}
+	}
 	json.NewEncoder(w).Encode(routesResponse{
-		Routes: h.gtfs.RoutesList,
-		Count:  len(h.gtfs.RoutesList),
+		Routes: routes,
+		Count:  len(routes),
 	})
 }
 
@@ -151,6 +364,7 @@ type routeShapeResponse struct {
 
 // RouteShape returns the shape points for a route.
 func (h *Handler) RouteShape(w http.ResponseWriter, r *http.Request) {
+	snap := h.feeds.Current()
 	w.Header().Set("Content-Type", "application/json")
 
 	routeID := r.URL.Query().Get("route_id")
@@ -159,20 +373,15 @@ func (h *Handler) RouteShape(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	route, ok := h.gtfs.Routes[routeID]
+	route, ok := snap.GTFS.Routes[routeID]
 	if !ok {
 		http.Error(w, "route not found", http.StatusNotFound)
 		return
 	}
 
 	var shapePoints []model.ShapePoint
-	for _, trip := range h.gtfs.Trips {
-		if trip.RouteID == route.ID && trip.ShapeID != "" {
-			if points, ok := h.gtfs.Shapes[trip.ShapeID]; ok {
-				shapePoints = points
-				break
-			}
-		}
+	if shapeIDs := snap.GTFS.RouteShapes[route.ID]; len(shapeIDs) > 0 {
+		shapePoints = snap.GTFS.Shapes[shapeIDs[0]]
 	}
 
 	json.NewEncoder(w).Encode(routeShapeResponse{
@@ -183,12 +392,136 @@ func (h *Handler) RouteShape(w http.ResponseWriter, r *http.Request) {
 
 // Helper functions
 
-func (h *Handler) findNearbyStops(lat, lon, radiusMeters float64) []*model.Stop {
-	var nearby []*model.Stop
-	for _, stop := range h.gtfs.StopsList {
-		if geo.HaversineDistance(lat, lon, stop.Lat, stop.Lon) <= radiusMeters {
-			nearby = append(nearby, stop)
+// parseDateTime reads the date and time query parameters, defaulting to the
+// current date and time in the agency time zone.
+func parseDateTime(r *http.Request, gtfs *model.GTFSData) (time.Time, model.GTFSTime, error) {
+	loc := service.AgencyLocation(gtfs)
+	now := time.Now().In(loc)
+
+	date := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	if dateStr := r.URL.Query().Get("date"); dateStr != "" {
+		var err error
+		if date, err = service.ParseServiceDate(dateStr, loc); err != nil {
+			return time.Time{}, 0, fmt.Errorf("invalid date parameter")
 		}
 	}
+
+	t := model.GTFSTime(now.Hour()*3600 + now.Minute()*60 + now.Second())
+	if timeStr := r.URL.Query().Get("time"); timeStr != "" {
+		var err error
+		if t, err = model.ParseGTFSTime(timeStr); err != nil {
+			return time.Time{}, 0, fmt.Errorf("invalid time parameter")
+		}
+	}
+
+	return date, t, nil
+}
+
+// parseLocation reads the lat and lon query parameters, which must be valid
+// coordinates.
+func parseLocation(r *http.Request) (float64, float64, error) {
+	lat, err := strconv.ParseFloat(r.URL.Query().Get("lat"), 64)
+	if err != nil || !validCoordinates(lat, 0) {
+		return 0, 0, fmt.Errorf("invalid lat parameter")
+	}
+	lon, err := strconv.ParseFloat(r.URL.Query().Get("lon"), 64)
+	if err != nil || !validCoordinates(0, lon) {
+		return 0, 0, fmt.Errorf("invalid lon parameter")
+	}
+	return lat, lon, nil
+}
+
+// validCoordinates reports whether lat and lon lie on the globe. NaN and
+// infinities fail the comparisons and are rejected too.
+func validCoordinates(lat, lon float64) bool {
+	return lat >= -90 && lat <= 90 && lon >= -180 && lon <= 180
+}
+
+// checkCoverage rejects a location more than nearestRadius outside the
+// bounds of an index, where there is nothing to find.
+func checkCoverage(bounds geo.BBox, lat, lon float64) error {
+	if bounds.MinLat <= bounds.MaxLat && bounds.Distance(lat, lon) > nearestRadius {
+		return fmt.Errorf("location is outside the area covered by the feed")
+	}
+	return nil
+}
+
+// requestLanguage returns the language to answer in: the lang query
+// parameter if given, otherwise the most preferred language of the
+// Accept-Language header the feed has names in. "" means the feed's own
+// names.
+func requestLanguage(r *http.Request, gtfs *model.GTFSData) string {
+	if lang := r.URL.Query().Get("lang"); lang != "" {
+		return service.NormalizeLanguage(lang)
+	}
+	return service.MatchLanguage(gtfs, parseAcceptLanguage(r.Header.Get("Accept-Language")))
+}
+
+// parseAcceptLanguage returns the languages of an Accept-Language header,
+// most preferred first. Languages with q=0 are left out.
+func parseAcceptLanguage(header string) []string {
+	type pref struct {
+		lang string
+		q    float64
+	}
+	var prefs []pref
+	for _, part := range strings.Split(header, ",") {
+		lang, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if lang == "" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			var err error
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q > 0 {
+			prefs = append(prefs, pref{lang: strings.TrimSpace(lang), q: q})
+		}
+	}
+	sort.SliceStable(prefs, func(i, j int) bool { return prefs[i].q > prefs[j].q })
+
+	langs := make([]string, len(prefs))
+	for i, p := range prefs {
+		langs[i] = p.lang
+	}
+	return langs
+}
+
+// upstreamError maps a Kentkart failure to a response status and message:
+// 504 when it timed out, 503 when it is down or the circuit breaker is
+// open, and 502 when it answered with something unusable. Messages are
+// fixed: what the upstream said, such as a Kentkart result message, is only
+// logged by the caller.
+func upstreamError(err error) (int, string) {
+	switch {
+	case errors.Is(err, service.ErrRealtimeTimeout):
+		return http.StatusGatewayTimeout, "real-time service timed out"
+	case errors.Is(err, service.ErrRealtimeUnavailable):
+		return http.StatusServiceUnavailable, "real-time service unavailable"
+	case errors.Is(err, service.ErrRealtimeBadPayload):
+		return http.StatusBadGateway, "invalid response from real-time service"
+	case errors.Is(err, service.ErrRealtimeUnsupported):
+		return http.StatusNotImplemented, "not available from this agency's real-time provider"
+	}
+	return http.StatusInternalServerError, "failed to fetch real-time data"
+}
+
+func findNearbyStops(gtfs *model.GTFSData, lat, lon, radiusMeters float64) []nearbyStop {
+	results := gtfs.StopIndex.Radius(lat, lon, radiusMeters)
+	nearby := make([]nearbyStop, 0, len(results))
+	for _, res := range results {
+		nearby = append(nearby, newNearbyStop(lat, lon, res))
+	}
 	return nearby
 }
+
+func newNearbyStop(lat, lon float64, res spatial.Result[*model.Stop]) nearbyStop {
+	return nearbyStop{
+		Stop:     res.Item,
+		Distance: math.Round(res.Distance*10) / 10,
+		Bearing:  math.Round(geo.Bearing(lat, lon, res.Item.Lat, res.Item.Lon)*10) / 10,
+	}
+}
@@ -0,0 +1,221 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rfurkan37/transport-app/backend/internal/feed"
+	"github.com/rfurkan37/transport-app/backend/internal/gtfstest"
+	"github.com/rfurkan37/transport-app/backend/internal/kentkartfake"
+	"github.com/rfurkan37/transport-app/backend/internal/model"
+	"github.com/rfurkan37/transport-app/backend/internal/router"
+	"github.com/rfurkan37/transport-app/backend/internal/service"
+)
+
+// newRealtimeHandler serves a five-stop route 80 with arrivals and
+// vehicles from a fake Kentkart. Cached answers are never fresh, so every
+// request reaches the fake, but they can be served as stale for a minute.
+func newRealtimeHandler(t *testing.T, fake *kentkartfake.Server) *Handler {
+	t.Helper()
+	f := gtfstest.Base()
+	f["stops"] = "stop_id,stop_name,stop_lat,stop_lon\n"
+	f["stop_times"] = "trip_id,arrival_time,departure_time,stop_id,stop_sequence\n"
+	for i := 1; i <= 5; i++ {
+		f["stops"] += fmt.Sprintf("S%d,Stop %d,40.76,%.2f\n", i, i, 29.90+float64(i)/100)
+		f["stop_times"] += fmt.Sprintf("T1,08:0%d:00,08:0%d:00,S%d,%d\n", i, i, i, i)
+	}
+	f["routes"] = "route_id,agency_id,route_short_name,route_type\nR80,A,80,3\n"
+	f["trips"] = "route_id,service_id,trip_id\nR80,ALL,T1\n"
+
+	feeds := feed.NewManager(gtfstest.Write(t, f), service.DefaultLoadOptions(), router.DefaultOptions())
+	if _, err := feeds.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	client := service.NewKentkartClient(srv.URL, "")
+	providers := map[string]service.RealtimeProvider{
+		service.ProviderKentkart: {Arrivals: client, Vehicles: client},
+		service.ProviderSchedule: {Arrivals: service.ScheduleProvider{}},
+	}
+	arrivals := service.NewLiveCache[[]model.StopArrival]("test_arrivals", time.Nanosecond, time.Minute)
+	vehicles := service.NewLiveCache[[]model.Vehicle]("test_vehicles", time.Nanosecond, time.Minute)
+	realtime, err := service.NewRealtime(service.ProviderKentkart, providers, arrivals, vehicles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return New(feeds, realtime)
+}
+
+// realtimeEndpoints are the endpoints answered from Kentkart, by path.
+var realtimeEndpoints = map[string]func(*Handler) http.HandlerFunc{
+	"/stops/arrivals": func(h *Handler) http.HandlerFunc { return h.Arrivals },
+	"/vehicles":       func(h *Handler) http.HandlerFunc { return h.Vehicles },
+}
+
+// realtimeResult is the part of arrivals and vehicles responses the tests
+// look at.
+type realtimeResult struct {
+	Arrivals []json.RawMessage `json:"arrivals"`
+	Vehicles []json.RawMessage `json:"vehicles"`
+	Stale    bool              `json:"stale"`
+}
+
+func (r realtimeResult) count() int { return len(r.Arrivals) + len(r.Vehicles) }
+
+// get requests stop_id=stopID from an endpoint, giving up after timeout.
+func get(h *Handler, path, stopID string, timeout time.Duration) *httptest.ResponseRecorder {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	req := httptest.NewRequestWithContext(ctx, "GET", path+"?stop_id="+stopID, nil)
+	rec := httptest.NewRecorder()
+	realtimeEndpoints[path](h)(rec, req)
+	return rec
+}
+
+// busAt80 is a Kentkart answer with one bus and one arrival of route 80.
+var busAt80 = kentkartfake.Response{Payload: &kentkartfake.NearestBus{
+	BusList:   []kentkartfake.Bus{{BusID: "B1", RouteCode: "80", Lat: "40.76", Lng: "29.95", ArrivalTime: "3"}},
+	RouteList: []kentkartfake.Route{{RouteCode: "80", DisplayRouteCode: "80", StopArrivalTime: "3"}},
+}}
+
+func TestRealtimeUpstreamErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		response   kentkartfake.Response
+		timeout    time.Duration
+		wantStatus int
+	}{
+		{"answer", busAt80, time.Second, http.StatusOK},
+		{"result code", kentkartfake.ResultCode(3, "internal detail"), time.Second, http.StatusBadGateway},
+		{"unavailable", kentkartfake.HTTPError(http.StatusServiceUnavailable), 5 * time.Second, http.StatusServiceUnavailable},
+		{"slow", kentkartfake.Slow(300*time.Millisecond, busAt80), 100 * time.Millisecond, http.StatusGatewayTimeout},
+		{"malformed", kentkartfake.Malformed(), time.Second, http.StatusBadGateway},
+	}
+	for path := range realtimeEndpoints {
+		for _, tt := range tests {
+			t.Run(path+"/"+tt.name, func(t *testing.T) {
+				fake := kentkartfake.New()
+				fake.SetDefault(tt.response)
+				h := newRealtimeHandler(t, fake)
+
+				rec := get(h, path, "S1", tt.timeout)
+				if rec.Code != tt.wantStatus {
+					t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.wantStatus, rec.Body)
+				}
+				if strings.Contains(rec.Body.String(), "internal detail") {
+					t.Errorf("response leaks the upstream message: %s", rec.Body)
+				}
+				if rec.Code != http.StatusOK {
+					return
+				}
+				var res realtimeResult
+				if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+					t.Fatal(err)
+				}
+				if res.count() != 1 || res.Stale {
+					t.Errorf("got %d results, stale %v; want 1 fresh", res.count(), res.Stale)
+				}
+			})
+		}
+	}
+}
+
+func TestRealtimeBreakerOpen(t *testing.T) {
+	for path := range realtimeEndpoints {
+		t.Run(path, func(t *testing.T) {
+			fake := kentkartfake.New()
+			fake.SetDefault(kentkartfake.HTTPError(http.StatusInternalServerError))
+			h := newRealtimeHandler(t, fake)
+
+			// Five failing stops at once open the breaker.
+			var wg sync.WaitGroup
+			for i := 1; i <= 5; i++ {
+				wg.Go(func() { get(h, path, fmt.Sprintf("S%d", i), 5*time.Second) })
+			}
+			wg.Wait()
+
+			fake.SetDefault(busAt80)
+			sent := len(fake.Requests())
+			if rec := get(h, path, "S1", time.Second); rec.Code != http.StatusServiceUnavailable {
+				t.Errorf("status = %d, want %d", rec.Code, http.StatusServiceUnavailable)
+			}
+			if n := len(fake.Requests()); n != sent {
+				t.Errorf("open breaker let %d requests through", n-sent)
+			}
+		})
+	}
+}
+
+func TestRealtimeServesStaleOnFailedRefresh(t *testing.T) {
+	for path := range realtimeEndpoints {
+		t.Run(path, func(t *testing.T) {
+			fake := kentkartfake.New()
+			fake.Script("S1", busAt80, kentkartfake.ResultCode(3, "stop unknown"))
+			h := newRealtimeHandler(t, fake)
+
+			for i, wantStale := range []bool{false, true} {
+				rec := get(h, path, "S1", time.Second)
+				if rec.Code != http.StatusOK {
+					t.Fatalf("request %d: status = %d (%s)", i, rec.Code, rec.Body)
+				}
+				var res realtimeResult
+				if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+					t.Fatal(err)
+				}
+				if res.count() != 1 || res.Stale != wantStale {
+					t.Errorf("request %d: got %d results, stale %v; want 1, stale %v", i, res.count(), res.Stale, wantStale)
+				}
+			}
+		})
+	}
+}
+
+func TestStopsRadius(t *testing.T) {
+	h := newRealtimeHandler(t, kentkartfake.New())
+
+	tests := []struct {
+		radius     string
+		wantStatus int
+		wantCount  int
+	}{
+		{"", http.StatusOK, 1}, // default 500 m
+		{"1000", http.StatusOK, 2},
+		{"20000", http.StatusOK, 5},
+		{"20001", http.StatusBadRequest, 0},
+		{"0", http.StatusBadRequest, 0},
+		{"-500", http.StatusBadRequest, 0},
+		{"NaN", http.StatusBadRequest, 0},
+		{"Inf", http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.radius, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/stops?lat=40.76&lon=29.91&radius="+tt.radius, nil)
+			rec := httptest.NewRecorder()
+			h.Stops(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if rec.Code != http.StatusOK {
+				return
+			}
+			var res struct {
+				Count int `json:"count"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+				t.Fatal(err)
+			}
+			if res.Count != tt.wantCount {
+				t.Errorf("%d stops, want %d", res.Count, tt.wantCount)
+			}
+		})
+	}
+}
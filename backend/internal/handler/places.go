@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/rfurkan37/transport-app/backend/internal/geo"
+	"github.com/rfurkan37/transport-app/backend/internal/model"
+)
+
+// Places response types
+
+type placesResponse struct {
+	Places []*model.Place `json:"places"`
+	Count  int            `json:"count"`
+}
+
+// Places returns all card top-up places, or those inside a bbox if one is
+// provided.
+func (h *Handler) Places(w http.ResponseWriter, r *http.Request) {
+	snap := h.feeds.Current()
+	w.Header().Set("Content-Type", "application/json")
+
+	places := snap.GTFS.PlacesList
+	if bboxStr := r.URL.Query().Get("bbox"); bboxStr != "" {
+		bbox, err := parseBBox(bboxStr)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		results := snap.GTFS.PlaceIndex.BBox(bbox.MinLat, bbox.MinLon, bbox.MaxLat, bbox.MaxLon)
+		places = make([]*model.Place, 0, len(results))
+		for _, res := range results {
+			places = append(places, res.Item)
+		}
+	}
+
+	json.NewEncoder(w).Encode(placesResponse{
+		Places: places,
+		Count:  len(places),
+	})
+}
+
+type nearbyPlace struct {
+	*model.Place
+	Distance float64 `json:"distance_m"`
+	Bearing  float64 `json:"bearing"`
+}
+
+type nearbyPlacesResponse struct {
+	Places []nearbyPlace `json:"places"`
+	Count  int           `json:"count"`
+}
+
+// NearestPlaces returns the k card top-up places closest to a location.
+func (h *Handler) NearestPlaces(w http.ResponseWriter, r *http.Request) {
+	snap := h.feeds.Current()
+	w.Header().Set("Content-Type", "application/json")
+
+	lat, lon, err := parseLocation(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := checkCoverage(snap.GTFS.PlaceIndex.Bounds(), lat, lon); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	k := 5
+	if kStr := r.URL.Query().Get("k"); kStr != "" {
+		if k, err = strconv.Atoi(kStr); err != nil || k <= 0 || k > 100 {
+			http.Error(w, "invalid k parameter", http.StatusBadRequest)
+			return
+		}
+	}
+
+	results := snap.GTFS.PlaceIndex.Nearest(lat, lon, k, nearestRadius)
+	nearest := make([]nearbyPlace, 0, len(results))
+	for _, res := range results {
+		nearest = append(nearest, nearbyPlace{
+			Place:    res.Item,
+			Distance: math.Round(res.Distance*10) / 10,
+			Bearing:  math.Round(geo.Bearing(lat, lon, res.Item.Lat, res.Item.Lon)*10) / 10,
+		})
+	}
+	json.NewEncoder(w).Encode(nearbyPlacesResponse{
+		Places: nearest,
+		Count:  len(nearest),
+	})
+}
@@ -0,0 +1,238 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/rfurkan37/transport-app/backend/internal/model"
+	"github.com/rfurkan37/transport-app/backend/internal/router"
+)
+
+// Route response types
+
+type coordinate struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+type routeResponse struct {
+	From     coordinate      `json:"from"`
+	To       coordinate      `json:"to"`
+	Date     string          `json:"date"`
+	Time     model.GTFSTime  `json:"time"`
+	ArriveBy bool            `json:"arrive_by"`
+	Journeys []model.Journey `json:"journeys"`
+}
+
+// Route plans a journey between two coordinates. With mode=pareto it returns
+// every journey that trades off arrival time, transfers and walking; with
+// arrive_by=true the time is the latest arrival and the journey leaving
+// as late as possible is returned.
+func (h *Handler) Route(w http.ResponseWriter, r *http.Request) {
+	snap := h.feeds.Current()
+	w.Header().Set("Content-Type", "application/json")
+
+	q, err := parseRouteQuery(r, snap.GTFS)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := snap.Planner.CheckQuery(q); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	arriveBy := false
+	if s := r.URL.Query().Get("arrive_by"); s != "" {
+		if arriveBy, err = strconv.ParseBool(s); err != nil {
+			http.Error(w, "invalid arrive_by parameter", http.StatusBadRequest)
+			return
+		}
+	}
+
+	journeys := []model.Journey{}
+	switch mode := r.URL.Query().Get("mode"); {
+	case arriveBy && (mode == "" || mode == "fastest"):
+		if journey, ok := snap.Planner.LatestDeparture(q); ok {
+			journeys = append(journeys, journey)
+		}
+	case arriveBy:
+		http.Error(w, "arrive_by is only supported with mode=fastest", http.StatusBadRequest)
+		return
+	case mode == "" || mode == "fastest":
+		if journey, ok := snap.Planner.EarliestArrival(q); ok {
+			journeys = append(journeys, journey)
+		}
+	case mode == "pareto":
+		journeys = append(journeys, snap.Planner.Pareto(q)...)
+	default:
+		http.Error(w, "invalid mode parameter", http.StatusBadRequest)
+		return
+	}
+
+	json.NewEncoder(w).Encode(routeResponse{
+		From:     coordinate{Lat: q.FromLat, Lon: q.FromLon},
+		To:       coordinate{Lat: q.ToLat, Lon: q.ToLon},
+		Date:     q.Date.Format("20060102"),
+		Time:     q.Time,
+		ArriveBy: arriveBy,
+		Journeys: journeys,
+	})
+}
+
+// parseRouteQuery reads the from, to, date and time query parameters.
+func parseRouteQuery(r *http.Request, gtfs *model.GTFSData) (router.Query, error) {
+	var q router.Query
+	var err error
+
+	if q.FromLat, q.FromLon, err = parseLatLon(r.URL.Query().Get("from")); err != nil {
+		return q, fmt.Errorf("invalid from parameter")
+	}
+	if q.ToLat, q.ToLon, err = parseLatLon(r.URL.Query().Get("to")); err != nil {
+		return q, fmt.Errorf("invalid to parameter")
+	}
+	if q.Date, q.Time, err = parseDateTime(r, gtfs); err != nil {
+		return q, err
+	}
+	return q, nil
+}
+
+// parseLatLon parses a "LAT,LON" pair of valid coordinates.
+func parseLatLon(s string) (float64, float64, error) {
+	latStr, lonStr, ok := strings.Cut(s, ",")
+	if !ok {
+		return 0, 0, fmt.Errorf("expected LAT,LON")
+	}
+	lat, err := strconv.ParseFloat(strings.TrimSpace(latStr), 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	lon, err := strconv.ParseFloat(strings.TrimSpace(lonStr), 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	if !validCoordinates(lat, lon) {
+		return 0, 0, fmt.Errorf("coordinates out of range")
+	}
+	return lat, lon, nil
+}
+
+// RouteProfile response types
+
+type routeProfileResponse struct {
+	From     coordinate      `json:"from"`
+	To       coordinate      `json:"to"`
+	Date     string          `json:"date"`
+	FromTime model.GTFSTime  `json:"from_time"`
+	ToTime   model.GTFSTime  `json:"to_time"`
+	Journeys []model.Journey `json:"journeys"`
+	Count    int             `json:"count"`
+}
+
+// maxProfileWindow is the longest departure window /route/profile searches,
+// as each departure in it is a search of its own.
+const maxProfileWindow = 3 * 3600 // seconds
+
+// RouteProfile returns every optimal journey leaving within a time window.
+func (h *Handler) RouteProfile(w http.ResponseWriter, r *http.Request) {
+	snap := h.feeds.Current()
+	w.Header().Set("Content-Type", "application/json")
+
+	q, err := parseRouteQuery(r, snap.GTFS)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := snap.Planner.CheckQuery(q); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if s := r.URL.Query().Get("from_time"); s != "" {
+		if q.Time, err = model.ParseGTFSTime(s); err != nil {
+			http.Error(w, "invalid from_time parameter", http.StatusBadRequest)
+			return
+		}
+	}
+	until := q.Time + 3600 // default one hour window
+	if s := r.URL.Query().Get("to_time"); s != "" {
+		if until, err = model.ParseGTFSTime(s); err != nil || until < q.Time || until-q.Time > maxProfileWindow {
+			http.Error(w, "invalid to_time parameter", http.StatusBadRequest)
+			return
+		}
+	}
+
+	journeys := snap.Planner.Profile(q, until)
+	if journeys == nil {
+		journeys = []model.Journey{}
+	}
+
+	json.NewEncoder(w).Encode(routeProfileResponse{
+		From:     coordinate{Lat: q.FromLat, Lon: q.FromLon},
+		To:       coordinate{Lat: q.ToLat, Lon: q.ToLon},
+		Date:     q.Date.Format("20060102"),
+		FromTime: q.Time,
+		ToTime:   until,
+		Journeys: journeys,
+		Count:    len(journeys),
+	})
+}
+
+// Isochrone response types
+
+type isochroneResponse struct {
+	Origin coordinate     `json:"origin"`
+	Date   string         `json:"date"`
+	Time   model.GTFSTime `json:"time"`
+	model.Isochrone
+	Count int `json:"count"`
+}
+
+// Isochrone returns the stops and area reachable from a point by transit
+// and walking within one or more time bands given in minutes.
+func (h *Handler) Isochrone(w http.ResponseWriter, r *http.Request) {
+	snap := h.feeds.Current()
+	w.Header().Set("Content-Type", "application/json")
+
+	lat, lon, err := parseLocation(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !snap.Planner.NearStops(lat, lon) {
+		http.Error(w, router.ErrNoStopsNearOrigin.Error(), http.StatusBadRequest)
+		return
+	}
+
+	date, t, err := parseDateTime(r, snap.GTFS)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	bands := []int{15, 30, 45}
+	if s := r.URL.Query().Get("minutes"); s != "" {
+		bands = bands[:0]
+		for _, part := range strings.Split(s, ",") {
+			m, err := strconv.Atoi(strings.TrimSpace(part))
+			if err != nil || m <= 0 || m > 180 {
+				http.Error(w, "invalid minutes parameter", http.StatusBadRequest)
+				return
+			}
+			bands = append(bands, m)
+		}
+	}
+
+	iso := snap.Planner.Isochrone(router.Query{FromLat: lat, FromLon: lon, Date: date, Time: t}, bands)
+	json.NewEncoder(w).Encode(isochroneResponse{
+		Origin:    coordinate{Lat: lat, Lon: lon},
+		Date:      date.Format("20060102"),
+		Time:      t,
+		Isochrone: iso,
+		Count:     len(iso.Stops),
+	})
+}
@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rfurkan37/transport-app/backend/internal/kentkartfake"
+)
+
+func TestRouteProfileWindow(t *testing.T) {
+	h := newRealtimeHandler(t, kentkartfake.New())
+
+	tests := []struct {
+		name       string
+		window     string
+		wantStatus int
+	}{
+		{"default hour", "from_time=08:00:00", http.StatusOK},
+		{"three hours", "from_time=08:00:00&to_time=11:00:00", http.StatusOK},
+		{"longer than three hours", "from_time=08:00:00&to_time=11:00:01", http.StatusBadRequest},
+		{"whole service day", "from_time=00:00:00&to_time=47:59:59", http.StatusBadRequest},
+		{"ends before it starts", "from_time=08:00:00&to_time=07:59:59", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/route/profile?from=40.76,29.91&to=40.76,29.95&date=20261016&"+tt.window, nil)
+			rec := httptest.NewRecorder()
+			h.RouteProfile(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d (%s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+		})
+	}
+}
+
+func TestParseLatLon(t *testing.T) {
+	tests := []struct {
+		in       string
+		lat, lon float64
+		wantErr  bool
+	}{
+		{"40.76,29.91", 40.76, 29.91, false},
+		{" 40.76 , 29.91 ", 40.76, 29.91, false},
+		{"-90,180", -90, 180, false},
+		{"40.76", 0, 0, true},
+		{"north,29.91", 0, 0, true},
+		{"NaN,NaN", 0, 0, true},
+		{"Inf,29.91", 0, 0, true},
+		{"40.76,-Inf", 0, 0, true},
+		{"91,29.91", 0, 0, true},
+		{"40.76,180.5", 0, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			lat, lon, err := parseLatLon(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseLatLon(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+			}
+			if !tt.wantErr && (lat != tt.lat || lon != tt.lon) {
+				t.Errorf("parseLatLon(%q) = %v, %v; want %v, %v", tt.in, lat, lon, tt.lat, tt.lon)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/rfurkan37/transport-app/backend/internal/model"
+	"github.com/rfurkan37/transport-app/backend/internal/service"
+)
+
+// Search response types
+
+type searchResponse struct {
+	Query  string         `json:"query"`
+	Lang   string         `json:"lang,omitempty"`
+	Stops  []*model.Stop  `json:"stops"`
+	Routes []*model.Route `json:"routes"`
+	Count  int            `json:"count"`
+}
+
+// Search finds stops and routes by name, in the feed's names or their
+// translations.
+func (h *Handler) Search(w http.ResponseWriter, r *http.Request) {
+	snap := h.feeds.Current()
+	w.Header().Set("Content-Type", "application/json")
+
+	query := r.URL.Query().Get("q")
+	if query == "" {
+		http.Error(w, "q parameter required", http.StatusBadRequest)
+		return
+	}
+
+	limit := 20
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		var err error
+		if limit, err = strconv.Atoi(limitStr); err != nil || limit <= 0 || limit > 100 {
+			http.Error(w, "invalid limit parameter", http.StatusBadRequest)
+			return
+		}
+	}
+
+	lang := requestLanguage(r, snap.GTFS)
+	results := service.Search(snap.GTFS, query, lang, limit)
+	json.NewEncoder(w).Encode(searchResponse{
+		Query:  query,
+		Lang:   lang,
+		Stops:  results.Stops,
+		Routes: results.Routes,
+		Count:  len(results.Stops) + len(results.Routes),
+	})
+}
@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"github.com/rfurkan37/transport-app/backend/internal/model"
+	"github.com/rfurkan37/transport-app/backend/internal/service"
+)
+
+// Station response types
+
+type stationChild struct {
+	*model.Stop
+	BoardingAreas []*model.Stop `json:"boarding_areas,omitempty"`
+	RouteIDs      []string      `json:"route_ids,omitempty"`
+}
+
+type stationResponse struct {
+	Station   *model.Stop      `json:"station"`
+	Platforms []stationChild   `json:"platforms"`
+	Entrances []stationChild   `json:"entrances"`
+	Nodes     []stationChild   `json:"nodes"`
+	Levels    []*model.Level   `json:"levels"`
+	Pathways  []*model.Pathway `json:"pathways"`
+}
+
+// Station returns a station with its platforms, entrances and the pathways
+// and levels inside it.
+func (h *Handler) Station(w http.ResponseWriter, r *http.Request) {
+	snap := h.feeds.Current()
+	w.Header().Set("Content-Type", "application/json")
+
+	station, ok := snap.GTFS.Stops[r.PathValue("id")]
+	if !ok || station.LocationType != model.LocationStation {
+		http.Error(w, "station not found", http.StatusNotFound)
+		return
+	}
+	lang := requestLanguage(r, snap.GTFS)
+
+	resp := stationResponse{
+		Station:   service.LocalizeStop(snap.GTFS, station, lang),
+		Platforms: []stationChild{},
+		Entrances: []stationChild{},
+		Nodes:     []stationChild{},
+		Levels:    []*model.Level{},
+		Pathways:  []*model.Pathway{},
+	}
+
+	levels := make(map[string]bool)
+	pathways := make(map[string]bool)
+	addLocation := func(stop *model.Stop) {
+		if stop.LevelID != "" {
+			levels[stop.LevelID] = true
+		}
+		for _, p := range snap.GTFS.PathwaysByStop[stop.ID] {
+			pathways[p.ID] = true
+		}
+	}
+	addLocation(station)
+
+	for _, id := range snap.GTFS.StopChildren[station.ID] {
+		stop := snap.GTFS.Stops[id]
+		addLocation(stop)
+		child := stationChild{Stop: service.LocalizeStop(snap.GTFS, stop, lang)}
+		for _, areaID := range snap.GTFS.StopChildren[id] {
+			area := snap.GTFS.Stops[areaID]
+			addLocation(area)
+			child.BoardingAreas = append(child.BoardingAreas, service.LocalizeStop(snap.GTFS, area, lang))
+		}
+
+		switch stop.LocationType {
+		case model.LocationStop:
+			child.RouteIDs = stopRouteIDs(snap.GTFS, id)
+			resp.Platforms = append(resp.Platforms, child)
+		case model.LocationEntrance:
+			resp.Entrances = append(resp.Entrances, child)
+		case model.LocationGenericNode:
+			resp.Nodes = append(resp.Nodes, child)
+		}
+	}
+
+	for id := range levels {
+		if level, ok := snap.GTFS.Levels[id]; ok {
+			resp.Levels = append(resp.Levels, level)
+		}
+	}
+	sort.Slice(resp.Levels, func(i, j int) bool { return resp.Levels[i].Index < resp.Levels[j].Index })
+	for id := range pathways {
+		resp.Pathways = append(resp.Pathways, snap.GTFS.Pathways[id])
+	}
+	sort.Slice(resp.Pathways, func(i, j int) bool { return resp.Pathways[i].ID < resp.Pathways[j].ID })
+
+	json.NewEncoder(w).Encode(resp)
+}
+
+// stopRouteIDs returns the routes whose trips call at a stop.
+func stopRouteIDs(gtfs *model.GTFSData, stopID string) []string {
+	seen := make(map[string]bool)
+	for _, st := range gtfs.StopTimesByStop[stopID] {
+		if trip, ok := gtfs.Trips[st.TripID]; ok {
+			seen[trip.RouteID] = true
+		}
+	}
+	ids := make([]string, 0, len(seen))
+	for id := range seen {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/rfurkan37/transport-app/backend/internal/model"
+	"github.com/rfurkan37/transport-app/backend/internal/service"
+)
+
+// Vehicles response types
+
+type vehiclesResponse struct {
+	StopID    string          `json:"stop_id,omitempty"`
+	RouteID   string          `json:"route_id,omitempty"`
+	Vehicles  []model.Vehicle `json:"vehicles"`
+	Count     int             `json:"count"`
+	FetchedAt time.Time       `json:"fetched_at"`
+	Stale     bool            `json:"stale"`             // upstream unavailable; positions may be outdated
+	Partial   bool            `json:"partial,omitempty"` // some upstreams failed; their buses are missing
+}
+
+// Vehicles returns the live positions of the buses heading to a stop, or
+// running a route, or running a route towards a stop. With a stop, each
+// bus comes with its distance to the stop, nearest first. A route's buses
+// are best effort with providers that only list buses by stop, such as
+// Kentkart: those approaching a few stops along the route, which can miss
+// some.
+func (h *Handler) Vehicles(w http.ResponseWriter, r *http.Request) {
+	snap := h.feeds.Current()
+	w.Header().Set("Content-Type", "application/json")
+
+	stopID := r.URL.Query().Get("stop_id")
+	routeID := r.URL.Query().Get("route_id")
+	if stopID == "" && routeID == "" {
+		http.Error(w, "stop_id or route_id parameter required", http.StatusBadRequest)
+		return
+	}
+	if _, ok := snap.GTFS.Stops[stopID]; stopID != "" && !ok {
+		http.Error(w, "stop not found", http.StatusNotFound)
+		return
+	}
+	if _, ok := snap.GTFS.Routes[routeID]; routeID != "" && !ok {
+		http.Error(w, "route not found", http.StatusNotFound)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), realtimeTimeout)
+	defer cancel()
+	res, err := h.realtime.Vehicles(ctx, service.VehiclesQuery{Data: snap.GTFS, StopID: stopID, RouteID: routeID})
+	if err != nil {
+		log.Printf("Error fetching vehicles for stop %q route %q: %v", stopID, routeID, err)
+		status, msg := upstreamError(err)
+		http.Error(w, msg, status)
+		return
+	}
+
+	json.NewEncoder(w).Encode(vehiclesResponse{
+		StopID:    stopID,
+		RouteID:   routeID,
+		Vehicles:  res.Vehicles,
+		Count:     len(res.Vehicles),
+		FetchedAt: res.FetchedAt,
+		Stale:     res.Stale,
+		Partial:   res.Partial,
+	})
+}
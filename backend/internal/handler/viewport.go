@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/rfurkan37/transport-app/backend/internal/geo"
+	"github.com/rfurkan37/transport-app/backend/internal/model"
+)
+
+// Shapes returns the geometry of every route crossing a bbox, clipped to it,
+// as a GeoJSON FeatureCollection with one feature per route shape.
+func (h *Handler) Shapes(w http.ResponseWriter, r *http.Request) {
+	snap := h.feeds.Current()
+	w.Header().Set("Content-Type", "application/json")
+
+	bboxStr := r.URL.Query().Get("bbox")
+	if bboxStr == "" {
+		http.Error(w, "bbox parameter required", http.StatusBadRequest)
+		return
+	}
+	bbox, err := parseBBox(bboxStr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	collection := model.FeatureCollection{Type: "FeatureCollection", Features: []model.Feature{}}
+	for _, route := range snap.GTFS.RoutesList {
+		for _, shapeID := range snap.GTFS.RouteShapes[route.ID] {
+			if !bbox.Intersects(snap.GTFS.ShapeBounds[shapeID]) {
+				continue
+			}
+
+			points := snap.GTFS.Shapes[shapeID]
+			coords := make([][2]float64, len(points))
+			for i, p := range points {
+				coords[i] = [2]float64{p.Lon, p.Lat}
+			}
+			parts := geo.ClipLineString(coords, bbox)
+			if len(parts) == 0 {
+				continue
+			}
+
+			collection.Features = append(collection.Features, model.Feature{
+				Type:     "Feature",
+				Geometry: model.Geometry{Type: "MultiLineString", Coordinates: parts},
+				Properties: map[string]any{
+					"route_id":         route.ID,
+					"shape_id":         shapeID,
+					"route_short_name": route.ShortName,
+					"route_color":      route.Color,
+					"route_type":       route.Type,
+				},
+			})
+		}
+	}
+
+	json.NewEncoder(w).Encode(collection)
+}
+
+// parseBBox parses a "minLon,minLat,maxLon,maxLat" bounding box of valid
+// coordinates.
+func parseBBox(s string) (geo.BBox, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return geo.BBox{}, fmt.Errorf("invalid bbox parameter")
+	}
+
+	var v [4]float64
+	for i, part := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return geo.BBox{}, fmt.Errorf("invalid bbox parameter")
+		}
+		v[i] = f
+	}
+
+	bbox := geo.BBox{MinLon: v[0], MinLat: v[1], MaxLon: v[2], MaxLat: v[3]}
+	if !validCoordinates(bbox.MinLat, bbox.MinLon) || !validCoordinates(bbox.MaxLat, bbox.MaxLon) ||
+		bbox.MinLon > bbox.MaxLon || bbox.MinLat > bbox.MaxLat {
+		return geo.BBox{}, fmt.Errorf("invalid bbox parameter")
+	}
+	return bbox, nil
+}
@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/rfurkan37/transport-app/backend/internal/geo"
+)
+
+func TestParseBBox(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    geo.BBox
+		wantErr bool
+	}{
+		{"29.9,40.7,30.0,40.8", geo.BBox{MinLon: 29.9, MinLat: 40.7, MaxLon: 30.0, MaxLat: 40.8}, false},
+		{" 29.9, 40.7 ,30,40.8", geo.BBox{MinLon: 29.9, MinLat: 40.7, MaxLon: 30, MaxLat: 40.8}, false},
+		{"29.9,40.7,29.9,40.7", geo.BBox{MinLon: 29.9, MinLat: 40.7, MaxLon: 29.9, MaxLat: 40.7}, false},
+		{"30.0,40.7,29.9,40.8", geo.BBox{}, true}, // min and max swapped
+		{"29.9,40.8,30.0,40.7", geo.BBox{}, true},
+		{"29.9,40.7,30.0", geo.BBox{}, true},
+		{"29.9,40.7,30.0,north", geo.BBox{}, true},
+		{"", geo.BBox{}, true},
+		{"NaN,NaN,NaN,NaN", geo.BBox{}, true},
+		{"29.9,NaN,30.0,40.8", geo.BBox{}, true},
+		{"-Inf,-Inf,Inf,Inf", geo.BBox{}, true},
+		{"29.9,-91,30.0,40.8", geo.BBox{}, true},  // latitude out of range
+		{"-181,40.7,30.0,40.8", geo.BBox{}, true}, // longitude out of range
+		{"-180,-90,180,90", geo.BBox{MinLon: -180, MinLat: -90, MaxLon: 180, MaxLat: 90}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseBBox(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseBBox(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseBBox(%q) = %+v, want %+v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,241 @@
+// Package kentkartfake is a stand-in for the Kentkart API, for exercising
+// the arrivals and vehicles endpoints without reaching service.kentkart.com.
+// It answers nearest/bus requests with recorded or scripted payloads, and
+// can be told to fail with result codes, HTTP errors, slow responses or
+// malformed JSON.
+//
+// Point the server at it with KENTKART_BASE_URL, or use it in-process:
+//
+//	fake := kentkartfake.New()
+//	fake.Script("80192", kentkartfake.Arrivals(kentkartfake.Route{DisplayRouteCode: "80", StopArrivalTime: "5"}))
+//	srv := httptest.NewServer(fake)
+//	client := service.NewKentkartClient(srv.URL, "")
+package kentkartfake
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Region the fake answers for unless told otherwise.
+const DefaultRegion = "004"
+
+// Kentkart nearest/bus payload types
+
+// NearestBus is a nearest/bus response.
+type NearestBus struct {
+	Result    Result   `json:"result"`
+	StopInfo  StopInfo `json:"stopInfo"`
+	BusList   []Bus    `json:"busList"`
+	RouteList []Route  `json:"routeList"`
+}
+
+// Result reports success (code 0) or the reason for a failure.
+type Result struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// StopInfo describes the stop asked about.
+type StopInfo struct {
+	BusStopID   string `json:"busStopid"`
+	BusStopName string `json:"busStopName"`
+	Lat         string `json:"lat"`
+	Lng         string `json:"lng"`
+}
+
+// Bus is a vehicle on its way to the stop.
+type Bus struct {
+	BusID       string `json:"busId"`
+	RouteCode   string `json:"routeCode"`
+	Lat         string `json:"lat"`
+	Lng         string `json:"lng"`
+	ArrivalTime string `json:"arrivalTime"`
+}
+
+// Route is a route calling at the stop with its next arrival.
+type Route struct {
+	RouteCode           string `json:"routeCode"`
+	DisplayRouteCode    string `json:"displayRouteCode"`
+	Name                string `json:"name"`
+	HeadSign            string `json:"headSign"`
+	RouteColor          string `json:"routeColor"`
+	Direction           string `json:"direction"`
+	RouteType           string `json:"routeType"`
+	RouteTextColor      string `json:"routeTextColor"`
+	StopArrivalTime     string `json:"stopArrivalTime"`
+	NextTripArrivalTime string `json:"nextTripArrivalTime"`
+}
+
+// Response is one scripted answer. Body, when set, is sent as it is, so it
+// can hold a recording or malformed JSON; otherwise Payload is encoded.
+type Response struct {
+	Status  int           // HTTP status, 200 if zero
+	Delay   time.Duration // wait before answering, cut short if the client gives up
+	Body    []byte
+	Payload *NearestBus
+}
+
+// Arrivals answers with the given routes.
+func Arrivals(routes ...Route) Response {
+	return Response{Payload: &NearestBus{RouteList: routes}}
+}
+
+// ResultCode answers with a failure result code, as Kentkart does for
+// unknown stops or regions.
+func ResultCode(code int, message string) Response {
+	return Response{Payload: &NearestBus{Result: Result{Code: code, Message: message}}}
+}
+
+// HTTPError answers with an HTTP error status.
+func HTTPError(status int) Response {
+	return Response{Status: status, Body: []byte(http.StatusText(status))}
+}
+
+// Malformed answers 200 with a body that is not JSON.
+func Malformed() Response {
+	return Response{Body: []byte("<html><body>Service Unavailable</body></html>")}
+}
+
+// Slow delays a response.
+func Slow(delay time.Duration, r Response) Response {
+	r.Delay = delay
+	return r
+}
+
+// Server is a fake Kentkart API. It is safe for concurrent use.
+type Server struct {
+	mu       sync.Mutex
+	region   string
+	scripts  map[string][]Response // by stop ID
+	fallback Response
+	requests []url.Values
+}
+
+// New creates a fake answering every stop with no arrivals until scripted.
+func New() *Server {
+	return &Server{
+		region:   DefaultRegion,
+		scripts:  make(map[string][]Response),
+		fallback: Arrivals(),
+	}
+}
+
+// SetRegion changes the region the fake answers for. Requests for other
+// regions get a failure result code.
+func (s *Server) SetRegion(region string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.region = region
+}
+
+// Script queues responses for a stop, answered in order; the last one keeps
+// being answered once the others are used up. Scripting a stop again
+// replaces its queue.
+func (s *Server) Script(stopID string, responses ...Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scripts[stopID] = responses
+}
+
+// SetDefault sets the response for stops without a script.
+func (s *Server) SetDefault(r Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fallback = r
+}
+
+// LoadRecordings scripts every stop with a recording in dir, a file named
+// after the stop ID such as 80192.json holding a nearest/bus response body
+// as Kentkart sent it. It returns the number of stops loaded.
+func (s *Server) LoadRecordings(dir string) (int, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return 0, err
+	}
+	for _, file := range files {
+		body, err := os.ReadFile(file)
+		if err != nil {
+			return 0, err
+		}
+		if !json.Valid(body) {
+			return 0, fmt.Errorf("%s: recording is not valid JSON", file)
+		}
+		s.Script(strings.TrimSuffix(filepath.Base(file), ".json"), Response{Body: body})
+	}
+	return len(files), nil
+}
+
+// Requests returns the query parameters of the requests answered so far.
+func (s *Server) Requests() []url.Values {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]url.Values(nil), s.requests...)
+}
+
+// ServeHTTP answers nearest/bus requests; other paths are not found.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasSuffix(r.URL.Path, "/nearest/bus") {
+		http.NotFound(w, r)
+		return
+	}
+	query := r.URL.Query()
+	resp := s.next(query)
+
+	if resp.Delay > 0 {
+		timer := time.NewTimer(resp.Delay)
+		select {
+		case <-r.Context().Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+
+	body := resp.Body
+	if body == nil {
+		var payload NearestBus
+		if resp.Payload != nil {
+			payload = *resp.Payload
+		}
+		if payload.Result.Code == 0 && payload.StopInfo.BusStopID == "" {
+			payload.StopInfo.BusStopID = query.Get("busStopId")
+		}
+		var err error
+		if body, err = json.Marshal(payload); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(cmp.Or(resp.Status, http.StatusOK))
+	w.Write(body)
+}
+
+// next records a request and picks its response.
+func (s *Server) next(query url.Values) Response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, query)
+
+	if region := query.Get("region"); region != s.region {
+		return ResultCode(2, fmt.Sprintf("unknown region %q", region))
+	}
+	stopID := query.Get("busStopId")
+	script, ok := s.scripts[stopID]
+	if !ok || len(script) == 0 {
+		return s.fallback
+	}
+	if len(script) > 1 {
+		s.scripts[stopID] = script[1:]
+	}
+	return script[0]
+}
@@ -1,6 +1,13 @@
 // Package model defines all data structures for the transport API.
 package model
 
+import (
+	"time"
+
+	"github.com/rfurkan37/transport-app/backend/internal/geo"
+	"github.com/rfurkan37/transport-app/backend/internal/spatial"
+)
+
 // Agency represents a transit agency from GTFS agency.csv
 type Agency struct {
 	ID       string `json:"agency_id"`
@@ -20,6 +27,41 @@ type Stop struct {
 	URL                string  `json:"stop_url,omitempty"`
 	LocationType       int     `json:"location_type"`
 	ParentStation      string  `json:"parent_station,omitempty"`
+	ZoneID             string  `json:"zone_id,omitempty"`
+	LevelID            string  `json:"level_id,omitempty"`
+	PlatformCode       string  `json:"platform_code,omitempty"`
+}
+
+// Location types of GTFS stops
+const (
+	LocationStop         = 0 // platform or stop
+	LocationStation      = 1
+	LocationEntrance     = 2
+	LocationGenericNode  = 3
+	LocationBoardingArea = 4
+)
+
+// Level is a floor of a station, from GTFS levels.csv
+type Level struct {
+	ID    string  `json:"level_id"`
+	Index float64 `json:"level_index"` // 0 = ground, negative below
+	Name  string  `json:"level_name,omitempty"`
+}
+
+// Pathway links two locations inside a station, from GTFS pathways.csv
+type Pathway struct {
+	ID                   string  `json:"pathway_id"`
+	FromStopID           string  `json:"from_stop_id"`
+	ToStopID             string  `json:"to_stop_id"`
+	Mode                 int     `json:"pathway_mode"` // 1=walkway, 2=stairs, 3=moving sidewalk, 4=escalator, 5=elevator, 6=fare gate, 7=exit gate
+	IsBidirectional      bool    `json:"is_bidirectional"`
+	Length               float64 `json:"length,omitempty"`         // meters
+	TraversalTime        int     `json:"traversal_time,omitempty"` // seconds
+	StairCount           int     `json:"stair_count,omitempty"`
+	MaxSlope             float64 `json:"max_slope,omitempty"`
+	MinWidth             float64 `json:"min_width,omitempty"`
+	SignpostedAs         string  `json:"signposted_as,omitempty"`
+	ReversedSignpostedAs string  `json:"reversed_signposted_as,omitempty"`
 }
 
 // Route represents a transit route from GTFS routes.csv
@@ -33,6 +75,19 @@ type Route struct {
 	Color     string `json:"route_color,omitempty"`
 	TextColor string `json:"route_text_color,omitempty"`
 	URL       string `json:"route_url,omitempty"`
+	NetworkID string `json:"network_id,omitempty"` // Fares v2 network
+
+	// Set for routes with trips in frequencies.csv
+	HeadwayMin float64        `json:"headway_min,omitempty"` // shortest headway
+	Headways   []RouteHeadway `json:"headways,omitempty"`
+}
+
+// RouteHeadway is a time window in which a route runs every HeadwayMin minutes
+type RouteHeadway struct {
+	StartTime  GTFSTime `json:"start_time"`
+	EndTime    GTFSTime `json:"end_time"`
+	HeadwayMin float64  `json:"headway_min"`
+	ExactTimes bool     `json:"exact_times"`
 }
 
 // Trip represents a trip from GTFS trips.csv
@@ -46,6 +101,31 @@ type Trip struct {
 	ShortName            string `json:"trip_short_name,omitempty"`
 	WheelchairAccessible int    `json:"wheelchair_accessible"`
 	BikesAllowed         int    `json:"bikes_allowed"`
+
+	// Set on trip instances expanded from frequencies.csv
+	Frequency *Frequency `json:"-"`
+}
+
+// Frequency runs a template trip repeatedly over a time window, from GTFS frequencies.csv
+type Frequency struct {
+	TripID      string   `json:"trip_id"`
+	StartTime   GTFSTime `json:"start_time"`
+	EndTime     GTFSTime `json:"end_time"`
+	HeadwaySecs int      `json:"headway_secs"`
+	ExactTimes  int      `json:"exact_times"` // 0 = times are approximate
+}
+
+// StopTime represents a trip's visit to a stop from GTFS stop_times.csv
+type StopTime struct {
+	TripID            string   `json:"trip_id"`
+	StopID            string   `json:"stop_id"`
+	StopSequence      int      `json:"stop_sequence"`
+	ArrivalTime       GTFSTime `json:"arrival_time"`
+	DepartureTime     GTFSTime `json:"departure_time"`
+	StopHeadsign      string   `json:"stop_headsign,omitempty"`
+	PickupType        int      `json:"pickup_type"`
+	DropOffType       int      `json:"drop_off_type"`
+	ShapeDistTraveled float64  `json:"shape_dist_traveled,omitempty"`
 }
 
 // Calendar represents service days from GTFS calendar.csv
@@ -62,6 +142,100 @@ type Calendar struct {
 	EndDate   string `json:"end_date"`
 }
 
+// Calendar date exception types
+const (
+	ServiceAdded   = 1
+	ServiceRemoved = 2
+)
+
+// CalendarDate adds or removes a service on one date, from GTFS calendar_dates.csv
+type CalendarDate struct {
+	ServiceID     string `json:"service_id"`
+	Date          string `json:"date"`
+	ExceptionType int    `json:"exception_type"`
+}
+
+// Transfer is a walking link between two stops, either computed from their
+// distance or read from GTFS transfers.csv
+type Transfer struct {
+	FromStopID      string  `json:"from_stop_id"`
+	ToStopID        string  `json:"to_stop_id"`
+	Type            int     `json:"transfer_type"`     // 0=recommended, 1=timed, 2=min time, 3=not possible
+	MinTransferTime int     `json:"min_transfer_time"` // seconds
+	Distance        float64 `json:"distance_m"`
+	Source          string  `json:"source"` // "computed" or "gtfs"
+}
+
+// FareAttribute is a fare class from GTFS fare_attributes.csv
+type FareAttribute struct {
+	FareID           string  `json:"fare_id"`
+	Price            float64 `json:"price"`
+	CurrencyType     string  `json:"currency_type"`
+	PaymentMethod    int     `json:"payment_method"` // 0 = on board, 1 = before boarding
+	Transfers        int     `json:"transfers"`      // -1 = unlimited
+	AgencyID         string  `json:"agency_id,omitempty"`
+	TransferDuration int     `json:"transfer_duration,omitempty"` // seconds
+}
+
+// FareRule restricts a fare to a route or zones, from GTFS fare_rules.csv
+type FareRule struct {
+	FareID        string `json:"fare_id"`
+	RouteID       string `json:"route_id,omitempty"`
+	OriginID      string `json:"origin_id,omitempty"`
+	DestinationID string `json:"destination_id,omitempty"`
+	ContainsID    string `json:"contains_id,omitempty"`
+}
+
+// FareProduct is a purchasable fare from GTFS-Fares v2 fare_products.csv
+type FareProduct struct {
+	ID          string  `json:"fare_product_id"`
+	Name        string  `json:"fare_product_name,omitempty"`
+	FareMediaID string  `json:"fare_media_id,omitempty"`
+	Amount      float64 `json:"amount"`
+	Currency    string  `json:"currency"`
+}
+
+// FareLegRule prices a single ride, from GTFS-Fares v2 fare_leg_rules.csv
+type FareLegRule struct {
+	LegGroupID    string `json:"leg_group_id,omitempty"`
+	NetworkID     string `json:"network_id,omitempty"`
+	FromAreaID    string `json:"from_area_id,omitempty"`
+	ToAreaID      string `json:"to_area_id,omitempty"`
+	FareProductID string `json:"fare_product_id"`
+	RulePriority  int    `json:"rule_priority,omitempty"`
+}
+
+// Fare transfer types of GTFS-Fares v2: what a transfer costs
+const (
+	TransferFromLegPlusTransfer      = 0 // A + AB
+	TransferFromLegPlusTransferAndTo = 1 // A + AB + B
+	TransferOnly                     = 2 // AB
+)
+
+// FareTransferRule prices a transfer between two rides, from GTFS-Fares v2
+// fare_transfer_rules.csv
+type FareTransferRule struct {
+	FromLegGroupID    string `json:"from_leg_group_id,omitempty"`
+	ToLegGroupID      string `json:"to_leg_group_id,omitempty"`
+	TransferCount     int    `json:"transfer_count"`           // -1 = unlimited
+	DurationLimit     int    `json:"duration_limit,omitempty"` // seconds
+	DurationLimitType int    `json:"duration_limit_type,omitempty"`
+	FareTransferType  int    `json:"fare_transfer_type"`
+	FareProductID     string `json:"fare_product_id,omitempty"`
+}
+
+// TranslationKey identifies a value in GTFS translations.csv: a field of
+// one record when RecordID is set, otherwise every occurrence of FieldValue
+// in that field. Languages are lowercase IETF tags.
+type TranslationKey struct {
+	Table       string
+	Field       string
+	Language    string
+	RecordID    string
+	RecordSubID string
+	FieldValue  string
+}
+
 // ShapePoint represents a point in a route shape from GTFS shapes.csv
 type ShapePoint struct {
 	ShapeID  string  `json:"shape_id"`
@@ -72,11 +246,15 @@ type ShapePoint struct {
 
 // Place represents a transit card kiosk from places.csv
 type Place struct {
-	ID   string  `json:"place_id"`
-	Name string  `json:"place_name"`
-	Lat  float64 `json:"place_lat"`
-	Lon  float64 `json:"place_lon"`
-	Type string  `json:"place_type"`
+	ID           string  `json:"place_id"`
+	Name         string  `json:"place_name"`
+	Lat          float64 `json:"place_lat"`
+	Lon          float64 `json:"place_lon"`
+	Type         string  `json:"place_type"`
+	Address      string  `json:"address,omitempty"`
+	District     string  `json:"district,omitempty"`
+	TerminalNo   string  `json:"terminal_no,omitempty"`
+	TerminalType string  `json:"terminal_type,omitempty"`
 }
 
 // GTFSData holds all loaded GTFS data in memory
@@ -89,9 +267,64 @@ type GTFSData struct {
 	Shapes    map[string][]ShapePoint
 	Places    map[string]*Place
 
+	// Stop times indexed by trip (sorted by stop_sequence)
+	// and by stop (sorted by departure time)
+	StopTimesByTrip map[string][]*StopTime
+	StopTimesByStop map[string][]*StopTime
+
+	// Headway windows indexed by template trip. The template is moved from
+	// Trips to TripTemplates, and its stop times are replaced by one trip
+	// instance per departure.
+	Frequencies   map[string][]*Frequency
+	TripTemplates map[string]*Trip
+
+	// Fares v1: attributes by fare ID and their rules
+	FareAttributes map[string]*FareAttribute
+	FareRules      map[string][]*FareRule // by fare ID
+
+	// Fares v2: products by ID (one per fare media), leg and transfer
+	// rules, route networks and the areas each stop belongs to
+	FareProducts      map[string][]*FareProduct
+	FareLegRules      []*FareLegRule
+	FareTransferRules []*FareTransferRule
+	RouteNetworks     map[string]string
+	StopAreas         map[string][]string
+
+	// Station tree: the children of each station (and the boarding areas
+	// of each platform), with the pathways and levels inside stations
+	StopChildren   map[string][]string
+	Levels         map[string]*Level
+	Pathways       map[string]*Pathway
+	PathwaysByStop map[string][]*Pathway // both ends of each pathway
+
+	// Translated names and the languages they are available in
+	Translations map[TranslationKey]string
+	Languages    []string
+
+	// Service exceptions indexed by date (YYYYMMDD)
+	CalendarDates map[string][]*CalendarDate
+
+	// Time zone of the agencies, in which service dates are counted
+	Location *time.Location
+
+	// Shape IDs used by each route and the bounds of each shape
+	RouteShapes map[string][]string
+	ShapeBounds map[string]geo.BBox
+
+	// Walking transfers indexed by origin stop
+	Transfers map[string][]*Transfer
+
 	// Slices for iteration
 	StopsList  []*Stop
 	RoutesList []*Route
+	PlacesList []*Place
+
+	// Spatial indexes for location lookups
+	StopIndex  *spatial.Grid[*Stop]
+	PlaceIndex *spatial.Grid[*Place]
+
+	// Problems found while parsing the feed files
+	LoadIssues []ValidationIssue
 }
 
 // NewGTFSData creates an empty GTFSData structure
@@ -104,6 +337,25 @@ func NewGTFSData() *GTFSData {
 		Calendars: make(map[string]*Calendar),
 		Shapes:    make(map[string][]ShapePoint),
 		Places:    make(map[string]*Place),
+
+		Frequencies:     make(map[string][]*Frequency),
+		TripTemplates:   make(map[string]*Trip),
+		FareAttributes:  make(map[string]*FareAttribute),
+		FareRules:       make(map[string][]*FareRule),
+		FareProducts:    make(map[string][]*FareProduct),
+		RouteNetworks:   make(map[string]string),
+		StopAreas:       make(map[string][]string),
+		Translations:    make(map[TranslationKey]string),
+		StopChildren:    make(map[string][]string),
+		Levels:          make(map[string]*Level),
+		Pathways:        make(map[string]*Pathway),
+		PathwaysByStop:  make(map[string][]*Pathway),
+		CalendarDates:   make(map[string][]*CalendarDate),
+		StopTimesByTrip: make(map[string][]*StopTime),
+		StopTimesByStop: make(map[string][]*StopTime),
+		RouteShapes:     make(map[string][]string),
+		ShapeBounds:     make(map[string]geo.BBox),
+		Transfers:       make(map[string][]*Transfer),
 	}
 }
 
@@ -116,4 +368,190 @@ type StopArrival struct {
 	RouteType   string `json:"route_type"`
 	ArrivalTime string `json:"arrival_time"`
 	Headsign    string `json:"headsign"`
+	RouteID     string `json:"route_id,omitempty"`
+	TripID      string `json:"trip_id,omitempty"`
+	Source      string `json:"source"` // provider that answered: kentkart, gtfs-rt or schedule
+
+	// Set for headway-based trips without exact times, whose ArrivalTime
+	// is empty while they run every HeadwayMin minutes
+	HeadwayMin  float64 `json:"headway_min,omitempty"`
+	Approximate bool    `json:"approximate,omitempty"`
+}
+
+// Vehicle is the live position of a vehicle in service
+type Vehicle struct {
+	ID        string    `json:"id"`
+	Label     string    `json:"label,omitempty"`
+	RouteID   string    `json:"route_id,omitempty"`
+	RouteCode string    `json:"route_code,omitempty"`
+	TripID    string    `json:"trip_id,omitempty"`
+	Lat       float64   `json:"lat"`
+	Lon       float64   `json:"lon"`
+	Bearing   float64   `json:"bearing,omitempty"`
+	Speed     float64   `json:"speed,omitempty"`   // meters per second
+	StopID    string    `json:"stop_id,omitempty"` // stop the vehicle is at or heading to
+	Timestamp time.Time `json:"timestamp,omitzero"`
+	Source    string    `json:"source"`
+
+	// Set when the vehicle's arrival at StopID is predicted
+	ArrivalTime string `json:"arrival_time,omitempty"`
+
+	// Set when vehicles are asked for by stop: the distance left to the
+	// stop, along ShapeID when the vehicle could be placed on its route's
+	// shape and in a straight line otherwise
+	DistanceToStop *float64 `json:"distance_to_stop_m,omitempty"`
+	ShapeID        string   `json:"shape_id,omitempty"`
+	AlongShape     bool     `json:"along_shape,omitempty"`
+}
+
+// ScheduledDeparture represents a timetabled departure from a stop
+type ScheduledDeparture struct {
+	TripID         string   `json:"trip_id"`
+	RouteID        string   `json:"route_id"`
+	RouteShortName string   `json:"route_short_name"`
+	RouteLongName  string   `json:"route_long_name"`
+	RouteColor     string   `json:"route_color,omitempty"`
+	Headsign       string   `json:"headsign"`
+	StopSequence   int      `json:"stop_sequence"`
+	ArrivalTime    GTFSTime `json:"arrival_time"`
+	DepartureTime  GTFSTime `json:"departure_time"`
+	ServiceDate    string   `json:"service_date"`
+	HeadwayMin     float64  `json:"headway_min,omitempty"`
+	Approximate    bool     `json:"approximate,omitempty"` // headway-based trip without exact times
+}
+
+// Geometry is a GeoJSON geometry object
+type Geometry struct {
+	Type        string `json:"type"`
+	Coordinates any    `json:"coordinates"`
+}
+
+// Feature is a GeoJSON feature
+type Feature struct {
+	Type       string         `json:"type"`
+	Geometry   Geometry       `json:"geometry"`
+	Properties map[string]any `json:"properties"`
+}
+
+// FeatureCollection is a GeoJSON feature collection
+type FeatureCollection struct {
+	Type     string    `json:"type"`
+	Features []Feature `json:"features"`
+}
+
+// LegPlace is the start or end point of a journey leg
+type LegPlace struct {
+	StopID string  `json:"stop_id,omitempty"`
+	Name   string  `json:"name"`
+	Lat    float64 `json:"lat"`
+	Lon    float64 `json:"lon"`
+}
+
+// Leg is a single walking or riding part of a journey
+type Leg struct {
+	Mode              string     `json:"mode"` // "walk" or "ride"
+	From              LegPlace   `json:"from"`
+	To                LegPlace   `json:"to"`
+	DepartureTime     GTFSTime   `json:"departure_time"`
+	ArrivalTime       GTFSTime   `json:"arrival_time"`
+	Duration          int        `json:"duration_s"`
+	Distance          float64    `json:"distance_m"`
+	TripID            string     `json:"trip_id,omitempty"`
+	RouteID           string     `json:"route_id,omitempty"`
+	RouteShortName    string     `json:"route_short_name,omitempty"`
+	RouteLongName     string     `json:"route_long_name,omitempty"`
+	RouteColor        string     `json:"route_color,omitempty"`
+	Headsign          string     `json:"headsign,omitempty"`
+	HeadwayMin        float64    `json:"headway_min,omitempty"`
+	Approximate       bool       `json:"approximate,omitempty"` // headway-based trip, times are estimates
+	IntermediateStops []LegPlace `json:"intermediate_stops,omitempty"`
+	Geometry          Geometry   `json:"geometry"`
+}
+
+// Journey is a planned trip from an origin to a destination
+type Journey struct {
+	DepartureTime GTFSTime `json:"departure_time"`
+	ArrivalTime   GTFSTime `json:"arrival_time"`
+	Duration      int      `json:"duration_s"`
+	Transfers     int      `json:"transfers"`
+	WalkDistance  float64  `json:"walk_distance_m"`
+	Fare          *Fare    `json:"fare,omitempty"`
+	Legs          []Leg    `json:"legs"`
+}
+
+// Fare is the price of a journey
+type Fare struct {
+	Total      float64         `json:"total"`
+	Currency   string          `json:"currency"`
+	Complete   bool            `json:"complete"` // false if some rides could not be priced
+	Components []FareComponent `json:"components"`
+}
+
+// RouteFare is a fare that can apply to rides on a route. Fares v1 entries
+// carry their zone restrictions, Fares v2 entries their network and areas.
+type RouteFare struct {
+	FareID           string  `json:"fare_id"`
+	Name             string  `json:"name,omitempty"`
+	Price            float64 `json:"price"`
+	Currency         string  `json:"currency"`
+	Transfers        *int    `json:"transfers,omitempty"` // -1 = unlimited
+	TransferDuration int     `json:"transfer_duration,omitempty"`
+	OriginID         string  `json:"origin_id,omitempty"`
+	DestinationID    string  `json:"destination_id,omitempty"`
+	ContainsID       string  `json:"contains_id,omitempty"`
+	NetworkID        string  `json:"network_id,omitempty"`
+	FromAreaID       string  `json:"from_area_id,omitempty"`
+	ToAreaID         string  `json:"to_area_id,omitempty"`
+}
+
+// FareComponent is one ticket or transfer charge of a journey fare
+type FareComponent struct {
+	FareID   string  `json:"fare_id"`
+	Name     string  `json:"name,omitempty"`
+	Price    float64 `json:"price"`
+	Currency string  `json:"currency"`
+	Legs     []int   `json:"legs"`               // indexes into Journey.Legs
+	Transfer bool    `json:"transfer,omitempty"` // charge for changing vehicles
+}
+
+// ReachableStop is a stop reached by an isochrone search
+type ReachableStop struct {
+	StopID      string   `json:"stop_id"`
+	StopName    string   `json:"stop_name"`
+	Lat         float64  `json:"stop_lat"`
+	Lon         float64  `json:"stop_lon"`
+	ArrivalTime GTFSTime `json:"arrival_time"`
+	Minutes     float64  `json:"minutes"`
+}
+
+// Isochrone is the area reachable from a point within time bands
+type Isochrone struct {
+	Bands FeatureCollection `json:"bands"`
+	Stops []ReachableStop   `json:"stops"`
+}
+
+// Validation severities
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// ValidationIssue is a single problem found in a GTFS feed
+type ValidationIssue struct {
+	Severity string `json:"severity"`
+	Code     string `json:"code"`
+	Table    string `json:"table"`
+	ID       string `json:"id,omitempty"`
+	Field    string `json:"field,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	Message  string `json:"message"`
+}
+
+// ValidationReport summarizes the problems found in a GTFS feed
+type ValidationReport struct {
+	Valid    bool              `json:"valid"`
+	Errors   int               `json:"errors"`
+	Warnings int               `json:"warnings"`
+	Codes    map[string]int    `json:"codes"`
+	Issues   []ValidationIssue `json:"issues"`
 }
@@ -0,0 +1,49 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// GTFSTime is a time of day in seconds since midnight of the service day.
+// Values past 24:00:00 are valid and denote trips running after midnight.
+type GTFSTime int
+
+// NoTime marks a stop time whose arrival or departure was left empty.
+const NoTime GTFSTime = -1
+
+// ParseGTFSTime parses an "HH:MM:SS" (or "HH:MM") string, allowing hours >= 24.
+func ParseGTFSTime(s string) (GTFSTime, error) {
+	// Fields are cut one at a time rather than split, as this runs for
+	// every row of stop_times.
+	rest := strings.TrimSpace(s)
+	var total int
+	for i, mult := range [...]int{3600, 60, 1} {
+		part, tail, more := strings.Cut(rest, ":")
+		v, err := strconv.Atoi(part)
+		if err != nil || v < 0 || (i > 0 && v >= 60) || (i == 0 && !more) {
+			return NoTime, fmt.Errorf("invalid time %q", s)
+		}
+		total += v * mult
+		if !more {
+			return GTFSTime(total), nil
+		}
+		rest = tail
+	}
+	return NoTime, fmt.Errorf("invalid time %q", s)
+}
+
+// String formats the time as "HH:MM:SS".
+func (t GTFSTime) String() string {
+	if t < 0 {
+		return ""
+	}
+	return fmt.Sprintf("%02d:%02d:%02d", int(t)/3600, int(t)%3600/60, int(t)%60)
+}
+
+// MarshalJSON encodes the time as an "HH:MM:SS" string.
+func (t GTFSTime) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.String())
+}
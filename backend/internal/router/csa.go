@@ -0,0 +1,324 @@
+package router
+
+import (
+	"sort"
+
+	"github.com/rfurkan37/transport-app/backend/internal/model"
+)
+
+// How a stop was reached during a connection scan.
+const (
+	viaNone = iota
+	viaAccess
+	viaRide
+	viaWalk
+)
+
+// csaPointer records how a stop got its earliest arrival time, so the
+// journey can be reconstructed by walking back from the destination.
+type csaPointer struct {
+	kind        int
+	enter, exit int // connection indices of a ride
+	walk        stopWalk
+
+	// Footpaths only: the other end of the walk and when it starts
+	from int
+	dep  model.GTFSTime
+}
+
+// csaState holds the per-stop and per-trip labels of one connection scan.
+type csaState struct {
+	arr       []model.GTFSTime // earliest arrival at each stop
+	ready     []model.GTFSTime // earliest time a vehicle can be boarded at each stop
+	via       []csaPointer
+	tripBoard []int // connection at which each trip was boarded, or -1
+}
+
+func (r *Router) newCSAState() *csaState {
+	s := &csaState{
+		arr:       make([]model.GTFSTime, len(r.stops)),
+		ready:     make([]model.GTFSTime, len(r.stops)),
+		via:       make([]csaPointer, len(r.stops)),
+		tripBoard: make([]int, len(r.trips)),
+	}
+	for i := range s.arr {
+		s.arr[i], s.ready[i] = infinity, infinity
+	}
+	for i := range s.tripBoard {
+		s.tripBoard[i] = -1
+	}
+	return s
+}
+
+// scan relaxes connections departing from start onward until they depart at
+// or after the value returned by limit, following footpaths after each
+// vehicle arrival. reached is called for every stop whose arrival time
+// improves.
+func (r *Router) scan(s *csaState, active []bool, start model.GTFSTime, limit func() model.GTFSTime, reached func(stop int)) {
+	first := sort.Search(len(r.connections), func(i int) bool {
+		return r.connections[i].dep >= start
+	})
+
+	for i := first; i < len(r.connections); i++ {
+		c := &r.connections[i]
+		if c.dep >= limit() {
+			break
+		}
+		if !active[c.trip] {
+			continue
+		}
+
+		if s.tripBoard[c.trip] < 0 {
+			if !c.canBoard || s.ready[c.from] > c.dep {
+				continue
+			}
+			s.tripBoard[c.trip] = i
+		}
+
+		if c.canAlight && c.arr < s.arr[c.to] {
+			s.arr[c.to] = c.arr
+			s.ready[c.to] = c.arr + r.transferTime[c.to]
+			s.via[c.to] = csaPointer{kind: viaRide, enter: s.tripBoard[c.trip], exit: i}
+			reached(c.to)
+
+			for _, fp := range r.footpaths[c.to] {
+				if t := c.arr + fp.duration; t < s.arr[fp.stop] {
+					s.arr[fp.stop], s.ready[fp.stop] = t, t
+					s.via[fp.stop] = csaPointer{
+						kind: viaWalk, from: c.to, dep: c.arr,
+						walk: stopWalk{stop: fp.stop, distance: fp.distance, duration: fp.duration},
+					}
+					reached(fp.stop)
+				}
+			}
+		}
+	}
+}
+
+// EarliestArrival runs the Connection Scan Algorithm and returns the journey
+// that reaches the destination earliest. It reports false if the
+// destination cannot be reached on the query date.
+func (r *Router) EarliestArrival(q Query) (model.Journey, bool) {
+	s := r.newCSAState()
+	for _, w := range r.snap(q.FromLat, q.FromLon) {
+		if t := q.Time + w.duration; t < s.arr[w.stop] {
+			s.arr[w.stop], s.ready[w.stop] = t, t
+			s.via[w.stop] = csaPointer{kind: viaAccess, walk: w}
+		}
+	}
+
+	egress := make(map[int]stopWalk)
+	for _, w := range r.snap(q.ToLat, q.ToLon) {
+		egress[w.stop] = w
+	}
+
+	best, bestStop := infinity, -1
+	walkDist, walkTime := r.directWalk(q)
+	if walkDist <= r.opts.MaxWalkDistance {
+		best = q.Time + walkTime
+	}
+
+	// Stops reached on foot from the origin may already be egress stops.
+	for stop, w := range egress {
+		if t := s.arr[stop] + w.duration; s.arr[stop] < infinity && t < best {
+			best, bestStop = t, stop
+		}
+	}
+
+	r.scan(s, r.activeTrips(q.Date), q.Time, func() model.GTFSTime { return best }, func(stop int) {
+		if w, ok := egress[stop]; ok && s.arr[stop]+w.duration < best {
+			best, bestStop = s.arr[stop]+w.duration, stop
+		}
+	})
+
+	if best == infinity {
+		return model.Journey{}, false
+	}
+	if bestStop < 0 {
+		return r.buildJourney(q, []step{{
+			from: originStop, to: destinationStop,
+			dep: q.Time, arr: q.Time + walkTime, distance: walkDist,
+		}}), true
+	}
+
+	steps := r.csaSteps(q, s, bestStop)
+	w := egress[bestStop]
+	steps = append(steps, step{
+		from: bestStop, to: destinationStop,
+		dep: s.arr[bestStop], arr: s.arr[bestStop] + w.duration, distance: w.distance,
+	})
+	return r.buildJourney(q, steps), true
+}
+
+// csaSteps reconstructs the steps leading from the origin to stop.
+func (r *Router) csaSteps(q Query, s *csaState, stop int) []step {
+	var steps []step
+	for stop >= 0 {
+		p := s.via[stop]
+		switch p.kind {
+		case viaRide:
+			enter, exit := r.connections[p.enter], r.connections[p.exit]
+			steps = append(steps, step{
+				ride: true, from: enter.from, to: exit.to,
+				dep: enter.dep, arr: exit.arr,
+				trip: enter.trip, board: enter.seq, alight: exit.seq + 1,
+			})
+			stop = enter.from
+		case viaWalk:
+			steps = append(steps, step{
+				from: p.from, to: stop,
+				dep: p.dep, arr: p.dep + p.walk.duration, distance: p.walk.distance,
+			})
+			stop = p.from
+		case viaAccess:
+			steps = append(steps, step{
+				from: originStop, to: stop,
+				dep: q.Time, arr: q.Time + p.walk.duration, distance: p.walk.distance,
+			})
+			stop = -1
+		default:
+			stop = -1
+		}
+	}
+
+	for i, j := 0, len(steps)-1; i < j; i, j = i+1, j-1 {
+		steps[i], steps[j] = steps[j], steps[i]
+	}
+	return steps
+}
+
+// LatestDeparture runs the Connection Scan Algorithm backwards from the query
+// time, treated as the latest acceptable arrival, and returns the journey
+// that leaves the origin as late as possible. It reports false if the
+// destination cannot be reached in time on the query date.
+func (r *Router) LatestDeparture(q Query) (model.Journey, bool) {
+	// dep is the latest departure from each stop that still reaches the
+	// destination in time, by is the latest a vehicle may arrive there to
+	// make that departure.
+	dep := make([]model.GTFSTime, len(r.stops))
+	by := make([]model.GTFSTime, len(r.stops))
+	via := make([]csaPointer, len(r.stops))
+	tripAlight := make([]int, len(r.trips))
+	for i := range dep {
+		dep[i], by[i] = -infinity, -infinity
+	}
+	for i := range tripAlight {
+		tripAlight[i] = -1
+	}
+
+	for _, w := range r.snap(q.ToLat, q.ToLon) {
+		if t := q.Time - w.duration; t > dep[w.stop] {
+			dep[w.stop], by[w.stop] = t, t
+			via[w.stop] = csaPointer{kind: viaAccess, walk: w}
+		}
+	}
+
+	access := make(map[int]stopWalk)
+	for _, w := range r.snap(q.FromLat, q.FromLon) {
+		access[w.stop] = w
+	}
+
+	best, bestStop := -infinity, -1
+	walkDist, walkTime := r.directWalk(q)
+	if walkDist <= r.opts.MaxWalkDistance {
+		best = q.Time - walkTime
+	}
+	for stop, w := range access {
+		if t := dep[stop] - w.duration; dep[stop] > -infinity && t > best {
+			best, bestStop = t, stop
+		}
+	}
+
+	active := r.activeTrips(q.Date)
+	last := sort.Search(len(r.byArrival), func(i int) bool {
+		return r.connections[r.byArrival[i]].arr > q.Time
+	})
+
+	for i := last - 1; i >= 0; i-- {
+		ci := r.byArrival[i]
+		c := &r.connections[ci]
+		if c.arr <= best {
+			break
+		}
+		if !active[c.trip] {
+			continue
+		}
+
+		if tripAlight[c.trip] < 0 {
+			if !c.canAlight || c.arr > by[c.to] {
+				continue
+			}
+			tripAlight[c.trip] = ci
+		}
+
+		if c.canBoard && c.dep > dep[c.from] {
+			dep[c.from] = c.dep
+			by[c.from] = c.dep - r.transferTime[c.from]
+			via[c.from] = csaPointer{kind: viaRide, enter: ci, exit: tripAlight[c.trip]}
+			if w, ok := access[c.from]; ok && c.dep-w.duration > best {
+				best, bestStop = c.dep-w.duration, c.from
+			}
+
+			for _, fp := range r.footpathsIn[c.from] {
+				t := c.dep - fp.duration
+				if t <= dep[fp.stop] {
+					continue
+				}
+				dep[fp.stop], by[fp.stop] = t, t
+				via[fp.stop] = csaPointer{
+					kind: viaWalk, from: c.from,
+					walk: stopWalk{stop: c.from, distance: fp.distance, duration: fp.duration},
+				}
+				if w, ok := access[fp.stop]; ok && t-w.duration > best {
+					best, bestStop = t-w.duration, fp.stop
+				}
+			}
+		}
+	}
+
+	if best == -infinity {
+		return model.Journey{}, false
+	}
+	if bestStop < 0 {
+		return r.buildJourney(q, []step{{
+			from: originStop, to: destinationStop,
+			dep: q.Time - walkTime, arr: q.Time, distance: walkDist,
+		}}), true
+	}
+
+	w := access[bestStop]
+	steps := []step{{
+		from: originStop, to: bestStop,
+		dep: best, arr: dep[bestStop], distance: w.distance,
+	}}
+	// Walk on to the destination as soon as the last vehicle arrives.
+	at := dep[bestStop]
+	for stop := bestStop; stop >= 0; {
+		p := via[stop]
+		switch p.kind {
+		case viaRide:
+			enter, exit := r.connections[p.enter], r.connections[p.exit]
+			steps = append(steps, step{
+				ride: true, from: enter.from, to: exit.to,
+				dep: enter.dep, arr: exit.arr,
+				trip: enter.trip, board: enter.seq, alight: exit.seq + 1,
+			})
+			stop, at = exit.to, exit.arr
+		case viaWalk:
+			steps = append(steps, step{
+				from: stop, to: p.from,
+				dep: at, arr: at + p.walk.duration, distance: p.walk.distance,
+			})
+			stop, at = p.from, at+p.walk.duration
+		case viaAccess:
+			steps = append(steps, step{
+				from: stop, to: destinationStop,
+				dep: at, arr: at + p.walk.duration, distance: p.walk.distance,
+			})
+			stop = -1
+		default:
+			stop = -1
+		}
+	}
+	return r.buildJourney(q, steps), true
+}
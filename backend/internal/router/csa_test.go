@@ -0,0 +1,92 @@
+package router
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestEarliestArrival(t *testing.T) {
+	r := newTestRouter(t, testFeed())
+
+	tests := []struct {
+		name      string
+		q         Query
+		wantTrips []string // nil when no journey is expected
+		wantArr   string
+	}{
+		{"one transfer at a shared station", query(stopA, stopB, "07:50:00"), []string{"T1", "T2"}, "08:50:00"},
+		{"missed connection", query(stopA, stopB, "08:10:00"), []string{"T1b", "T2b"}, "09:20:00"},
+		{"direct trip", query(stopA, stopX1, "08:25:00"), []string{"T1b"}, "08:50:00"},
+		{"previous day after midnight", query(stopA, stopX1, "00:30:00"), []string{"N1"}, "01:00:00"},
+		{"no more trips", query(stopA, stopB, "09:00:00"), nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j, ok := r.EarliestArrival(tt.q)
+			if ok != (tt.wantTrips != nil) {
+				t.Fatalf("found = %v, want %v", ok, tt.wantTrips != nil)
+			}
+			if !ok {
+				return
+			}
+			if got := rideTrips(j); !slices.Equal(got, tt.wantTrips) {
+				t.Errorf("trips = %v, want %v", got, tt.wantTrips)
+			}
+			if got := j.ArrivalTime.String(); got != tt.wantArr {
+				t.Errorf("arrival = %s, want %s", got, tt.wantArr)
+			}
+			if j.Transfers != len(tt.wantTrips)-1 {
+				t.Errorf("transfers = %d, want %d", j.Transfers, len(tt.wantTrips)-1)
+			}
+		})
+	}
+}
+
+func TestLatestDepartureMatchesEarliestArrival(t *testing.T) {
+	r := newTestRouter(t, testFeed())
+
+	tests := []struct {
+		name      string
+		arriveBy  string
+		wantTrips []string // nil when no journey is expected
+		wantDep   string
+	}{
+		{"exactly on time", "08:50:00", []string{"T1", "T2"}, "08:00:00"},
+		{"later connection", "09:25:00", []string{"T1b", "T2b"}, "08:30:00"},
+		{"later connection beats the direct trip", "09:30:00", []string{"T1b", "T2b"}, "08:30:00"},
+		{"too early", "08:40:00", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := query(stopA, stopB, tt.arriveBy)
+			j, ok := r.LatestDeparture(q)
+			if ok != (tt.wantTrips != nil) {
+				t.Fatalf("found = %v, want %v", ok, tt.wantTrips != nil)
+			}
+			if !ok {
+				return
+			}
+			if got := rideTrips(j); !slices.Equal(got, tt.wantTrips) {
+				t.Errorf("trips = %v, want %v", got, tt.wantTrips)
+			}
+			if got := j.DepartureTime.String(); got != tt.wantDep {
+				t.Errorf("departure = %s, want %s", got, tt.wantDep)
+			}
+			if j.ArrivalTime > q.Time {
+				t.Errorf("arrival %s after %s", j.ArrivalTime, q.Time)
+			}
+
+			// Leaving at that time, the depart-at search finds the same
+			// journey.
+			q.Time = j.DepartureTime
+			forward, ok := r.EarliestArrival(q)
+			if !ok {
+				t.Fatal("no depart-at journey")
+			}
+			if forward.ArrivalTime != j.ArrivalTime || !slices.Equal(rideTrips(forward), rideTrips(j)) {
+				t.Errorf("depart-at arrives %s on %v, arrive-by %s on %v",
+					forward.ArrivalTime, rideTrips(forward), j.ArrivalTime, rideTrips(j))
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package router
+
+import (
+	"math"
+	"sort"
+
+	"github.com/rfurkan37/transport-app/backend/internal/geo"
+	"github.com/rfurkan37/transport-app/backend/internal/model"
+)
+
+// circleSegments is the number of sides of the polygons buffering stops.
+const circleSegments = 16
+
+// Isochrone runs a one-to-all connection scan from the query origin and
+// returns the stops reachable within the largest band, plus one GeoJSON
+// MultiPolygon per band made of the walking buffers around each stop that
+// is reached with time to spare.
+func (r *Router) Isochrone(q Query, bands []int) model.Isochrone {
+	sort.Ints(bands)
+	maxBand := 0
+	if len(bands) > 0 {
+		maxBand = bands[len(bands)-1]
+	}
+	limit := q.Time + model.GTFSTime(maxBand*60)
+
+	s := r.newCSAState()
+	for _, w := range r.snap(q.FromLat, q.FromLon) {
+		if t := q.Time + w.duration; t < s.arr[w.stop] {
+			s.arr[w.stop], s.ready[w.stop] = t, t
+		}
+	}
+	r.scan(s, r.activeTrips(q.Date), q.Time, func() model.GTFSTime { return limit }, func(int) {})
+
+	iso := model.Isochrone{
+		Bands: model.FeatureCollection{Type: "FeatureCollection", Features: []model.Feature{}},
+		Stops: []model.ReachableStop{},
+	}
+	for i, t := range s.arr {
+		if t > limit {
+			continue
+		}
+		stop := r.stops[i]
+		iso.Stops = append(iso.Stops, model.ReachableStop{
+			StopID:      stop.ID,
+			StopName:    stop.Name,
+			Lat:         stop.Lat,
+			Lon:         stop.Lon,
+			ArrivalTime: t,
+			Minutes:     math.Round(float64(t-q.Time)/6) / 10,
+		})
+	}
+	sort.Slice(iso.Stops, func(i, j int) bool {
+		return iso.Stops[i].ArrivalTime < iso.Stops[j].ArrivalTime
+	})
+
+	for _, band := range bands {
+		end := q.Time + model.GTFSTime(band*60)
+		origin := min(float64(band*60)*r.opts.WalkSpeed, r.opts.MaxWalkDistance)
+		polygons := [][][][2]float64{{geo.Circle(q.FromLat, q.FromLon, origin, circleSegments)}}
+
+		reached := 0
+		for _, rs := range iso.Stops {
+			if rs.ArrivalTime > end {
+				break
+			}
+			reached++
+			radius := min(float64(end-rs.ArrivalTime)*r.opts.WalkSpeed, r.opts.MaxWalkDistance)
+			if radius < 1 {
+				continue
+			}
+			polygons = append(polygons, [][][2]float64{geo.Circle(rs.Lat, rs.Lon, radius, circleSegments)})
+		}
+
+		iso.Bands.Features = append(iso.Bands.Features, model.Feature{
+			Type:     "Feature",
+			Geometry: model.Geometry{Type: "MultiPolygon", Coordinates: polygons},
+			Properties: map[string]any{
+				"minutes":    band,
+				"stop_count": reached,
+			},
+		})
+	}
+
+	return iso
+}
@@ -0,0 +1,200 @@
+package router
+
+import (
+	"math"
+
+	"github.com/rfurkan37/transport-app/backend/internal/geo"
+	"github.com/rfurkan37/transport-app/backend/internal/model"
+	"github.com/rfurkan37/transport-app/backend/internal/service"
+)
+
+// Stop indices used in steps for the query's own coordinates.
+const (
+	originStop      = -1
+	destinationStop = -2
+)
+
+// step is one part of a raw journey produced by a search, before it is
+// expanded into legs with names and geometry.
+type step struct {
+	ride     bool
+	from, to int
+	dep, arr model.GTFSTime
+
+	// Ride steps only
+	trip          int
+	board, alight int // indices into the trip's stop times
+
+	// Walk steps only
+	distance float64
+}
+
+// buildJourney expands the steps of a search result into a journey.
+func (r *Router) buildJourney(q Query, steps []step) model.Journey {
+	steps = mergeWalks(steps)
+
+	// Leave the origin just in time for the first vehicle.
+	if len(steps) > 1 && !steps[0].ride && steps[1].ride {
+		walk := steps[0].arr - steps[0].dep
+		steps[0].arr = steps[1].dep
+		steps[0].dep = steps[1].dep - walk
+	}
+
+	journey := model.Journey{Legs: make([]model.Leg, 0, len(steps))}
+	rides := 0
+	for _, s := range steps {
+		if s.ride {
+			rides++
+			journey.Legs = append(journey.Legs, r.rideLeg(s))
+			continue
+		}
+		leg := r.walkLeg(q, s)
+		journey.WalkDistance += leg.Distance
+		journey.Legs = append(journey.Legs, leg)
+	}
+
+	if len(journey.Legs) > 0 {
+		journey.DepartureTime = journey.Legs[0].DepartureTime
+		journey.ArrivalTime = journey.Legs[len(journey.Legs)-1].ArrivalTime
+		journey.Duration = int(journey.ArrivalTime - journey.DepartureTime)
+	}
+	if rides > 1 {
+		journey.Transfers = rides - 1
+	}
+	journey.WalkDistance = math.Round(journey.WalkDistance)
+	journey.Fare = service.JourneyFare(r.data, journey.Legs)
+	return journey
+}
+
+// mergeWalks joins consecutive walking steps into one.
+func mergeWalks(steps []step) []step {
+	merged := make([]step, 0, len(steps))
+	for _, s := range steps {
+		if n := len(merged); n > 0 && !s.ride && !merged[n-1].ride {
+			merged[n-1].to = s.to
+			merged[n-1].arr = s.arr
+			merged[n-1].distance += s.distance
+			continue
+		}
+		merged = append(merged, s)
+	}
+	return merged
+}
+
+func (r *Router) place(q Query, stop int) model.LegPlace {
+	switch stop {
+	case originStop:
+		return model.LegPlace{Name: "Origin", Lat: q.FromLat, Lon: q.FromLon}
+	case destinationStop:
+		return model.LegPlace{Name: "Destination", Lat: q.ToLat, Lon: q.ToLon}
+	}
+	return stopPlace(r.stops[stop])
+}
+
+func stopPlace(stop *model.Stop) model.LegPlace {
+	return model.LegPlace{StopID: stop.ID, Name: stop.Name, Lat: stop.Lat, Lon: stop.Lon}
+}
+
+func (r *Router) walkLeg(q Query, s step) model.Leg {
+	from, to := r.place(q, s.from), r.place(q, s.to)
+	return model.Leg{
+		Mode:          "walk",
+		From:          from,
+		To:            to,
+		DepartureTime: s.dep,
+		ArrivalTime:   s.arr,
+		Duration:      int(s.arr - s.dep),
+		Distance:      math.Round(s.distance),
+		Geometry:      lineString([][2]float64{{from.Lon, from.Lat}, {to.Lon, to.Lat}}),
+	}
+}
+
+func (r *Router) rideLeg(s step) model.Leg {
+	trip := r.trips[s.trip]
+	times := r.tripTimes[s.trip]
+	board, alight := times[s.board], times[s.alight]
+
+	leg := model.Leg{
+		Mode:          "ride",
+		From:          r.place(Query{}, s.from),
+		To:            r.place(Query{}, s.to),
+		DepartureTime: board.DepartureTime,
+		ArrivalTime:   alight.ArrivalTime,
+		Duration:      int(alight.ArrivalTime - board.DepartureTime),
+		TripID:        trip.TripID,
+		RouteID:       trip.RouteID,
+		Headsign:      trip.Headsign,
+		HeadwayMin:    service.HeadwayMinutes(trip),
+		Approximate:   service.Approximate(trip),
+	}
+	if board.StopHeadsign != "" {
+		leg.Headsign = board.StopHeadsign
+	}
+	if route, ok := r.data.Routes[trip.RouteID]; ok {
+		leg.RouteShortName = route.ShortName
+		leg.RouteLongName = route.LongName
+		leg.RouteColor = route.Color
+	}
+
+	stopCoords := make([][2]float64, 0, s.alight-s.board+1)
+	for i := s.board; i <= s.alight; i++ {
+		stop, ok := r.data.Stops[times[i].StopID]
+		if !ok {
+			continue
+		}
+		stopCoords = append(stopCoords, [2]float64{stop.Lon, stop.Lat})
+		if i > s.board && i < s.alight {
+			leg.IntermediateStops = append(leg.IntermediateStops, stopPlace(stop))
+		}
+	}
+
+	coords := r.shapeBetween(trip.ShapeID, leg.From, leg.To)
+	if coords == nil {
+		coords = stopCoords
+	}
+	leg.Geometry = lineString(coords)
+	leg.Distance = math.Round(pathLength(coords))
+	return leg
+}
+
+// shapeBetween cuts the part of a trip shape between two stops, or returns
+// nil when the trip has no usable shape.
+func (r *Router) shapeBetween(shapeID string, from, to model.LegPlace) [][2]float64 {
+	points := r.data.Shapes[shapeID]
+	if len(points) < 2 {
+		return nil
+	}
+
+	start := nearestShapePoint(points, 0, from.Lat, from.Lon)
+	end := nearestShapePoint(points, start, to.Lat, to.Lon)
+
+	coords := make([][2]float64, 0, end-start+3)
+	coords = append(coords, [2]float64{from.Lon, from.Lat})
+	for _, p := range points[start : end+1] {
+		coords = append(coords, [2]float64{p.Lon, p.Lat})
+	}
+	coords = append(coords, [2]float64{to.Lon, to.Lat})
+	return coords
+}
+
+func nearestShapePoint(points []model.ShapePoint, start int, lat, lon float64) int {
+	best, bestDist := start, math.MaxFloat64
+	for i := start; i < len(points); i++ {
+		if d := geo.HaversineDistance(lat, lon, points[i].Lat, points[i].Lon); d < bestDist {
+			best, bestDist = i, d
+		}
+	}
+	return best
+}
+
+func pathLength(coords [][2]float64) float64 {
+	var total float64
+	for i := 1; i < len(coords); i++ {
+		total += geo.HaversineDistance(coords[i-1][1], coords[i-1][0], coords[i][1], coords[i][0])
+	}
+	return total
+}
+
+func lineString(coords [][2]float64) model.Geometry {
+	return model.Geometry{Type: "LineString", Coordinates: coords}
+}
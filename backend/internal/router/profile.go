@@ -0,0 +1,250 @@
+package router
+
+import (
+	"sort"
+
+	"github.com/rfurkan37/transport-app/backend/internal/model"
+)
+
+// raptorParent records how a stop was reached in a RAPTOR round.
+type raptorParent struct {
+	kind          int // labelAccess, labelRide or labelWalk
+	walk          stopWalk
+	from          int // footpaths only
+	trip          int
+	board, alight int
+}
+
+// Profile returns every journey that is Pareto-optimal on departure time,
+// arrival time and number of transfers among those leaving the origin
+// between q.Time and until. It runs rRAPTOR: one RAPTOR search per
+// departure time, latest first, reusing labels between runs so that each
+// run only has to find journeys that beat the ones leaving later.
+func (r *Router) Profile(q Query, until model.GTFSTime) []model.Journey {
+	active := r.activeTrips(q.Date)
+	rounds := r.opts.MaxTransfers + 1
+
+	access := r.snap(q.FromLat, q.FromLon)
+	egress := make(map[int]stopWalk)
+	for _, w := range r.snap(q.ToLat, q.ToLon) {
+		egress[w.stop] = w
+	}
+
+	arr := make([][]model.GTFSTime, rounds+1)
+	parent := make([][]raptorParent, rounds+1)
+	for k := range arr {
+		arr[k] = make([]model.GTFSTime, len(r.stops))
+		parent[k] = make([]raptorParent, len(r.stops))
+		for i := range arr[k] {
+			arr[k][i] = infinity
+		}
+	}
+	target := make([]model.GTFSTime, rounds+1)
+	targetStop := make([]int, rounds+1)
+	for k := range target {
+		target[k] = infinity
+	}
+
+	var journeys []model.Journey
+	for _, dep := range r.departureTimes(access, q.Time, until, active) {
+		improved := make([]bool, rounds+1)
+
+		marked := make(map[int]bool)
+		for _, w := range access {
+			if t := dep + w.duration; t < arr[0][w.stop] {
+				arr[0][w.stop] = t
+				parent[0][w.stop] = raptorParent{kind: labelAccess, walk: w}
+				marked[w.stop] = true
+			}
+		}
+
+		for k := 1; k <= rounds && len(marked) > 0; k++ {
+			bound := infinity
+			for j := 0; j <= k; j++ {
+				bound = min(bound, target[j])
+			}
+			marked = r.raptorRound(k, marked, arr, parent, active, bound)
+
+			for stop := range marked {
+				if w, ok := egress[stop]; ok && arr[k][stop]+w.duration < target[k] {
+					target[k] = arr[k][stop] + w.duration
+					targetStop[k] = stop
+					improved[k] = true
+				}
+			}
+		}
+
+		// Keep only journeys that beat every journey with fewer transfers.
+		bound := infinity
+		for k := 1; k <= rounds; k++ {
+			if improved[k] && target[k] < bound {
+				steps := r.roundSteps(arr, parent, k, targetStop[k])
+				w := egress[targetStop[k]]
+				at := arr[k][targetStop[k]]
+				steps = append(steps, step{
+					from: targetStop[k], to: destinationStop,
+					dep: at, arr: at + w.duration, distance: w.distance,
+				})
+				// Labels reused from later runs may not leave within the window.
+				if j := r.buildJourney(q, steps); j.DepartureTime <= until {
+					journeys = append(journeys, j)
+				}
+			}
+			bound = min(bound, target[k])
+		}
+	}
+
+	journeys = paretoByDeparture(journeys)
+	sort.Slice(journeys, func(i, j int) bool {
+		if journeys[i].DepartureTime != journeys[j].DepartureTime {
+			return journeys[i].DepartureTime < journeys[j].DepartureTime
+		}
+		return journeys[i].Transfers < journeys[j].Transfers
+	})
+	return journeys
+}
+
+// departureTimes lists, latest first, the distinct times at which one can
+// leave the origin to catch a vehicle at an access stop within the window.
+func (r *Router) departureTimes(access []stopWalk, from, until model.GTFSTime, active []bool) []model.GTFSTime {
+	seen := make(map[model.GTFSTime]bool)
+	var times []model.GTFSTime
+	for _, w := range access {
+		for _, ps := range r.stopPatterns[w.stop] {
+			for _, trip := range r.patterns[ps.pattern].trips {
+				st := r.tripTimes[trip][ps.pos]
+				t := st.DepartureTime - w.duration
+				if !active[trip] || st.PickupType == 1 || t < from || t > until || seen[t] {
+					continue
+				}
+				seen[t] = true
+				times = append(times, t)
+			}
+		}
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i] > times[j] })
+	return times
+}
+
+// raptorRound scans every pattern through the stops marked in round k-1
+// and returns the stops whose round-k arrival improved. Arrivals at or
+// after bound cannot lead to a useful journey and are discarded.
+func (r *Router) raptorRound(k int, marked map[int]bool, arr [][]model.GTFSTime, parent [][]raptorParent, active []bool, bound model.GTFSTime) map[int]bool {
+	queue := make(map[int]int)
+	for stop := range marked {
+		for _, ps := range r.stopPatterns[stop] {
+			if pos, ok := queue[ps.pattern]; !ok || ps.pos < pos {
+				queue[ps.pattern] = ps.pos
+			}
+		}
+	}
+
+	next := make(map[int]bool)
+	for p, start := range queue {
+		pat := &r.patterns[p]
+		rank, board := -1, 0
+
+		for pos := start; pos < len(pat.stops); pos++ {
+			stop := pat.stops[pos]
+
+			if rank >= 0 {
+				trip := pat.trips[rank]
+				st := r.tripTimes[trip][pos]
+				if st.DropOffType != 1 && st.ArrivalTime < arr[k][stop] && st.ArrivalTime < bound {
+					arr[k][stop] = st.ArrivalTime
+					parent[k][stop] = raptorParent{kind: labelRide, trip: trip, board: board, alight: pos}
+					next[stop] = true
+				}
+			}
+
+			if arr[k-1][stop] == infinity {
+				continue
+			}
+			ready := arr[k-1][stop]
+			if parent[k-1][stop].kind == labelRide {
+				ready += r.transferTime[stop]
+			}
+			if earlier := r.earliestTrip(pat, pos, ready, active); earlier >= 0 && (rank < 0 || earlier < rank) {
+				rank, board = earlier, pos
+			}
+		}
+	}
+
+	rides := make([]int, 0, len(next))
+	for stop := range next {
+		rides = append(rides, stop)
+	}
+	for _, stop := range rides {
+		for _, fp := range r.footpaths[stop] {
+			if t := arr[k][stop] + fp.duration; t < arr[k][fp.stop] && t < bound {
+				arr[k][fp.stop] = t
+				parent[k][fp.stop] = raptorParent{
+					kind: labelWalk, from: stop,
+					walk: stopWalk{stop: fp.stop, distance: fp.distance, duration: fp.duration},
+				}
+				next[fp.stop] = true
+			}
+		}
+	}
+	return next
+}
+
+// roundSteps reconstructs the steps reaching stop in round k.
+func (r *Router) roundSteps(arr [][]model.GTFSTime, parent [][]raptorParent, k, stop int) []step {
+	var steps []step
+	for k >= 0 {
+		p := parent[k][stop]
+		if p.kind == labelAccess {
+			steps = append(steps, step{
+				from: originStop, to: stop,
+				dep: arr[k][stop] - p.walk.duration, arr: arr[k][stop], distance: p.walk.distance,
+			})
+			break
+		}
+		if p.kind == labelWalk {
+			steps = append(steps, step{
+				from: p.from, to: stop,
+				dep: arr[k][stop] - p.walk.duration, arr: arr[k][stop], distance: p.walk.distance,
+			})
+			stop = p.from
+			continue
+		}
+		times := r.tripTimes[p.trip]
+		board := r.stopIndex[times[p.board].StopID]
+		steps = append(steps, step{
+			ride: true, from: board, to: stop,
+			dep: times[p.board].DepartureTime, arr: times[p.alight].ArrivalTime,
+			trip: p.trip, board: p.board, alight: p.alight,
+		})
+		stop = board
+		k--
+	}
+
+	for i, j := 0, len(steps)-1; i < j; i, j = i+1, j-1 {
+		steps[i], steps[j] = steps[j], steps[i]
+	}
+	return steps
+}
+
+// paretoByDeparture drops journeys that leave no later, arrive no earlier
+// and transfer no less often than another journey.
+func paretoByDeparture(journeys []model.Journey) []model.Journey {
+	var kept []model.Journey
+	for i, a := range journeys {
+		dominated := false
+		for j, b := range journeys {
+			if i == j {
+				continue
+			}
+			if b.DepartureTime >= a.DepartureTime && b.ArrivalTime <= a.ArrivalTime && b.Transfers <= a.Transfers &&
+				(b.DepartureTime != a.DepartureTime || b.ArrivalTime != a.ArrivalTime || b.Transfers != a.Transfers || j < i) {
+				dominated = true
+				break
+			}
+		}
+		if !dominated {
+			kept = append(kept, a)
+		}
+	}
+	return kept
+}
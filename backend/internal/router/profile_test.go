@@ -0,0 +1,42 @@
+package router
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestProfile(t *testing.T) {
+	r := newTestRouter(t, testFeed())
+
+	tests := []struct {
+		name        string
+		from, until string
+		want        []string // departure, arrival, transfers and trips of each journey
+	}{
+		{"whole morning", "07:00:00", "09:00:00", []string{
+			"08:00:00 08:50:00 1 [T1 T2]",
+			"08:00:00 09:30:00 0 [T3]",
+			"08:30:00 09:20:00 1 [T1b T2b]",
+		}},
+		{"window after the first trips", "08:10:00", "09:00:00", []string{
+			"08:30:00 09:20:00 1 [T1b T2b]",
+		}},
+		{"window before any trip", "06:00:00", "07:00:00", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := query(stopA, stopB, tt.from)
+			until := query(stopA, stopB, tt.until).Time
+
+			var got []string
+			for _, j := range r.Profile(q, until) {
+				got = append(got, fmt.Sprintf("%s %s %d %v", j.DepartureTime, j.ArrivalTime, j.Transfers, rideTrips(j)))
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("journeys = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
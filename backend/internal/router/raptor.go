@@ -0,0 +1,393 @@
+package router
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/rfurkan37/transport-app/backend/internal/model"
+)
+
+// pattern is a RAPTOR route: trips of one GTFS route that visit exactly the
+// same sequence of stops, ordered by departure from the first stop. No trip
+// of a pattern overtakes another, so the first trip that can be boarded at
+// a stop is also the first to reach every later stop.
+type pattern struct {
+	stops []int
+	trips []int
+}
+
+// patternStop is a position of a stop within a pattern.
+type patternStop struct {
+	pattern, pos int
+}
+
+// buildPatterns groups the router's trips into RAPTOR patterns. Trips of a
+// route on the same stops are split into as many patterns as it takes for
+// none to overtake another, as express runs and previous-day copies may.
+func (r *Router) buildPatterns() {
+	byKey := make(map[string]int)
+	var groups [][]int // trips by route and stop sequence
+	r.stopPatterns = make([][]patternStop, len(r.stops))
+
+	for tripIdx, times := range r.tripTimes {
+		var key strings.Builder
+		key.WriteString(r.trips[tripIdx].RouteID)
+		known := true
+		for _, st := range times {
+			if _, ok := r.stopIndex[st.StopID]; !ok {
+				known = false
+				break
+			}
+			key.WriteByte('|')
+			key.WriteString(st.StopID)
+		}
+		if !known {
+			continue
+		}
+
+		g, ok := byKey[key.String()]
+		if !ok {
+			g = len(groups)
+			byKey[key.String()] = g
+			groups = append(groups, nil)
+		}
+		groups[g] = append(groups[g], tripIdx)
+	}
+
+	for _, trips := range groups {
+		sort.Slice(trips, func(a, b int) bool {
+			return r.tripTimes[trips[a]][0].DepartureTime < r.tripTimes[trips[b]][0].DepartureTime
+		})
+
+		// Each trip joins the first pattern whose latest trip it does not
+		// overtake.
+		first := len(r.patterns)
+		for _, trip := range trips {
+			p := first
+			for p < len(r.patterns) && r.overtakes(trip, r.patterns[p].trips[len(r.patterns[p].trips)-1]) {
+				p++
+			}
+			if p == len(r.patterns) {
+				r.patterns = append(r.patterns, pattern{stops: r.tripStops(trip)})
+				for pos, stop := range r.patterns[p].stops {
+					r.stopPatterns[stop] = append(r.stopPatterns[stop], patternStop{pattern: p, pos: pos})
+				}
+			}
+			r.patterns[p].trips = append(r.patterns[p].trips, trip)
+		}
+	}
+}
+
+// overtakes reports whether trip, on the same stops as other, arrives at or
+// leaves any of them earlier than other does.
+func (r *Router) overtakes(trip, other int) bool {
+	times, otherTimes := r.tripTimes[trip], r.tripTimes[other]
+	for pos, st := range times {
+		if st.ArrivalTime < otherTimes[pos].ArrivalTime || st.DepartureTime < otherTimes[pos].DepartureTime {
+			return true
+		}
+	}
+	return false
+}
+
+// tripStops returns the router indices of a trip's stops.
+func (r *Router) tripStops(trip int) []int {
+	stops := make([]int, len(r.tripTimes[trip]))
+	for i, st := range r.tripTimes[trip] {
+		stops[i] = r.stopIndex[st.StopID]
+	}
+	return stops
+}
+
+// earliestTrip returns the rank within the pattern of the first active trip
+// that can be boarded at position pos at or after t, or -1 if there is none.
+func (r *Router) earliestTrip(p *pattern, pos int, t model.GTFSTime, active []bool) int {
+	for rank, trip := range p.trips {
+		st := r.tripTimes[trip][pos]
+		if active[trip] && st.PickupType != 1 && st.DepartureTime >= t {
+			return rank
+		}
+	}
+	return -1
+}
+
+// How a McRAPTOR label was reached.
+const (
+	labelAccess = iota
+	labelRide
+	labelWalk
+)
+
+// mcLabel is a multi-criteria label: a way of reaching a stop with a given
+// arrival time and walking distance within a RAPTOR round.
+type mcLabel struct {
+	kind  int
+	stop  int
+	arr   model.GTFSTime
+	ready model.GTFSTime
+	walk  float64
+	prev  *mcLabel
+
+	// Ride labels only
+	trip          int
+	board, alight int
+}
+
+// dominates reports whether l is at least as good as o on time and walking.
+func (l *mcLabel) dominates(o *mcLabel) bool {
+	return l.arr <= o.arr && l.walk <= o.walk
+}
+
+// bag is a Pareto set of labels.
+type bag []*mcLabel
+
+// add inserts l unless it is dominated, dropping labels it dominates.
+// It reports whether l was added.
+func (b *bag) add(l *mcLabel) bool {
+	for _, o := range *b {
+		if o.dominates(l) {
+			return false
+		}
+	}
+	kept := (*b)[:0]
+	for _, o := range *b {
+		if !l.dominates(o) {
+			kept = append(kept, o)
+		}
+	}
+	*b = append(kept, l)
+	return true
+}
+
+func (b bag) dominated(l *mcLabel) bool {
+	for _, o := range b {
+		if o.dominates(l) {
+			return true
+		}
+	}
+	return false
+}
+
+// routeLabel is a label travelling along a pattern in a specific trip.
+type routeLabel struct {
+	rank   int // trip rank within the pattern
+	board  int
+	walk   float64
+	parent *mcLabel
+}
+
+// targetLabel is a complete journey candidate at the destination.
+type targetLabel struct {
+	arr       model.GTFSTime
+	walk      float64
+	transfers int
+	last      *mcLabel
+	egress    stopWalk
+}
+
+// Pareto runs multi-criteria RAPTOR and returns every journey that is
+// Pareto-optimal on arrival time, number of transfers and walking distance.
+func (r *Router) Pareto(q Query) []model.Journey {
+	active := r.activeTrips(q.Date)
+	rounds := r.opts.MaxTransfers + 1
+
+	egress := make(map[int]stopWalk)
+	for _, w := range r.snap(q.ToLat, q.ToLon) {
+		egress[w.stop] = w
+	}
+
+	var targets []targetLabel
+	addTarget := func(t targetLabel) {
+		for _, o := range targets {
+			if o.arr <= t.arr && o.walk <= t.walk && o.transfers <= t.transfers {
+				return
+			}
+		}
+		kept := targets[:0]
+		for _, o := range targets {
+			if !(t.arr <= o.arr && t.walk <= o.walk && t.transfers <= o.transfers) {
+				kept = append(kept, o)
+			}
+		}
+		targets = append(kept, t)
+	}
+	prunedByTarget := func(l *mcLabel) bool {
+		for _, o := range targets {
+			if o.arr <= l.arr && o.walk <= l.walk {
+				return true
+			}
+		}
+		return false
+	}
+
+	if d, t := r.directWalk(q); d <= r.opts.MaxWalkDistance {
+		addTarget(targetLabel{arr: q.Time + t, walk: d})
+	}
+
+	// best holds every label found so far at each stop, across rounds;
+	// a label with more transfers must beat all of them to be kept.
+	best := make([]bag, len(r.stops))
+	prev := make(map[int]bag)
+	for _, w := range r.snap(q.FromLat, q.FromLon) {
+		l := &mcLabel{kind: labelAccess, stop: w.stop, arr: q.Time + w.duration, walk: w.distance}
+		l.ready = l.arr
+		if best[w.stop].add(l) {
+			prev[w.stop] = append(prev[w.stop], l)
+		}
+	}
+
+	for k := 1; k <= rounds && len(prev) > 0; k++ {
+		// Collect patterns serving the stops improved in the previous round,
+		// remembering the earliest position to start scanning from.
+		queue := make(map[int]int)
+		for stop := range prev {
+			for _, ps := range r.stopPatterns[stop] {
+				if pos, ok := queue[ps.pattern]; !ok || ps.pos < pos {
+					queue[ps.pattern] = ps.pos
+				}
+			}
+		}
+
+		current := make(map[int]bag)
+		for p, start := range queue {
+			pat := &r.patterns[p]
+			var routeBag []routeLabel
+
+			for pos := start; pos < len(pat.stops); pos++ {
+				stop := pat.stops[pos]
+
+				// Alight every route label at this stop.
+				for _, rl := range routeBag {
+					trip := pat.trips[rl.rank]
+					st := r.tripTimes[trip][pos]
+					if st.DropOffType == 1 {
+						continue
+					}
+					l := &mcLabel{
+						kind: labelRide, stop: stop,
+						arr: st.ArrivalTime, walk: rl.walk, prev: rl.parent,
+						trip: trip, board: rl.board, alight: pos,
+					}
+					l.ready = l.arr + r.transferTime[stop]
+					if prunedByTarget(l) || best[stop].dominated(l) {
+						continue
+					}
+					best[stop].add(l)
+					b := current[stop]
+					b.add(l)
+					current[stop] = b
+				}
+
+				// Board with labels from the previous round.
+				for _, l := range prev[stop] {
+					rank := r.earliestTrip(pat, pos, l.ready, active)
+					if rank < 0 {
+						continue
+					}
+					routeBag = addRouteLabel(routeBag, routeLabel{rank: rank, board: pos, walk: l.walk, parent: l})
+				}
+			}
+		}
+
+		// Walk from every stop reached by vehicle in this round.
+		var rides []*mcLabel
+		for _, b := range current {
+			rides = append(rides, b...)
+		}
+		for _, l := range rides {
+			for _, fp := range r.footpaths[l.stop] {
+				nl := &mcLabel{
+					kind: labelWalk, stop: fp.stop,
+					arr: l.arr + fp.duration, walk: l.walk + fp.distance, prev: l,
+				}
+				nl.ready = nl.arr
+				if prunedByTarget(nl) || best[fp.stop].dominated(nl) {
+					continue
+				}
+				best[fp.stop].add(nl)
+				b := current[fp.stop]
+				b.add(nl)
+				current[fp.stop] = b
+			}
+		}
+
+		for stop, b := range current {
+			w, ok := egress[stop]
+			if !ok {
+				continue
+			}
+			for _, l := range b {
+				addTarget(targetLabel{arr: l.arr + w.duration, walk: l.walk + w.distance, transfers: k - 1, last: l, egress: w})
+			}
+		}
+		prev = current
+	}
+
+	journeys := make([]model.Journey, 0, len(targets))
+	for _, t := range targets {
+		journeys = append(journeys, r.buildJourney(q, r.labelSteps(q, t)))
+	}
+	sort.Slice(journeys, func(i, j int) bool {
+		if journeys[i].ArrivalTime != journeys[j].ArrivalTime {
+			return journeys[i].ArrivalTime < journeys[j].ArrivalTime
+		}
+		return journeys[i].Transfers < journeys[j].Transfers
+	})
+	return journeys
+}
+
+// addRouteLabel merges rl into a route bag, keeping it Pareto-optimal on
+// trip (earlier is better) and walking distance.
+func addRouteLabel(routeBag []routeLabel, rl routeLabel) []routeLabel {
+	for _, o := range routeBag {
+		if o.rank <= rl.rank && o.walk <= rl.walk {
+			return routeBag
+		}
+	}
+	kept := routeBag[:0]
+	for _, o := range routeBag {
+		if !(rl.rank <= o.rank && rl.walk <= o.walk) {
+			kept = append(kept, o)
+		}
+	}
+	return append(kept, rl)
+}
+
+// labelSteps converts a target label's chain into journey steps.
+func (r *Router) labelSteps(q Query, t targetLabel) []step {
+	if t.last == nil {
+		d, wt := r.directWalk(q)
+		return []step{{from: originStop, to: destinationStop, dep: q.Time, arr: q.Time + wt, distance: d}}
+	}
+
+	steps := []step{{
+		from: t.last.stop, to: destinationStop,
+		dep: t.last.arr, arr: t.last.arr + t.egress.duration, distance: t.egress.distance,
+	}}
+	for l := t.last; l != nil; l = l.prev {
+		switch l.kind {
+		case labelRide:
+			times := r.tripTimes[l.trip]
+			steps = append(steps, step{
+				ride: true, from: r.stopIndex[times[l.board].StopID], to: l.stop,
+				dep: times[l.board].DepartureTime, arr: l.arr,
+				trip: l.trip, board: l.board, alight: l.alight,
+			})
+		case labelWalk:
+			steps = append(steps, step{
+				from: l.prev.stop, to: l.stop,
+				dep: l.prev.arr, arr: l.arr, distance: l.walk - l.prev.walk,
+			})
+		case labelAccess:
+			steps = append(steps, step{
+				from: originStop, to: l.stop,
+				dep: q.Time, arr: l.arr, distance: l.walk,
+			})
+		}
+	}
+
+	for i, j := 0, len(steps)-1; i < j; i, j = i+1, j-1 {
+		steps[i], steps[j] = steps[j], steps[i]
+	}
+	return steps
+}
@@ -0,0 +1,85 @@
+package router
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestPareto(t *testing.T) {
+	r := newTestRouter(t, testFeed())
+
+	tests := []struct {
+		name string
+		q    Query
+		want []string // arrival, transfers and trips of each journey
+	}{
+		{"faster with a transfer or slower direct", query(stopA, stopB, "07:50:00"), []string{
+			"08:50:00 1 [T1 T2]",
+			"09:30:00 0 [T3]",
+		}},
+		{"direct trip gone", query(stopA, stopB, "08:10:00"), []string{
+			"09:20:00 1 [T1b T2b]",
+		}},
+		{"single route", query(stopA, stopX1, "07:50:00"), []string{
+			"08:20:00 0 [T1]",
+		}},
+		{"unreachable", query(stopA, stopB, "09:00:00"), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, j := range r.Pareto(tt.q) {
+				got = append(got, fmt.Sprintf("%s %d %v", j.ArrivalTime, j.Transfers, rideTrips(j)))
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("journeys = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParetoOvertakingTrips(t *testing.T) {
+	// On route R1 the express E1 leaves A after the slow S1 and reaches X1
+	// first.
+	f := testFeed()
+	f["trips"] += "R1,ALL,S1\nR1,ALL,E1\n"
+	f["stop_times"] += "S1,07:00:00,07:00:00,A,1\nS1,08:40:00,08:40:00,X1,2\n" +
+		"E1,07:05:00,07:05:00,A,1\nE1,07:25:00,07:25:00,X1,2\n"
+	r := newTestRouter(t, f)
+
+	for i, p := range r.patterns {
+		for k := 1; k < len(p.trips); k++ {
+			if r.overtakes(p.trips[k], p.trips[k-1]) {
+				t.Errorf("pattern %d: %s overtakes %s", i, r.trips[p.trips[k]].TripID, r.trips[p.trips[k-1]].TripID)
+			}
+		}
+	}
+
+	tests := []struct {
+		name string
+		q    Query
+		want []string // arrival, transfers and trips of each journey
+	}{
+		{"express reaches X1 first", query(stopA, stopX1, "07:00:00"), []string{
+			"07:25:00 0 [E1]",
+		}},
+		{"express makes the earlier connection", query(stopA, stopB, "07:00:00"), []string{
+			"08:50:00 1 [E1 T2]",
+			"09:30:00 0 [T3]",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, j := range r.Pareto(tt.q) {
+				got = append(got, fmt.Sprintf("%s %d %v", j.ArrivalTime, j.Transfers, rideTrips(j)))
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("journeys = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
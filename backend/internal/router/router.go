@@ -0,0 +1,360 @@
+// Package router implements journey planning over the loaded GTFS timetable.
+package router
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/rfurkan37/transport-app/backend/internal/geo"
+	"github.com/rfurkan37/transport-app/backend/internal/model"
+	"github.com/rfurkan37/transport-app/backend/internal/service"
+)
+
+// infinity is used as the arrival time of stops not reached yet.
+const infinity = model.GTFSTime(math.MaxInt32)
+
+// day is the length of a service day.
+const day = model.GTFSTime(24 * 3600)
+
+// Errors reported by CheckQuery
+var (
+	ErrNoStopsNearOrigin      = errors.New("no stops near origin")
+	ErrNoStopsNearDestination = errors.New("no stops near destination")
+)
+
+// Options configures walking and transfer behaviour of the router.
+type Options struct {
+	MaxWalkDistance float64 // meters, for walks to and from stops
+	MaxSnapDistance float64 // meters; a point farther than this from every stop cannot be routed from or to
+	WalkSpeed       float64 // meters per second
+	MinTransferTime int     // seconds needed to change vehicles at a stop
+	MaxTransfers    int     // transfers considered by RAPTOR searches
+}
+
+// DefaultOptions returns the options used by the server.
+func DefaultOptions() Options {
+	return Options{
+		MaxWalkDistance: 1000,
+		MaxSnapDistance: 3000,
+		WalkSpeed:       1.3,
+		MinTransferTime: 60,
+		MaxTransfers:    4,
+	}
+}
+
+// Query describes a journey request between two coordinates.
+type Query struct {
+	FromLat, FromLon float64
+	ToLat, ToLon     float64
+	Date             time.Time
+	Time             model.GTFSTime
+}
+
+// Router answers journey queries over a fixed GTFS snapshot.
+type Router struct {
+	data *model.GTFSData
+	opts Options
+
+	stops     []*model.Stop
+	stopIndex map[string]int
+
+	footpaths    [][]footpath     // walking links out of each stop
+	footpathsIn  [][]footpath     // walking links into each stop
+	transferTime []model.GTFSTime // time needed to change vehicles at each stop
+
+	trips     []*model.Trip
+	tripTimes [][]*model.StopTime
+	prevDay   []bool // copies of trips of the previous service day
+
+	connections []connection
+	byArrival   []int // connection indices sorted by arrival time
+
+	patterns     []pattern
+	stopPatterns [][]patternStop
+}
+
+// footpath is a walking link to (or, in footpathsIn, from) another stop.
+type footpath struct {
+	stop     int
+	duration model.GTFSTime
+	distance float64
+}
+
+// connection is a vehicle hop between two consecutive stops of a trip.
+type connection struct {
+	trip      int
+	seq       int // index of the departure stop within the trip's stop times
+	from, to  int
+	dep, arr  model.GTFSTime
+	canBoard  bool
+	canAlight bool
+}
+
+// New builds the connection timetable and RAPTOR patterns for data.
+func New(data *model.GTFSData, opts Options) *Router {
+	r := &Router{
+		data:      data,
+		opts:      opts,
+		stopIndex: make(map[string]int, len(data.Stops)),
+	}
+
+	for _, stop := range data.StopsList {
+		r.stopIndex[stop.ID] = len(r.stops)
+		r.stops = append(r.stops, stop)
+	}
+	r.buildFootpaths()
+
+	for tripID, times := range data.StopTimesByTrip {
+		trip, ok := data.Trips[tripID]
+		if !ok || len(times) < 2 || !timed(times) {
+			continue
+		}
+		r.addTrip(trip, times, false)
+
+		// A trip running past midnight can also be caught on the next
+		// service day, as a copy with its times shifted back 24 hours.
+		if times[len(times)-1].ArrivalTime >= day {
+			r.addTrip(trip, shiftStopTimes(times, -day), true)
+		}
+	}
+
+	sort.Slice(r.connections, func(i, j int) bool {
+		if r.connections[i].dep != r.connections[j].dep {
+			return r.connections[i].dep < r.connections[j].dep
+		}
+		return r.connections[i].arr < r.connections[j].arr
+	})
+	r.byArrival = make([]int, len(r.connections))
+	for i := range r.byArrival {
+		r.byArrival[i] = i
+	}
+	sort.Slice(r.byArrival, func(i, j int) bool {
+		return r.connections[r.byArrival[i]].arr < r.connections[r.byArrival[j]].arr
+	})
+	r.buildPatterns()
+
+	return r
+}
+
+// addTrip adds a trip and the connections between its stops. Connections
+// leaving before midnight of a previous-day copy are left out, as the
+// original trip covers them.
+func (r *Router) addTrip(trip *model.Trip, times []*model.StopTime, prevDay bool) {
+	tripIdx := len(r.trips)
+	r.trips = append(r.trips, trip)
+	r.tripTimes = append(r.tripTimes, times)
+	r.prevDay = append(r.prevDay, prevDay)
+
+	for i := 0; i+1 < len(times); i++ {
+		from, okFrom := r.stopIndex[times[i].StopID]
+		to, okTo := r.stopIndex[times[i+1].StopID]
+		if !okFrom || !okTo || times[i].DepartureTime < 0 {
+			continue
+		}
+		r.connections = append(r.connections, connection{
+			trip:      tripIdx,
+			seq:       i,
+			from:      from,
+			to:        to,
+			dep:       times[i].DepartureTime,
+			arr:       times[i+1].ArrivalTime,
+			canBoard:  times[i].PickupType != 1,
+			canAlight: times[i+1].DropOffType != 1,
+		})
+	}
+}
+
+// timed reports whether every stop of a trip has a time. Interpolation
+// leaves stops untimed only before the first or after the last timed stop,
+// and such trips cannot be placed on the timetable.
+func timed(times []*model.StopTime) bool {
+	for _, st := range times {
+		if st.ArrivalTime == model.NoTime || st.DepartureTime == model.NoTime {
+			return false
+		}
+	}
+	return true
+}
+
+// shiftStopTimes returns copies of a trip's stop times moved by offset.
+func shiftStopTimes(times []*model.StopTime, offset model.GTFSTime) []*model.StopTime {
+	shifted := make([]*model.StopTime, len(times))
+	for i, st := range times {
+		c := *st
+		c.ArrivalTime += offset
+		c.DepartureTime += offset
+		shifted[i] = &c
+	}
+	return shifted
+}
+
+// buildFootpaths indexes the feed's transfer table by stop. A transfer from
+// a stop to itself sets the time needed to change vehicles there. Platforms
+// of one station are then linked as if they were a single stop.
+func (r *Router) buildFootpaths() {
+	r.footpaths = make([][]footpath, len(r.stops))
+	r.footpathsIn = make([][]footpath, len(r.stops))
+	r.transferTime = make([]model.GTFSTime, len(r.stops))
+	for i := range r.transferTime {
+		r.transferTime[i] = model.GTFSTime(r.opts.MinTransferTime)
+	}
+
+	for fromID, transfers := range r.data.Transfers {
+		from, ok := r.stopIndex[fromID]
+		if !ok {
+			continue
+		}
+		for _, t := range transfers {
+			to, ok := r.stopIndex[t.ToStopID]
+			if !ok {
+				continue
+			}
+			if t.Source == "computed" && r.sameStation(from, to) {
+				continue // replaced by linkStations
+			}
+			if from == to {
+				if t.Type == 3 {
+					r.transferTime[from] = infinity / 2
+				} else if t.Type == 2 {
+					r.transferTime[from] = model.GTFSTime(t.MinTransferTime)
+				}
+				continue
+			}
+			if t.Type == 3 {
+				continue
+			}
+			duration := model.GTFSTime(t.MinTransferTime)
+			r.footpaths[from] = append(r.footpaths[from], footpath{stop: to, duration: duration, distance: t.Distance})
+			r.footpathsIn[to] = append(r.footpathsIn[to], footpath{stop: from, duration: duration, distance: t.Distance})
+		}
+	}
+	r.linkStations()
+}
+
+// sameStation reports whether two stops are platforms of one station.
+func (r *Router) sameStation(a, b int) bool {
+	station := r.stops[a].ParentStation
+	return station != "" && station == r.stops[b].ParentStation
+}
+
+// linkStations lets riders change between the platforms of a station as
+// they would at a single stop: every pair of platforms not already linked
+// by transfers.txt gets a footpath taking the time needed to change
+// vehicles at the destination platform, or longer if the station's
+// pathways say the walk takes longer.
+func (r *Router) linkStations() {
+	for stationID, children := range r.data.StopChildren {
+		if station, ok := r.data.Stops[stationID]; !ok || station.LocationType != model.LocationStation {
+			continue
+		}
+		var platforms []int
+		for _, id := range children {
+			if i, ok := r.stopIndex[id]; ok && r.stops[i].LocationType == model.LocationStop {
+				platforms = append(platforms, i)
+			}
+		}
+
+		for _, from := range platforms {
+			linked := make(map[int]bool)
+			for _, fp := range r.footpaths[from] {
+				linked[fp.stop] = true
+			}
+			for _, to := range platforms {
+				if from == to || linked[to] || r.transferTime[to] >= infinity/2 {
+					continue
+				}
+				a, b := r.stops[from], r.stops[to]
+				duration := r.transferTime[to]
+				distance := geo.HaversineDistance(a.Lat, a.Lon, b.Lat, b.Lon)
+				if seconds, meters, ok := service.StationPath(r.data, a.ID, b.ID, r.opts.WalkSpeed); ok {
+					duration = max(duration, model.GTFSTime(math.Ceil(seconds)))
+					distance = meters
+				}
+				distance = math.Round(distance)
+				r.footpaths[from] = append(r.footpaths[from], footpath{stop: to, duration: duration, distance: distance})
+				r.footpathsIn[to] = append(r.footpathsIn[to], footpath{stop: from, duration: duration, distance: distance})
+			}
+		}
+	}
+}
+
+// Connections returns the number of elementary connections in the timetable.
+func (r *Router) Connections() int {
+	return len(r.connections)
+}
+
+// activeTrips marks the trips whose service runs on date, and the
+// previous-day copies of trips whose service ran the day before.
+func (r *Router) activeTrips(date time.Time) []bool {
+	today := service.ActiveServices(r.data, date)
+	yesterday := service.ActiveServices(r.data, date.AddDate(0, 0, -1))
+	active := make([]bool, len(r.trips))
+	for i, trip := range r.trips {
+		if r.prevDay[i] {
+			active[i] = yesterday[trip.ServiceID]
+		} else {
+			active[i] = today[trip.ServiceID]
+		}
+	}
+	return active
+}
+
+// stopWalk is a stop reachable on foot from a coordinate.
+type stopWalk struct {
+	stop     int
+	distance float64
+	duration model.GTFSTime
+}
+
+// snap returns the stops within walking distance of a coordinate. When none
+// is close enough the single nearest stop is returned instead, as long as
+// it is within MaxSnapDistance.
+func (r *Router) snap(lat, lon float64) []stopWalk {
+	results := r.data.StopIndex.Radius(lat, lon, r.opts.MaxWalkDistance)
+	if len(results) == 0 {
+		results = r.data.StopIndex.Radius(lat, lon, r.opts.MaxSnapDistance)
+		results = results[:min(len(results), 1)]
+	}
+
+	walks := make([]stopWalk, 0, len(results))
+	for _, res := range results {
+		if i, ok := r.stopIndex[res.Item.ID]; ok {
+			walks = append(walks, stopWalk{stop: i, distance: res.Distance, duration: r.walkTime(res.Distance)})
+		}
+	}
+	return walks
+}
+
+// NearStops reports whether a coordinate is close enough to a stop for
+// journeys to start or end there.
+func (r *Router) NearStops(lat, lon float64) bool {
+	return len(r.snap(lat, lon)) > 0
+}
+
+// CheckQuery returns ErrNoStopsNearOrigin or ErrNoStopsNearDestination when
+// a query cannot be planned because one of its ends is too far from every
+// stop. Ends within walking distance of each other are always accepted.
+func (r *Router) CheckQuery(q Query) error {
+	if d, _ := r.directWalk(q); d <= r.opts.MaxWalkDistance {
+		return nil
+	}
+	if !r.NearStops(q.FromLat, q.FromLon) {
+		return ErrNoStopsNearOrigin
+	}
+	if !r.NearStops(q.ToLat, q.ToLon) {
+		return ErrNoStopsNearDestination
+	}
+	return nil
+}
+
+func (r *Router) walkTime(meters float64) model.GTFSTime {
+	return model.GTFSTime(math.Ceil(meters / r.opts.WalkSpeed))
+}
+
+// directWalk returns the walking distance and time between the query points.
+func (r *Router) directWalk(q Query) (float64, model.GTFSTime) {
+	d := geo.HaversineDistance(q.FromLat, q.FromLon, q.ToLat, q.ToLon)
+	return d, r.walkTime(d)
+}
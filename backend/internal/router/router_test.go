@@ -0,0 +1,105 @@
+package router
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rfurkan37/transport-app/backend/internal/gtfstest"
+	"github.com/rfurkan37/transport-app/backend/internal/model"
+	"github.com/rfurkan37/transport-app/backend/internal/service"
+)
+
+// testFeed is a line from A to B through station X, about 8.5 km apart
+// each: route R1 runs from A to platform X1, R2 from platform X2 to B and
+// the slower R3 straight from A to B. N1 leaves A after midnight.
+func testFeed() gtfstest.Feed {
+	f := gtfstest.Base()
+	f["stops"] = `stop_id,stop_name,stop_lat,stop_lon,location_type,parent_station
+A,A,40.7600,29.8000,0,
+X,Station X,40.7600,29.9000,1,
+X1,X platform 1,40.7601,29.9000,0,X
+X2,X platform 2,40.7599,29.9003,0,X
+B,B,40.7600,30.0000,0,
+`
+	f["routes"] = "route_id,agency_id,route_short_name,route_type\nR1,A,1,3\nR2,A,2,3\nR3,A,3,3\n"
+	f["trips"] = "route_id,service_id,trip_id\nR1,ALL,T1\nR1,ALL,T1b\nR2,ALL,T2\nR2,ALL,T2b\nR3,ALL,T3\nR1,ALL,N1\n"
+	f["stop_times"] = `trip_id,arrival_time,departure_time,stop_id,stop_sequence
+T1,08:00:00,08:00:00,A,1
+T1,08:20:00,08:20:00,X1,2
+T1b,08:30:00,08:30:00,A,1
+T1b,08:50:00,08:50:00,X1,2
+T2,08:30:00,08:30:00,X2,1
+T2,08:50:00,08:50:00,B,2
+T2b,09:00:00,09:00:00,X2,1
+T2b,09:20:00,09:20:00,B,2
+T3,08:00:00,08:00:00,A,1
+T3,09:30:00,09:30:00,B,2
+N1,24:40:00,24:40:00,A,1
+N1,25:00:00,25:00:00,X1,2
+`
+	return f
+}
+
+// Coordinates of the stops of testFeed
+var (
+	stopA  = [2]float64{40.7600, 29.8000}
+	stopX1 = [2]float64{40.7601, 29.9000}
+	stopB  = [2]float64{40.7600, 30.0000}
+)
+
+// testDate is a day testFeed's service runs, as is the day before.
+var testDate = time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)
+
+func newTestRouter(t *testing.T, f gtfstest.Feed) *Router {
+	t.Helper()
+	data, err := service.LoadGTFS(gtfstest.Write(t, f), service.DefaultLoadOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return New(data, DefaultOptions())
+}
+
+func query(from, to [2]float64, at string) Query {
+	t, err := model.ParseGTFSTime(at)
+	if err != nil {
+		panic(err)
+	}
+	return Query{FromLat: from[0], FromLon: from[1], ToLat: to[0], ToLon: to[1], Date: testDate, Time: t}
+}
+
+// rideTrips returns the trips a journey rides, in order.
+func rideTrips(j model.Journey) []string {
+	var trips []string
+	for _, leg := range j.Legs {
+		if leg.Mode == "ride" {
+			trips = append(trips, leg.TripID)
+		}
+	}
+	return trips
+}
+
+func TestCheckQuery(t *testing.T) {
+	r := newTestRouter(t, testFeed())
+	farAway := [2]float64{40.7600, 29.7500} // 4.2 km west of A
+	nearA := [2]float64{40.7600, 29.7800}   // 1.7 km west of A
+
+	tests := []struct {
+		name     string
+		from, to [2]float64
+		want     error
+	}{
+		{"near stops", stopA, stopB, nil},
+		{"snapped to the nearest stop", nearA, stopB, nil},
+		{"origin too far", farAway, stopB, ErrNoStopsNearOrigin},
+		{"destination too far", stopB, farAway, ErrNoStopsNearDestination},
+		{"walkable without stops", farAway, [2]float64{40.7610, 29.7500}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := r.CheckQuery(query(tt.from, tt.to, "08:00:00")); !errors.Is(err, tt.want) {
+				t.Errorf("CheckQuery() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	const cooldown = 10 * time.Millisecond
+	open := []string{"fail", "fail", "fail"}
+
+	tests := []struct {
+		name      string
+		steps     []string
+		wantState string
+		wantAllow bool // of the next call
+	}{
+		{"closed below the threshold", []string{"fail", "fail"}, breakerClosed, true},
+		{"opens at the threshold", open, breakerOpen, false},
+		{"success resets the count", []string{"fail", "fail", "ok", "fail", "fail"}, breakerClosed, true},
+		{"half-open after the cooldown", append(open, "wait", "allow"), breakerHalfOpen, false},
+		{"trial success closes", append(open, "wait", "allow", "ok"), breakerClosed, true},
+		{"trial failure opens again", append(open, "wait", "allow", "fail"), breakerOpen, false},
+		{"abandoned trial lets another through", append(open, "wait", "allow", "abandon"), breakerHalfOpen, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBreaker(3, cooldown)
+			for _, step := range tt.steps {
+				switch step {
+				case "fail":
+					b.failure()
+				case "ok":
+					b.success()
+				case "abandon":
+					b.abandon()
+				case "wait":
+					time.Sleep(cooldown + time.Millisecond)
+				case "allow":
+					if !b.allow() {
+						t.Fatal("call not allowed")
+					}
+				}
+			}
+			if got := b.current(); got != tt.wantState {
+				t.Errorf("state = %s, want %s", got, tt.wantState)
+			}
+			if got := b.allow(); got != tt.wantAllow {
+				t.Errorf("allow() = %v, want %v", got, tt.wantAllow)
+			}
+		})
+	}
+}
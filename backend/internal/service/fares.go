@@ -0,0 +1,667 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/rfurkan37/transport-app/backend/internal/model"
+)
+
+// Fares v1: fare_attributes.csv and fare_rules.csv
+
+func loadFareAttributes(reader *csvReader, data *model.GTFSData) error {
+	reader.require("fare_id", "price", "currency_type", "payment_method")
+	reader.identify("fare_id")
+	var (
+		id               = reader.column("fare_id")
+		price            = reader.column("price")
+		currency         = reader.column("currency_type")
+		paymentMethod    = reader.column("payment_method")
+		transfers        = reader.column("transfers")
+		agencyID         = reader.column("agency_id")
+		transferDuration = reader.column("transfer_duration")
+	)
+
+	for reader.Next() {
+		r := reader.Record()
+		fare := &model.FareAttribute{
+			FareID:           reader.intern(id.text(r)),
+			Price:            price.float(r),
+			CurrencyType:     reader.intern(currency.text(r)),
+			PaymentMethod:    paymentMethod.int(r),
+			Transfers:        -1, // an empty field allows unlimited transfers
+			AgencyID:         reader.intern(agencyID.text(r)),
+			TransferDuration: transferDuration.int(r),
+		}
+		if transfers.text(r) != "" {
+			fare.Transfers = transfers.int(r)
+		}
+		if _, ok := data.FareAttributes[fare.FareID]; ok {
+			reader.duplicate("fare_id", fare.FareID)
+		}
+		data.FareAttributes[fare.FareID] = fare
+	}
+	if err := reader.Err(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Loaded %d fare attributes\n", len(data.FareAttributes))
+	return nil
+}
+
+func loadFareRules(reader *csvReader, data *model.GTFSData) error {
+	reader.require("fare_id")
+	reader.identify("fare_id")
+	var (
+		fareID        = reader.column("fare_id")
+		routeID       = reader.column("route_id")
+		originID      = reader.column("origin_id")
+		destinationID = reader.column("destination_id")
+		containsID    = reader.column("contains_id")
+	)
+
+	count := 0
+	for reader.Next() {
+		r := reader.Record()
+		rule := &model.FareRule{
+			FareID:        reader.intern(fareID.text(r)),
+			RouteID:       reader.intern(routeID.text(r)),
+			OriginID:      reader.intern(originID.text(r)),
+			DestinationID: reader.intern(destinationID.text(r)),
+			ContainsID:    reader.intern(containsID.text(r)),
+		}
+		data.FareRules[rule.FareID] = append(data.FareRules[rule.FareID], rule)
+		count++
+	}
+	if err := reader.Err(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Loaded %d fare rules\n", count)
+	return nil
+}
+
+// Fares v2: fare_products.csv, fare_leg_rules.csv, fare_transfer_rules.csv,
+// route_networks.csv and stop_areas.csv
+
+func loadFareProducts(reader *csvReader, data *model.GTFSData) error {
+	reader.require("fare_product_id", "amount", "currency")
+	reader.identify("fare_product_id")
+	var (
+		id       = reader.column("fare_product_id")
+		name     = reader.column("fare_product_name")
+		mediaID  = reader.column("fare_media_id")
+		amount   = reader.column("amount")
+		currency = reader.column("currency")
+	)
+
+	count := 0
+	for reader.Next() {
+		r := reader.Record()
+		p := &model.FareProduct{
+			ID:          reader.intern(id.text(r)),
+			Name:        strings.Clone(name.text(r)),
+			FareMediaID: reader.intern(mediaID.text(r)),
+			Amount:      amount.float(r),
+			Currency:    reader.intern(currency.text(r)),
+		}
+		data.FareProducts[p.ID] = append(data.FareProducts[p.ID], p)
+		count++
+	}
+	if err := reader.Err(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Loaded %d fare products\n", count)
+	return nil
+}
+
+func loadFareLegRules(reader *csvReader, data *model.GTFSData) error {
+	reader.require("fare_product_id")
+	reader.identify("leg_group_id")
+	var (
+		legGroupID    = reader.column("leg_group_id")
+		networkID     = reader.column("network_id")
+		fromAreaID    = reader.column("from_area_id")
+		toAreaID      = reader.column("to_area_id")
+		fareProductID = reader.column("fare_product_id")
+		rulePriority  = reader.column("rule_priority")
+	)
+
+	for reader.Next() {
+		r := reader.Record()
+		data.FareLegRules = append(data.FareLegRules, &model.FareLegRule{
+			LegGroupID:    reader.intern(legGroupID.text(r)),
+			NetworkID:     reader.intern(networkID.text(r)),
+			FromAreaID:    reader.intern(fromAreaID.text(r)),
+			ToAreaID:      reader.intern(toAreaID.text(r)),
+			FareProductID: reader.intern(fareProductID.text(r)),
+			RulePriority:  rulePriority.int(r),
+		})
+	}
+	if err := reader.Err(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Loaded %d fare leg rules\n", len(data.FareLegRules))
+	return nil
+}
+
+func loadFareTransferRules(reader *csvReader, data *model.GTFSData) error {
+	reader.require("fare_transfer_type")
+	reader.identify("from_leg_group_id")
+	var (
+		fromLegGroupID    = reader.column("from_leg_group_id")
+		toLegGroupID      = reader.column("to_leg_group_id")
+		transferCount     = reader.column("transfer_count")
+		durationLimit     = reader.column("duration_limit")
+		durationLimitType = reader.column("duration_limit_type")
+		fareTransferType  = reader.column("fare_transfer_type")
+		fareProductID     = reader.column("fare_product_id")
+	)
+
+	for reader.Next() {
+		r := reader.Record()
+		rule := &model.FareTransferRule{
+			FromLegGroupID:    reader.intern(fromLegGroupID.text(r)),
+			ToLegGroupID:      reader.intern(toLegGroupID.text(r)),
+			TransferCount:     -1,
+			DurationLimit:     durationLimit.int(r),
+			DurationLimitType: durationLimitType.int(r),
+			FareTransferType:  fareTransferType.int(r),
+			FareProductID:     reader.intern(fareProductID.text(r)),
+		}
+		if transferCount.text(r) != "" {
+			rule.TransferCount = transferCount.int(r)
+		}
+		data.FareTransferRules = append(data.FareTransferRules, rule)
+	}
+	if err := reader.Err(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Loaded %d fare transfer rules\n", len(data.FareTransferRules))
+	return nil
+}
+
+func loadRouteNetworks(reader *csvReader, data *model.GTFSData) error {
+	reader.require("network_id", "route_id")
+	reader.identify("route_id")
+	var (
+		networkID = reader.column("network_id")
+		routeID   = reader.column("route_id")
+	)
+
+	for reader.Next() {
+		r := reader.Record()
+		id := reader.intern(routeID.text(r))
+		if _, ok := data.RouteNetworks[id]; ok {
+			reader.duplicate("route_id", id)
+		}
+		data.RouteNetworks[id] = reader.intern(networkID.text(r))
+	}
+	if err := reader.Err(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Loaded %d route networks\n", len(data.RouteNetworks))
+	return nil
+}
+
+func loadStopAreas(reader *csvReader, data *model.GTFSData) error {
+	reader.require("area_id", "stop_id")
+	reader.identify("stop_id")
+	var (
+		areaID = reader.column("area_id")
+		stopID = reader.column("stop_id")
+	)
+
+	count := 0
+	for reader.Next() {
+		r := reader.Record()
+		id := reader.intern(stopID.text(r))
+		data.StopAreas[id] = append(data.StopAreas[id], reader.intern(areaID.text(r)))
+		count++
+	}
+	if err := reader.Err(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Loaded %d stop areas\n", count)
+	return nil
+}
+
+// HasFares reports whether the feed defines any fares.
+func HasFares(data *model.GTFSData) bool {
+	return len(data.FareAttributes) > 0 || len(data.FareLegRules) > 0
+}
+
+// routeNetwork returns the Fares v2 network of a route.
+func routeNetwork(data *model.GTFSData, routeID string) string {
+	if id, ok := data.RouteNetworks[routeID]; ok {
+		return id
+	}
+	if route, ok := data.Routes[routeID]; ok {
+		return route.NetworkID
+	}
+	return ""
+}
+
+// stopAreas returns the Fares v2 areas of a stop, including those assigned
+// to its parent station.
+func stopAreas(data *model.GTFSData, stopID string) []string {
+	areas := data.StopAreas[stopID]
+	if stop, ok := data.Stops[stopID]; ok && stop.ParentStation != "" {
+		areas = append(areas[:len(areas):len(areas)], data.StopAreas[stop.ParentStation]...)
+	}
+	return areas
+}
+
+// cheapestProduct returns the lowest priced fare media of a product.
+func cheapestProduct(data *model.GTFSData, id string) *model.FareProduct {
+	var best *model.FareProduct
+	for _, p := range data.FareProducts[id] {
+		if best == nil || p.Amount < best.Amount {
+			best = p
+		}
+	}
+	return best
+}
+
+// RouteFares lists the fares that can apply to rides on a route: Fares v1
+// attributes whose rules name the route or no route at all, and Fares v2
+// leg rules for the route's network.
+func RouteFares(data *model.GTFSData, routeID string) []model.RouteFare {
+	fares := []model.RouteFare{}
+	route := data.Routes[routeID]
+
+	for _, id := range sortedKeys(data.FareAttributes) {
+		fare := data.FareAttributes[id]
+		if fare.AgencyID != "" && route != nil && route.AgencyID != "" && fare.AgencyID != route.AgencyID {
+			continue
+		}
+		base := model.RouteFare{
+			FareID:           fare.FareID,
+			Price:            fare.Price,
+			Currency:         fare.CurrencyType,
+			Transfers:        &fare.Transfers,
+			TransferDuration: fare.TransferDuration,
+		}
+		rules := data.FareRules[id]
+		if len(rules) == 0 {
+			fares = append(fares, base)
+			continue
+		}
+		for _, rule := range rules {
+			if rule.RouteID != "" && rule.RouteID != routeID {
+				continue
+			}
+			f := base
+			f.OriginID = rule.OriginID
+			f.DestinationID = rule.DestinationID
+			f.ContainsID = rule.ContainsID
+			fares = append(fares, f)
+		}
+	}
+
+	network := routeNetwork(data, routeID)
+	for _, rule := range data.FareLegRules {
+		if rule.NetworkID != "" && rule.NetworkID != network {
+			continue
+		}
+		p := cheapestProduct(data, rule.FareProductID)
+		if p == nil {
+			continue
+		}
+		fares = append(fares, model.RouteFare{
+			FareID:     p.ID,
+			Name:       p.Name,
+			Price:      p.Amount,
+			Currency:   p.Currency,
+			NetworkID:  rule.NetworkID,
+			FromAreaID: rule.FromAreaID,
+			ToAreaID:   rule.ToAreaID,
+		})
+	}
+
+	sort.SliceStable(fares, func(i, j int) bool { return fares[i].Price < fares[j].Price })
+	return fares
+}
+
+// fareRide is a ride leg of a journey as seen by the fare rules.
+type fareRide struct {
+	leg      int // index into Journey.Legs
+	routeID  string
+	from, to string // stop IDs
+	zones    []string
+	dep, arr model.GTFSTime
+}
+
+// JourneyFare prices the rides of a journey. Feeds with Fares v2 leg rules
+// are priced with those, otherwise with the Fares v1 tables. It returns nil
+// when the feed defines no fares or the journey has no rides.
+func JourneyFare(data *model.GTFSData, legs []model.Leg) *model.Fare {
+	var rides []fareRide
+	for i, leg := range legs {
+		if leg.Mode != "ride" {
+			continue
+		}
+		ride := fareRide{
+			leg:     i,
+			routeID: leg.RouteID,
+			from:    leg.From.StopID,
+			to:      leg.To.StopID,
+			dep:     leg.DepartureTime,
+			arr:     leg.ArrivalTime,
+		}
+		for _, place := range append([]model.LegPlace{leg.From, leg.To}, leg.IntermediateStops...) {
+			if stop, ok := data.Stops[place.StopID]; ok && stop.ZoneID != "" {
+				ride.zones = append(ride.zones, stop.ZoneID)
+			}
+		}
+		rides = append(rides, ride)
+	}
+	if len(rides) == 0 || !HasFares(data) {
+		return nil
+	}
+
+	var components []model.FareComponent
+	if len(data.FareLegRules) > 0 {
+		components = fareV2(data, rides)
+	} else {
+		components = fareV1(data, rides)
+	}
+
+	fare := &model.Fare{Components: components, Complete: true}
+	priced := make(map[int]bool)
+	for _, c := range components {
+		if fare.Currency == "" {
+			fare.Currency = c.Currency
+		}
+		if c.Currency != fare.Currency {
+			fare.Complete = false
+			continue
+		}
+		fare.Total += c.Price
+		for _, leg := range c.Legs {
+			priced[leg] = true
+		}
+	}
+	for _, ride := range rides {
+		if !priced[ride.leg] {
+			fare.Complete = false
+		}
+	}
+	fare.Total = math.Round(fare.Total*100) / 100
+	if fare.Components == nil {
+		fare.Components = []model.FareComponent{}
+	}
+	return fare
+}
+
+// fareV1 covers the rides with the cheapest combination of fare_attributes.
+// A fare may span several consecutive rides when its transfer limits allow
+// and its rules accept the whole stretch. Rides no fare applies to are left
+// unpriced.
+func fareV1(data *model.GTFSData, rides []fareRide) []model.FareComponent {
+	n := len(rides)
+	cost := make([]float64, n+1)
+	choice := make([]*model.FareAttribute, n)
+	next := make([]int, n)
+
+	for i := n - 1; i >= 0; i-- {
+		// Leaving the ride unpriced costs nothing but is only used when no
+		// fare applies, so it starts out as an infinite cost.
+		cost[i], next[i] = math.Inf(1), i+1
+		for j := i; j < n; j++ {
+			fare := cheapestFareV1(data, rides[i:j+1])
+			if fare == nil {
+				continue
+			}
+			if c := fare.Price + cost[j+1]; c < cost[i] {
+				cost[i], choice[i], next[i] = c, fare, j+1
+			}
+		}
+		if choice[i] == nil {
+			cost[i] = cost[i+1]
+		}
+	}
+
+	var components []model.FareComponent
+	for i := 0; i < n; i = next[i] {
+		fare := choice[i]
+		if fare == nil {
+			continue
+		}
+		c := model.FareComponent{FareID: fare.FareID, Price: fare.Price, Currency: fare.CurrencyType}
+		for _, ride := range rides[i:next[i]] {
+			c.Legs = append(c.Legs, ride.leg)
+		}
+		components = append(components, c)
+	}
+	return components
+}
+
+// cheapestFareV1 returns the cheapest fare valid for a stretch of rides.
+func cheapestFareV1(data *model.GTFSData, rides []fareRide) *model.FareAttribute {
+	var best *model.FareAttribute
+	for _, id := range sortedKeys(data.FareAttributes) {
+		fare := data.FareAttributes[id]
+		if (best == nil || fare.Price < best.Price) && fareAppliesV1(data, fare, rides) {
+			best = fare
+		}
+	}
+	return best
+}
+
+// fareAppliesV1 reports whether a fare covers a stretch of rides. Every ride
+// must match a rule on route, origin zone and destination zone of the
+// stretch, and every zone passed must be listed in the fare's contains_id
+// rules if it has any. A fare without rules applies to any ride of its
+// agency.
+func fareAppliesV1(data *model.GTFSData, fare *model.FareAttribute, rides []fareRide) bool {
+	transfers := len(rides) - 1
+	if fare.Transfers >= 0 && transfers > fare.Transfers {
+		return false
+	}
+	if transfers > 0 && fare.TransferDuration > 0 && int(rides[len(rides)-1].dep-rides[0].dep) > fare.TransferDuration {
+		return false
+	}
+	if fare.AgencyID != "" {
+		for _, ride := range rides {
+			if route, ok := data.Routes[ride.routeID]; ok && route.AgencyID != "" && route.AgencyID != fare.AgencyID {
+				return false
+			}
+		}
+	}
+
+	rules := data.FareRules[fare.FareID]
+	if len(rules) == 0 {
+		return true
+	}
+
+	origin := stopZone(data, rides[0].from)
+	destination := stopZone(data, rides[len(rides)-1].to)
+	contains := make(map[string]bool)
+	hasBasic := false
+	for _, rule := range rules {
+		if rule.ContainsID != "" {
+			contains[rule.ContainsID] = true
+		} else {
+			hasBasic = true
+		}
+	}
+
+	if len(contains) > 0 {
+		for _, ride := range rides {
+			for _, zone := range ride.zones {
+				if !contains[zone] {
+					return false
+				}
+			}
+		}
+	}
+	if !hasBasic {
+		return true
+	}
+
+	for _, ride := range rides {
+		matched := false
+		for _, rule := range rules {
+			if rule.ContainsID != "" {
+				continue
+			}
+			if (rule.RouteID == "" || rule.RouteID == ride.routeID) &&
+				(rule.OriginID == "" || rule.OriginID == origin) &&
+				(rule.DestinationID == "" || rule.DestinationID == destination) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+func stopZone(data *model.GTFSData, stopID string) string {
+	if stop, ok := data.Stops[stopID]; ok {
+		return stop.ZoneID
+	}
+	return ""
+}
+
+// fareV2 prices each ride with its best matching fare leg rule, then applies
+// fare transfer rules between consecutive rides.
+func fareV2(data *model.GTFSData, rides []fareRide) []model.FareComponent {
+	var components []model.FareComponent
+	var prev *model.FareLegRule
+	chain := 0 // transfers made under the current transfer rule
+
+	for i, ride := range rides {
+		rule := matchLegRule(data, ride)
+		if rule == nil {
+			prev, chain = nil, 0
+			continue
+		}
+		leg := model.FareComponent{Legs: []int{ride.leg}}
+		if p := cheapestProduct(data, rule.FareProductID); p != nil {
+			leg.FareID, leg.Name, leg.Price, leg.Currency = p.ID, p.Name, p.Amount, p.Currency
+		}
+
+		var transfer *model.FareTransferRule
+		if prev != nil {
+			transfer = matchTransferRule(data, prev, rule, rides[i-1], ride, chain)
+		}
+		if transfer == nil {
+			components = append(components, leg)
+			prev, chain = rule, 0
+			continue
+		}
+
+		charge := model.FareComponent{Legs: []int{rides[i-1].leg, ride.leg}, Transfer: true}
+		if p := cheapestProduct(data, transfer.FareProductID); p != nil {
+			charge.FareID, charge.Name, charge.Price, charge.Currency = p.ID, p.Name, p.Amount, p.Currency
+		} else if last := len(components) - 1; last >= 0 {
+			charge.Currency = components[last].Currency
+		}
+
+		switch transfer.FareTransferType {
+		case model.TransferFromLegPlusTransfer:
+			// The earlier ride's fare plus the transfer covers this ride.
+			components = append(components, charge)
+		case model.TransferFromLegPlusTransferAndTo:
+			components = append(components, charge, leg)
+		case model.TransferOnly:
+			// The transfer product replaces the earlier ride's fare.
+			last := len(components) - 1
+			if last >= 0 && !components[last].Transfer && len(components[last].Legs) == 1 {
+				components = components[:last]
+			}
+			components = append(components, charge)
+		}
+		prev, chain = rule, chain+1
+	}
+	return components
+}
+
+// matchLegRule returns the fare leg rule for a ride: the highest priority
+// rule whose network and areas match, preferring rules that name more of
+// them over wildcards.
+func matchLegRule(data *model.GTFSData, ride fareRide) *model.FareLegRule {
+	network := routeNetwork(data, ride.routeID)
+	fromAreas := stopAreas(data, ride.from)
+	toAreas := stopAreas(data, ride.to)
+
+	var best *model.FareLegRule
+	bestScore := -1
+	for _, rule := range data.FareLegRules {
+		if rule.NetworkID != "" && rule.NetworkID != network {
+			continue
+		}
+		if rule.FromAreaID != "" && !slices.Contains(fromAreas, rule.FromAreaID) {
+			continue
+		}
+		if rule.ToAreaID != "" && !slices.Contains(toAreas, rule.ToAreaID) {
+			continue
+		}
+		score := rule.RulePriority * 4
+		for _, field := range []string{rule.NetworkID, rule.FromAreaID, rule.ToAreaID} {
+			if field != "" {
+				score++
+			}
+		}
+		if score > bestScore {
+			best, bestScore = rule, score
+		}
+	}
+	return best
+}
+
+// matchTransferRule returns the cheapest fare transfer rule allowing a
+// change from one ride to the next. chain counts the transfers already
+// made, for rules limiting consecutive transfers within a leg group.
+func matchTransferRule(data *model.GTFSData, from, to *model.FareLegRule, a, b fareRide, chain int) *model.FareTransferRule {
+	var best *model.FareTransferRule
+	bestPrice := math.Inf(1)
+	for _, rule := range data.FareTransferRules {
+		if rule.FromLegGroupID != "" && rule.FromLegGroupID != from.LegGroupID {
+			continue
+		}
+		if rule.ToLegGroupID != "" && rule.ToLegGroupID != to.LegGroupID {
+			continue
+		}
+		if rule.TransferCount >= 0 && chain+1 > rule.TransferCount {
+			continue
+		}
+		if rule.DurationLimit > 0 && int(transferDuration(rule, a, b)) > rule.DurationLimit {
+			continue
+		}
+		price := 0.0
+		if p := cheapestProduct(data, rule.FareProductID); p != nil {
+			price = p.Amount
+		}
+		if price < bestPrice {
+			best, bestPrice = rule, price
+		}
+	}
+	return best
+}
+
+// transferDuration measures a transfer as duration_limit_type asks: from
+// departure or arrival of the first ride to departure or arrival of the next.
+func transferDuration(rule *model.FareTransferRule, a, b fareRide) model.GTFSTime {
+	switch rule.DurationLimitType {
+	case 1:
+		return b.dep - a.dep
+	case 2:
+		return b.dep - a.arr
+	case 3:
+		return b.arr - a.arr
+	}
+	return b.arr - a.dep
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/rfurkan37/transport-app/backend/internal/model"
+)
+
+// rides returns a journey of ten-minute rides on route R1 from S1 to S2,
+// one leaving at each of the given times.
+func rides(deps ...string) []model.Leg {
+	var legs []model.Leg
+	for _, dep := range deps {
+		t, err := model.ParseGTFSTime(dep)
+		if err != nil {
+			panic(err)
+		}
+		legs = append(legs, model.Leg{
+			Mode:          "ride",
+			RouteID:       "R1",
+			From:          model.LegPlace{StopID: "S1"},
+			To:            model.LegPlace{StopID: "S2"},
+			DepartureTime: t,
+			ArrivalTime:   t + 600,
+		})
+	}
+	return legs
+}
+
+// fareSummary lists a fare's total and its components' fares and legs.
+func fareSummary(fare *model.Fare) string {
+	if fare == nil {
+		return "none"
+	}
+	s := fmt.Sprintf("%g", fare.Total)
+	for _, c := range fare.Components {
+		s += fmt.Sprintf(" %s%v", c.FareID, c.Legs)
+	}
+	return s
+}
+
+func TestJourneyFareV1(t *testing.T) {
+	// A single ticket allows no transfers, a transfer ticket one within
+	// an hour and a day ticket any number.
+	f := sourceFeed()
+	f["fare_attributes"] = `fare_id,price,currency_type,payment_method,transfers,transfer_duration
+SINGLE,10,TRY,0,0,
+TRANSFER,12,TRY,0,1,3600
+DAY,25,TRY,0,,
+`
+	data := loadFeed(t, f)
+
+	tests := []struct {
+		name string
+		legs []model.Leg
+		want string
+	}{
+		{"one ride", rides("08:00:00"), "10 SINGLE[0]"},
+		{"one transfer", rides("08:00:00", "08:30:00"), "12 TRANSFER[0 1]"},
+		{"transfer too late", rides("08:00:00", "09:30:00"), "20 SINGLE[0] SINGLE[1]"},
+		{"two transfers", rides("08:00:00", "08:20:00", "08:40:00"), "22 SINGLE[0] TRANSFER[1 2]"},
+		{"unlimited transfers", rides("08:00:00", "08:20:00", "08:40:00", "09:00:00", "10:00:00"), "25 DAY[0 1 2 3 4]"},
+		{"no rides", nil, "none"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fareSummary(JourneyFare(data, tt.legs)); got != tt.want {
+				t.Errorf("fare = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJourneyFareV2TransferCount(t *testing.T) {
+	tests := []struct {
+		transferCount string
+		legs          []model.Leg
+		want          string
+	}{
+		{"-1", rides("08:00:00"), "10 RIDE[0]"},
+		{"-1", rides("08:00:00", "08:20:00", "08:40:00"), "14 RIDE[0] CHANGE[0 1] CHANGE[1 2]"},
+		{"1", rides("08:00:00", "08:20:00", "08:40:00"), "22 RIDE[0] CHANGE[0 1] RIDE[2]"},
+		{"0", rides("08:00:00", "08:20:00"), "20 RIDE[0] RIDE[1]"},
+		{"-1", rides("08:00:00", "10:00:00"), "20 RIDE[0] RIDE[1]"}, // beyond the duration limit
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("transfer_count %s, %d rides", tt.transferCount, len(tt.legs)), func(t *testing.T) {
+			// Each ride costs 10; changing within an hour costs 2 instead.
+			f := sourceFeed()
+			f["fare_products"] = "fare_product_id,amount,currency\nRIDE,10,TRY\nCHANGE,2,TRY\n"
+			f["fare_leg_rules"] = "leg_group_id,fare_product_id\nALL,RIDE\n"
+			f["fare_transfer_rules"] = "from_leg_group_id,to_leg_group_id,transfer_count,duration_limit,duration_limit_type,fare_transfer_type,fare_product_id\n" +
+				"ALL,ALL," + tt.transferCount + ",3600,1,0,CHANGE\n"
+			data := loadFeed(t, f)
+
+			if got := fareSummary(JourneyFare(data, tt.legs)); got != tt.want {
+				t.Errorf("fare = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/rfurkan37/transport-app/backend/internal/geo"
+	"github.com/rfurkan37/transport-app/backend/internal/model"
+)
+
+// loadTransfers reads the GTFS transfers table. Its entries take precedence over
+// the footpaths computed by buildFootpaths.
+func loadTransfers(reader *csvReader, data *model.GTFSData) error {
+	reader.require("from_stop_id", "to_stop_id")
+	reader.identify("from_stop_id")
+	var (
+		fromStopID      = reader.column("from_stop_id")
+		toStopID        = reader.column("to_stop_id")
+		transferType    = reader.column("transfer_type")
+		minTransferTime = reader.column("min_transfer_time")
+	)
+
+	count := 0
+	for reader.Next() {
+		r := reader.Record()
+		t := &model.Transfer{
+			FromStopID:      reader.intern(fromStopID.text(r)),
+			ToStopID:        reader.intern(toStopID.text(r)),
+			Type:            transferType.int(r),
+			MinTransferTime: minTransferTime.int(r),
+			Source:          "gtfs",
+		}
+		if t.FromStopID == "" || t.ToStopID == "" {
+			continue
+		}
+		data.Transfers[t.FromStopID] = append(data.Transfers[t.FromStopID], t)
+		count++
+	}
+	if err := reader.Err(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Loaded %d transfers\n", count)
+	return nil
+}
+
+// buildFootpaths links every pair of stops within opts.FootpathRadius of
+// each other. Walking time is the straight-line distance stretched by the
+// detour factor at walking speed. Pairs already listed in transfers.csv
+// keep their GTFS values, with missing distances and times filled in.
+func buildFootpaths(data *model.GTFSData, opts LoadOptions) {
+	if opts.FootpathRadius <= 0 || opts.WalkSpeed <= 0 {
+		return
+	}
+
+	given := make(map[[2]string]bool)
+	for _, transfers := range data.Transfers {
+		for _, t := range transfers {
+			given[[2]string{t.FromStopID, t.ToStopID}] = true
+			from, okFrom := data.Stops[t.FromStopID]
+			to, okTo := data.Stops[t.ToStopID]
+			if !okFrom || !okTo {
+				continue
+			}
+			t.Distance = math.Round(geo.HaversineDistance(from.Lat, from.Lon, to.Lat, to.Lon))
+			if t.Type != 2 && t.Type != 3 && t.FromStopID != t.ToStopID {
+				t.MinTransferTime = walkingTime(t.Distance, opts)
+			}
+		}
+	}
+
+	computed := 0
+	for _, a := range data.StopsList {
+		for _, near := range data.StopIndex.Radius(a.Lat, a.Lon, opts.FootpathRadius) {
+			b := near.Item
+			if b.ID == a.ID || given[[2]string{a.ID, b.ID}] {
+				continue
+			}
+			data.Transfers[a.ID] = append(data.Transfers[a.ID], &model.Transfer{
+				FromStopID:      a.ID,
+				ToStopID:        b.ID,
+				MinTransferTime: walkingTime(near.Distance, opts),
+				Distance:        math.Round(near.Distance),
+				Source:          "computed",
+			})
+			computed++
+		}
+	}
+
+	for _, transfers := range data.Transfers {
+		sort.Slice(transfers, func(i, j int) bool {
+			return transfers[i].Distance < transfers[j].Distance
+		})
+	}
+
+	fmt.Printf("Computed %d footpaths within %.0fm\n", computed, opts.FootpathRadius)
+}
+
+func walkingTime(meters float64, opts LoadOptions) int {
+	return int(math.Ceil(meters * opts.DetourFactor / opts.WalkSpeed))
+}
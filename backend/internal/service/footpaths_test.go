@@ -0,0 +1,68 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/rfurkan37/transport-app/backend/internal/gtfstest"
+	"github.com/rfurkan37/transport-app/backend/internal/model"
+)
+
+func TestBuildFootpaths(t *testing.T) {
+	// P, Q and R lie on a line, 200 m apart; transfers.txt links P to R
+	// and forbids changing from Q to R.
+	f := gtfstest.Base()
+	f["stops"] = `stop_id,stop_name,stop_lat,stop_lon
+P,P,40.76,29.90000
+Q,Q,40.76,29.90237
+R,R,40.76,29.90474
+`
+	f["routes"] = "route_id,agency_id,route_short_name,route_type\nR1,A,1,3\n"
+	f["trips"] = "route_id,service_id,trip_id\nR1,ALL,T1\n"
+	f["stop_times"] = "trip_id,arrival_time,departure_time,stop_id,stop_sequence\nT1,08:00:00,08:00:00,P,1\nT1,08:05:00,08:05:00,R,2\n"
+	f["transfers"] = "from_stop_id,to_stop_id,transfer_type,min_transfer_time\nP,R,2,600\nQ,R,3,\n"
+	data := loadFeed(t, f)
+
+	find := func(from, to string) *model.Transfer {
+		for _, tr := range data.Transfers[from] {
+			if tr.ToStopID == to {
+				return tr
+			}
+		}
+		return nil
+	}
+
+	tests := []struct {
+		from, to   string
+		wantSource string // empty when no footpath is expected
+		wantTime   int
+		wantType   int
+	}{
+		{"P", "Q", "computed", 200, 0},
+		{"Q", "P", "computed", 200, 0},
+		{"R", "Q", "computed", 200, 0},
+		{"P", "R", "gtfs", 600, 2}, // beyond the radius, as given
+		{"R", "P", "", 0, 0},       // beyond the radius
+		{"Q", "R", "gtfs", 0, 3},   // replaces the computed footpath
+	}
+	for _, tt := range tests {
+		t.Run(tt.from+"-"+tt.to, func(t *testing.T) {
+			tr := find(tt.from, tt.to)
+			if tt.wantSource == "" {
+				if tr != nil {
+					t.Fatalf("unexpected footpath %+v", tr)
+				}
+				return
+			}
+			if tr == nil {
+				t.Fatal("no footpath")
+			}
+			if tr.Source != tt.wantSource || tr.MinTransferTime != tt.wantTime || tr.Type != tt.wantType {
+				t.Errorf("got %s, %d s, type %d; want %s, %d s, type %d",
+					tr.Source, tr.MinTransferTime, tr.Type, tt.wantSource, tt.wantTime, tt.wantType)
+			}
+			if tr.Distance < 199 || tr.Distance > 401 {
+				t.Errorf("distance = %.0f m", tr.Distance)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/rfurkan37/transport-app/backend/internal/model"
+)
+
+func loadFrequencies(reader *csvReader, data *model.GTFSData) error {
+	reader.require("trip_id", "start_time", "end_time", "headway_secs")
+	reader.identify("trip_id")
+	var (
+		tripID      = reader.column("trip_id")
+		startTime   = reader.column("start_time")
+		endTime     = reader.column("end_time")
+		headwaySecs = reader.column("headway_secs")
+		exactTimes  = reader.column("exact_times")
+	)
+
+	count := 0
+	for reader.Next() {
+		r := reader.Record()
+		f := &model.Frequency{
+			TripID:      reader.intern(tripID.text(r)),
+			StartTime:   startTime.time(r),
+			EndTime:     endTime.time(r),
+			HeadwaySecs: headwaySecs.int(r),
+			ExactTimes:  exactTimes.int(r),
+		}
+		data.Frequencies[f.TripID] = append(data.Frequencies[f.TripID], f)
+		count++
+	}
+	if err := reader.Err(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Loaded %d frequencies\n", count)
+	return nil
+}
+
+// usableFrequency reports whether a headway window can be expanded.
+func usableFrequency(f *model.Frequency) bool {
+	return f.HeadwaySecs > 0 && f.StartTime != model.NoTime && f.EndTime > f.StartTime
+}
+
+// expandFrequencies replaces each frequency-based trip with one instance per
+// departure in its headway windows, so routing and departure boards can
+// treat them like scheduled trips. An instance starts at each start_time +
+// n*headway_secs before end_time and keeps the template's travel times.
+// Instance IDs are the template ID followed by "@" and the start time. The
+// template itself moves to data.TripTemplates, so only trips that run are
+// left in data.Trips.
+func expandFrequencies(data *model.GTFSData) {
+	instances := 0
+	for tripID, freqs := range data.Frequencies {
+		template, ok := data.Trips[tripID]
+		times := data.StopTimesByTrip[tripID]
+		if !ok || len(times) == 0 || times[0].DepartureTime == model.NoTime {
+			continue
+		}
+		delete(data.StopTimesByTrip, tripID)
+		delete(data.Trips, tripID)
+		data.TripTemplates[tripID] = template
+
+		base := times[0].DepartureTime
+		for _, f := range freqs {
+			if !usableFrequency(f) {
+				continue
+			}
+			for start := f.StartTime; start < f.EndTime; start += model.GTFSTime(f.HeadwaySecs) {
+				trip := *template
+				trip.TripID = tripID + "@" + start.String()
+				trip.Frequency = f
+				data.Trips[trip.TripID] = &trip
+
+				shift := start - base
+				batch := make([]model.StopTime, len(times))
+				shifted := make([]*model.StopTime, len(times))
+				for i, st := range times {
+					batch[i] = *st
+					batch[i].TripID = trip.TripID
+					if st.ArrivalTime != model.NoTime {
+						batch[i].ArrivalTime += shift
+					}
+					if st.DepartureTime != model.NoTime {
+						batch[i].DepartureTime += shift
+					}
+					shifted[i] = &batch[i]
+				}
+				data.StopTimesByTrip[trip.TripID] = shifted
+				instances++
+			}
+		}
+	}
+
+	if instances > 0 {
+		fmt.Printf("Expanded %d frequency-based trips into %d trip instances\n", len(data.Frequencies), instances)
+	}
+}
+
+// indexHeadways summarizes the headway windows of each route's trips.
+func indexHeadways(data *model.GTFSData) {
+	seen := make(map[string]map[model.RouteHeadway]bool)
+	for tripID, freqs := range data.Frequencies {
+		trip, ok := data.TripTemplates[tripID]
+		if !ok {
+			continue
+		}
+		route, ok := data.Routes[trip.RouteID]
+		if !ok {
+			continue
+		}
+		if seen[route.ID] == nil {
+			seen[route.ID] = make(map[model.RouteHeadway]bool)
+		}
+
+		for _, f := range freqs {
+			if !usableFrequency(f) {
+				continue
+			}
+			h := model.RouteHeadway{
+				StartTime:  f.StartTime,
+				EndTime:    f.EndTime,
+				HeadwayMin: headwayMinutes(f),
+				ExactTimes: f.ExactTimes == 1,
+			}
+			if seen[route.ID][h] {
+				continue
+			}
+			seen[route.ID][h] = true
+			route.Headways = append(route.Headways, h)
+			if route.HeadwayMin == 0 || h.HeadwayMin < route.HeadwayMin {
+				route.HeadwayMin = h.HeadwayMin
+			}
+		}
+	}
+
+	for id := range seen {
+		headways := data.Routes[id].Headways
+		sort.Slice(headways, func(i, j int) bool {
+			if headways[i].StartTime != headways[j].StartTime {
+				return headways[i].StartTime < headways[j].StartTime
+			}
+			return headways[i].HeadwayMin < headways[j].HeadwayMin
+		})
+	}
+}
+
+// headwayMinutes returns a frequency's headway in minutes.
+func headwayMinutes(f *model.Frequency) float64 {
+	return float64(f.HeadwaySecs) / 60
+}
+
+// HeadwayMinutes returns the headway of a trip instance expanded from
+// frequencies.csv, or 0 for a scheduled trip.
+func HeadwayMinutes(trip *model.Trip) float64 {
+	if trip.Frequency == nil {
+		return 0
+	}
+	return headwayMinutes(trip.Frequency)
+}
+
+// Approximate reports whether a trip instance comes from a headway window
+// without exact times, so that it should be presented as running every
+// HeadwayMinutes rather than at its expanded times.
+func Approximate(trip *model.Trip) bool {
+	return trip.Frequency != nil && trip.Frequency.ExactTimes == 0
+}
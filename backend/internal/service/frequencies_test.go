@@ -0,0 +1,79 @@
+package service
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/rfurkan37/transport-app/backend/internal/gtfstest"
+	"github.com/rfurkan37/transport-app/backend/internal/model"
+)
+
+// frequencyFeed runs template trip F1 from S1 to S2 every 20 minutes from
+// 06:00 to 07:00 with exact times, and from 18:00 to 19:00 without.
+func frequencyFeed() gtfstest.Feed {
+	f := sourceFeed()
+	f["trips"] = "route_id,service_id,trip_id\nR1,ALL,F1\n"
+	f["stop_times"] = "trip_id,arrival_time,departure_time,stop_id,stop_sequence\nF1,00:00:00,00:00:00,S1,1\nF1,00:05:00,00:05:00,S2,2\n"
+	f["frequencies"] = "trip_id,start_time,end_time,headway_secs,exact_times\nF1,06:00:00,07:00:00,1200,1\nF1,18:00:00,19:00:00,1200,0\n"
+	return f
+}
+
+func TestExpandFrequencies(t *testing.T) {
+	data := loadFeed(t, frequencyFeed())
+
+	var trips []string
+	for id := range data.Trips {
+		trips = append(trips, id)
+	}
+	slices.Sort(trips)
+	want := []string{"F1@06:00:00", "F1@06:20:00", "F1@06:40:00", "F1@18:00:00", "F1@18:20:00", "F1@18:40:00"}
+	if !slices.Equal(trips, want) {
+		t.Fatalf("trips = %v, want %v", trips, want)
+	}
+	if _, ok := data.TripTemplates["F1"]; !ok {
+		t.Error("template F1 not kept in TripTemplates")
+	}
+
+	times := data.StopTimesByTrip["F1@06:20:00"]
+	if len(times) != 2 || times[0].DepartureTime.String() != "06:20:00" || times[1].ArrivalTime.String() != "06:25:00" {
+		t.Errorf("instance F1@06:20:00 has stop times %+v", times)
+	}
+	if route := data.Routes["R1"]; route.HeadwayMin != 20 || len(route.Headways) != 2 {
+		t.Errorf("route headway %v min with %d windows, want 20 min with 2", route.HeadwayMin, len(route.Headways))
+	}
+	if report := ValidateGTFS(data); report.Errors+report.Warnings > 0 {
+		t.Errorf("validation issues: %+v", report.Issues)
+	}
+}
+
+func TestScheduledDeparturesHeadways(t *testing.T) {
+	data := loadFeed(t, frequencyFeed())
+	date := time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		from string
+		want []string // departure time, headway and whether it is approximate
+	}{
+		{"exact times are listed one by one", "06:10:00", []string{
+			"06:20:00 20 false", "06:40:00 20 false", "18:00:00 20 true",
+		}},
+		{"approximate window not started", "17:00:00", []string{"18:00:00 20 true"}},
+		{"approximate window running", "18:10:00", []string{" 20 true"}},
+		{"after the last window", "19:00:00", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, _ := model.ParseGTFSTime(tt.from)
+			var got []string
+			for _, dep := range ScheduledDepartures(data, "S1", date, from, 0) {
+				got = append(got, fmt.Sprintf("%s %g %v", dep.DepartureTime, dep.HeadwayMin, dep.Approximate))
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("departures = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,43 +2,98 @@
 package service
 
 import (
-	"encoding/csv"
 	"fmt"
-	"os"
-	"path/filepath"
 	"sort"
-	"strconv"
+	"strings"
+	"sync"
 
+	"github.com/rfurkan37/transport-app/backend/internal/geo"
 	"github.com/rfurkan37/transport-app/backend/internal/model"
+	"github.com/rfurkan37/transport-app/backend/internal/spatial"
 )
 
-// LoadGTFS loads all GTFS data from a directory.
-func LoadGTFS(dataDir string) (*model.GTFSData, error) {
+// gridCellMeters is the cell size of the stop and place spatial indexes.
+const gridCellMeters = 250
+
+// LoadOptions configures derived data computed while loading a feed.
+type LoadOptions struct {
+	FootpathRadius float64 // meters; stops closer than this get a footpath
+	WalkSpeed      float64 // meters per second
+	DetourFactor   float64 // street distance relative to straight-line distance
+}
+
+// DefaultLoadOptions returns the options used when none are configured.
+func DefaultLoadOptions() LoadOptions {
+	return LoadOptions{
+		FootpathRadius: 300,
+		WalkSpeed:      1.3,
+		DetourFactor:   1.3,
+	}
+}
+
+// LoadGTFS loads all GTFS data from a feed directory or .zip archive. Tables
+// may be named either name.txt, as in the GTFS spec, or name.csv.
+func LoadGTFS(feedPath string, opts LoadOptions) (*model.GTFSData, error) {
+	feed, err := openFeed(feedPath)
+	if err != nil {
+		return nil, err
+	}
+	defer feed.Close()
+
 	data := model.NewGTFSData()
 
 	loaders := []struct {
-		file string
-		load func(string, *model.GTFSData) error
-		req  bool
+		table string
+		load  tableLoader
+		req   bool
 	}{
-		{"agency.csv", loadAgencies, true},
-		{"stops.csv", loadStops, true},
-		{"routes.csv", loadRoutes, true},
-		{"trips.csv", loadTrips, true},
-		{"calendar.csv", loadCalendar, true},
-		{"shapes.csv", loadShapes, true},
-		{"places.csv", loadPlaces, false},
-	}
-
-	for _, l := range loaders {
-		path := filepath.Join(dataDir, l.file)
-		if err := l.load(path, data); err != nil {
+		{"agency", loadAgencies, true},
+		{"stops", loadStops, true},
+		{"routes", loadRoutes, true},
+		{"trips", loadTrips, true},
+		{"calendar", loadCalendar, false},
+		{"calendar_dates", loadCalendarDates, false},
+		{"shapes", loadShapes, true},
+		{"stop_times", loadStopTimes, true},
+		{"frequencies", loadFrequencies, false},
+		{"places", loadPlaces, false},
+		{"transfers", loadTransfers, false},
+		{"fare_attributes", loadFareAttributes, false},
+		{"fare_rules", loadFareRules, false},
+		{"fare_products", loadFareProducts, false},
+		{"fare_leg_rules", loadFareLegRules, false},
+		{"fare_transfer_rules", loadFareTransferRules, false},
+		{"route_networks", loadRouteNetworks, false},
+		{"stop_areas", loadStopAreas, false},
+		{"translations", loadTranslations, false},
+		{"levels", loadLevels, false},
+		{"pathways", loadPathways, false},
+	}
+
+	// Each loader fills its own maps, so the tables are read concurrently.
+	errs := make([]error, len(loaders))
+	issues := make([][]model.ValidationIssue, len(loaders))
+	var wg sync.WaitGroup
+	for i, l := range loaders {
+		wg.Go(func() {
+			issues[i], errs[i] = loadTable(feed, l.table, l.load, data)
+		})
+	}
+	wg.Wait()
+
+	for i, l := range loaders {
+		data.LoadIssues = append(data.LoadIssues, issues[i]...)
+		if err := errs[i]; err != nil {
 			if l.req {
-				return nil, fmt.Errorf("loading %s: %w", l.file, err)
+				return nil, fmt.Errorf("loading %s: %w", l.table, err)
 			}
-			fmt.Printf("Warning: %s not loaded: %v\n", l.file, err)
+			fmt.Printf("Warning: %s not loaded: %v\n", l.table, err)
 		}
 	}
+	if len(data.Calendars) == 0 && len(data.CalendarDates) == 0 {
+		return nil, fmt.Errorf("feed has neither calendar nor calendar_dates")
+	}
+	data.Location = agencyTimezone(data)
 
 	// Build lists for iteration
 	for _, stop := range data.Stops {
@@ -47,200 +102,307 @@ func LoadGTFS(dataDir string) (*model.GTFSData, error) {
 	for _, route := range data.Routes {
 		data.RoutesList = append(data.RoutesList, route)
 	}
+	for _, place := range data.Places {
+		data.PlacesList = append(data.PlacesList, place)
+	}
+
+	// Build spatial indexes
+	data.StopIndex = spatial.NewGrid(data.StopsList, func(s *model.Stop) (float64, float64) {
+		return s.Lat, s.Lon
+	}, gridCellMeters)
+	data.PlaceIndex = spatial.NewGrid(data.PlacesList, func(p *model.Place) (float64, float64) {
+		return p.Lat, p.Lon
+	}, gridCellMeters)
+
+	indexStations(data)
+	indexStopTimes(data)
+	indexHeadways(data)
+	indexShapes(data)
+	buildFootpaths(data, opts)
 
 	return data, nil
 }
 
 // CSV reading helpers
 
-func readCSV(path string) ([][]string, map[string]int, error) {
-	file, err := os.Open(path)
-	if err != nil {
-		return nil, nil, err
-	}
-	defer file.Close()
+// tableLoader reads the records of one feed table into data.
+type tableLoader func(*csvReader, *model.GTFSData) error
 
-	reader := csv.NewReader(file)
-	records, err := reader.ReadAll()
+// loadTable opens a feed table and streams it into load, returning the
+// problems found in its records.
+func loadTable(feed feedSource, table string, load tableLoader, data *model.GTFSData) ([]model.ValidationIssue, error) {
+	rc, err := feed.open(table)
 	if err != nil {
-		return nil, nil, err
-	}
-
-	if len(records) == 0 {
-		return nil, nil, fmt.Errorf("empty CSV file")
-	}
-
-	header := make(map[string]int)
-	for i, col := range records[0] {
-		header[col] = i
-	}
-
-	return records[1:], header, nil
-}
-
-func getField(record []string, header map[string]int, field string) string {
-	if idx, ok := header[field]; ok && idx < len(record) {
-		return record[idx]
-	}
-	return ""
-}
-
-func getFieldFloat(record []string, header map[string]int, field string) float64 {
-	if s := getField(record, header, field); s != "" {
-		if f, err := strconv.ParseFloat(s, 64); err == nil {
-			return f
-		}
+		return nil, err
 	}
-	return 0
-}
+	defer rc.Close()
 
-func getFieldInt(record []string, header map[string]int, field string) int {
-	if s := getField(record, header, field); s != "" {
-		if i, err := strconv.Atoi(s); err == nil {
-			return i
-		}
+	reader, err := newCSVReader(rc, table)
+	if err != nil {
+		return nil, err
 	}
-	return 0
+	err = load(reader, data)
+	return reader.Issues(), err
 }
 
 // Individual loaders
 
-func loadAgencies(path string, data *model.GTFSData) error {
-	records, header, err := readCSV(path)
-	if err != nil {
-		return err
-	}
-
-	for _, r := range records {
+func loadAgencies(reader *csvReader, data *model.GTFSData) error {
+	reader.require("agency_name", "agency_url", "agency_timezone")
+	reader.identify("agency_id")
+	var (
+		id       = reader.column("agency_id")
+		name     = reader.column("agency_name")
+		url      = reader.column("agency_url")
+		timezone = reader.column("agency_timezone")
+		lang     = reader.column("agency_lang")
+	)
+
+	for reader.Next() {
+		r := reader.Record()
 		agency := &model.Agency{
-			ID:       getField(r, header, "agency_id"),
-			Name:     getField(r, header, "agency_name"),
-			URL:      getField(r, header, "agency_url"),
-			Timezone: getField(r, header, "agency_timezone"),
-			Lang:     getField(r, header, "agency_lang"),
+			ID:       reader.intern(id.text(r)),
+			Name:     strings.Clone(name.text(r)),
+			URL:      strings.Clone(url.text(r)),
+			Timezone: reader.intern(timezone.text(r)),
+			Lang:     reader.intern(lang.text(r)),
+		}
+		if _, ok := data.Agencies[agency.ID]; ok {
+			reader.duplicate("agency_id", agency.ID)
 		}
 		data.Agencies[agency.ID] = agency
 	}
+	if err := reader.Err(); err != nil {
+		return err
+	}
 
 	fmt.Printf("Loaded %d agencies\n", len(data.Agencies))
 	return nil
 }
 
-func loadStops(path string, data *model.GTFSData) error {
-	records, header, err := readCSV(path)
-	if err != nil {
-		return err
-	}
-
-	for _, r := range records {
+func loadStops(reader *csvReader, data *model.GTFSData) error {
+	reader.require("stop_id")
+	reader.identify("stop_id")
+	var (
+		id            = reader.column("stop_id")
+		name          = reader.column("stop_name")
+		lat           = reader.column("stop_lat")
+		lon           = reader.column("stop_lon")
+		wheelchair    = reader.column("wheelchair_boarding")
+		url           = reader.column("stop_url")
+		locationType  = reader.column("location_type")
+		parentStation = reader.column("parent_station")
+		zoneID        = reader.column("zone_id")
+		levelID       = reader.column("level_id")
+		platformCode  = reader.column("platform_code")
+	)
+
+	for reader.Next() {
+		r := reader.Record()
 		stop := &model.Stop{
-			ID:                 getField(r, header, "stop_id"),
-			Name:               getField(r, header, "stop_name"),
-			Lat:                getFieldFloat(r, header, "stop_lat"),
-			Lon:                getFieldFloat(r, header, "stop_lon"),
-			WheelchairBoarding: getFieldInt(r, header, "wheelchair_boarding"),
-			URL:                getField(r, header, "stop_url"),
-			LocationType:       getFieldInt(r, header, "location_type"),
-			ParentStation:      getField(r, header, "parent_station"),
+			ID:                 reader.intern(id.text(r)),
+			Name:               strings.Clone(name.text(r)),
+			Lat:                lat.float(r),
+			Lon:                lon.float(r),
+			WheelchairBoarding: wheelchair.int(r),
+			URL:                strings.Clone(url.text(r)),
+			LocationType:       locationType.int(r),
+			ParentStation:      reader.intern(parentStation.text(r)),
+			ZoneID:             reader.intern(zoneID.text(r)),
+			LevelID:            reader.intern(levelID.text(r)),
+			PlatformCode:       strings.Clone(platformCode.text(r)),
+		}
+		if _, ok := data.Stops[stop.ID]; ok {
+			reader.duplicate("stop_id", stop.ID)
 		}
 		data.Stops[stop.ID] = stop
 	}
+	if err := reader.Err(); err != nil {
+		return err
+	}
 
 	fmt.Printf("Loaded %d stops\n", len(data.Stops))
 	return nil
 }
 
-func loadRoutes(path string, data *model.GTFSData) error {
-	records, header, err := readCSV(path)
-	if err != nil {
-		return err
-	}
-
-	for _, r := range records {
+func loadRoutes(reader *csvReader, data *model.GTFSData) error {
+	reader.require("route_id", "route_type")
+	reader.identify("route_id")
+	var (
+		id        = reader.column("route_id")
+		agencyID  = reader.column("agency_id")
+		shortName = reader.column("route_short_name")
+		longName  = reader.column("route_long_name")
+		routeType = reader.column("route_type")
+		desc      = reader.column("route_desc")
+		color     = reader.column("route_color")
+		textColor = reader.column("route_text_color")
+		url       = reader.column("route_url")
+		networkID = reader.column("network_id")
+	)
+
+	for reader.Next() {
+		r := reader.Record()
 		route := &model.Route{
-			ID:        getField(r, header, "route_id"),
-			AgencyID:  getField(r, header, "agency_id"),
-			ShortName: getField(r, header, "route_short_name"),
-			LongName:  getField(r, header, "route_long_name"),
-			Type:      getFieldInt(r, header, "route_type"),
-			Desc:      getField(r, header, "route_desc"),
-			Color:     getField(r, header, "route_color"),
-			TextColor: getField(r, header, "route_text_color"),
-			URL:       getField(r, header, "route_url"),
+			ID:        reader.intern(id.text(r)),
+			AgencyID:  reader.intern(agencyID.text(r)),
+			ShortName: strings.Clone(shortName.text(r)),
+			LongName:  strings.Clone(longName.text(r)),
+			Type:      routeType.int(r),
+			Desc:      strings.Clone(desc.text(r)),
+			Color:     reader.intern(color.text(r)),
+			TextColor: reader.intern(textColor.text(r)),
+			URL:       strings.Clone(url.text(r)),
+			NetworkID: reader.intern(networkID.text(r)),
+		}
+		if _, ok := data.Routes[route.ID]; ok {
+			reader.duplicate("route_id", route.ID)
 		}
 		data.Routes[route.ID] = route
 	}
+	if err := reader.Err(); err != nil {
+		return err
+	}
 
 	fmt.Printf("Loaded %d routes\n", len(data.Routes))
 	return nil
 }
 
-func loadTrips(path string, data *model.GTFSData) error {
-	records, header, err := readCSV(path)
-	if err != nil {
-		return err
-	}
-
-	for _, r := range records {
+func loadTrips(reader *csvReader, data *model.GTFSData) error {
+	reader.require("route_id", "service_id", "trip_id")
+	reader.identify("trip_id")
+	var (
+		routeID     = reader.column("route_id")
+		serviceID   = reader.column("service_id")
+		tripID      = reader.column("trip_id")
+		directionID = reader.column("direction_id")
+		shapeID     = reader.column("shape_id")
+		headsign    = reader.column("trip_headsign")
+		shortName   = reader.column("trip_short_name")
+		wheelchair  = reader.column("wheelchair_accessible")
+		bikes       = reader.column("bikes_allowed")
+	)
+
+	for reader.Next() {
+		r := reader.Record()
 		trip := &model.Trip{
-			RouteID:              getField(r, header, "route_id"),
-			ServiceID:            getField(r, header, "service_id"),
-			TripID:               getField(r, header, "trip_id"),
-			DirectionID:          getFieldInt(r, header, "direction_id"),
-			ShapeID:              getField(r, header, "shape_id"),
-			Headsign:             getField(r, header, "trip_headsign"),
-			ShortName:            getField(r, header, "trip_short_name"),
-			WheelchairAccessible: getFieldInt(r, header, "wheelchair_accessible"),
-			BikesAllowed:         getFieldInt(r, header, "bikes_allowed"),
+			RouteID:              reader.intern(routeID.text(r)),
+			ServiceID:            reader.intern(serviceID.text(r)),
+			TripID:               reader.intern(tripID.text(r)),
+			DirectionID:          directionID.int(r),
+			ShapeID:              reader.intern(shapeID.text(r)),
+			Headsign:             reader.intern(headsign.text(r)),
+			ShortName:            reader.intern(shortName.text(r)),
+			WheelchairAccessible: wheelchair.int(r),
+			BikesAllowed:         bikes.int(r),
+		}
+		if _, ok := data.Trips[trip.TripID]; ok {
+			reader.duplicate("trip_id", trip.TripID)
 		}
 		data.Trips[trip.TripID] = trip
 	}
+	if err := reader.Err(); err != nil {
+		return err
+	}
 
 	fmt.Printf("Loaded %d trips\n", len(data.Trips))
 	return nil
 }
 
-func loadCalendar(path string, data *model.GTFSData) error {
-	records, header, err := readCSV(path)
-	if err != nil {
-		return err
-	}
-
-	for _, r := range records {
+func loadCalendar(reader *csvReader, data *model.GTFSData) error {
+	reader.require("service_id", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday", "sunday", "start_date", "end_date")
+	reader.identify("service_id")
+	var (
+		serviceID = reader.column("service_id")
+		monday    = reader.column("monday")
+		tuesday   = reader.column("tuesday")
+		wednesday = reader.column("wednesday")
+		thursday  = reader.column("thursday")
+		friday    = reader.column("friday")
+		saturday  = reader.column("saturday")
+		sunday    = reader.column("sunday")
+		startDate = reader.column("start_date")
+		endDate   = reader.column("end_date")
+	)
+
+	for reader.Next() {
+		r := reader.Record()
 		cal := &model.Calendar{
-			ServiceID: getField(r, header, "service_id"),
-			Monday:    getFieldInt(r, header, "monday"),
-			Tuesday:   getFieldInt(r, header, "tuesday"),
-			Wednesday: getFieldInt(r, header, "wednesday"),
-			Thursday:  getFieldInt(r, header, "thursday"),
-			Friday:    getFieldInt(r, header, "friday"),
-			Saturday:  getFieldInt(r, header, "saturday"),
-			Sunday:    getFieldInt(r, header, "sunday"),
-			StartDate: getField(r, header, "start_date"),
-			EndDate:   getField(r, header, "end_date"),
+			ServiceID: reader.intern(serviceID.text(r)),
+			Monday:    monday.int(r),
+			Tuesday:   tuesday.int(r),
+			Wednesday: wednesday.int(r),
+			Thursday:  thursday.int(r),
+			Friday:    friday.int(r),
+			Saturday:  saturday.int(r),
+			Sunday:    sunday.int(r),
+			StartDate: reader.intern(startDate.text(r)),
+			EndDate:   reader.intern(endDate.text(r)),
+		}
+		if _, ok := data.Calendars[cal.ServiceID]; ok {
+			reader.duplicate("service_id", cal.ServiceID)
 		}
 		data.Calendars[cal.ServiceID] = cal
 	}
+	if err := reader.Err(); err != nil {
+		return err
+	}
 
 	fmt.Printf("Loaded %d calendar entries\n", len(data.Calendars))
 	return nil
 }
 
-func loadShapes(path string, data *model.GTFSData) error {
-	records, header, err := readCSV(path)
-	if err != nil {
+func loadCalendarDates(reader *csvReader, data *model.GTFSData) error {
+	reader.require("service_id", "date", "exception_type")
+	reader.identify("service_id")
+	var (
+		serviceID     = reader.column("service_id")
+		date          = reader.column("date")
+		exceptionType = reader.column("exception_type")
+	)
+
+	count := 0
+	for reader.Next() {
+		r := reader.Record()
+		cd := &model.CalendarDate{
+			ServiceID:     reader.intern(serviceID.text(r)),
+			Date:          reader.intern(date.text(r)),
+			ExceptionType: exceptionType.int(r),
+		}
+		data.CalendarDates[cd.Date] = append(data.CalendarDates[cd.Date], cd)
+		count++
+	}
+	if err := reader.Err(); err != nil {
 		return err
 	}
 
-	for _, r := range records {
+	fmt.Printf("Loaded %d calendar date exceptions\n", count)
+	return nil
+}
+
+func loadShapes(reader *csvReader, data *model.GTFSData) error {
+	reader.require("shape_id", "shape_pt_lat", "shape_pt_lon", "shape_pt_sequence")
+	reader.identify("shape_id")
+	var (
+		shapeID  = reader.column("shape_id")
+		lat      = reader.column("shape_pt_lat")
+		lon      = reader.column("shape_pt_lon")
+		sequence = reader.column("shape_pt_sequence")
+	)
+
+	for reader.Next() {
+		r := reader.Record()
 		point := model.ShapePoint{
-			ShapeID:  getField(r, header, "shape_id"),
-			Lat:      getFieldFloat(r, header, "shape_pt_lat"),
-			Lon:      getFieldFloat(r, header, "shape_pt_lon"),
-			Sequence: getFieldInt(r, header, "shape_pt_sequence"),
+			ShapeID:  reader.intern(shapeID.text(r)),
+			Lat:      lat.float(r),
+			Lon:      lon.float(r),
+			Sequence: sequence.int(r),
 		}
 		data.Shapes[point.ShapeID] = append(data.Shapes[point.ShapeID], point)
 	}
+	if err := reader.Err(); err != nil {
+		return err
+	}
 
 	// Sort shape points by sequence
 	for shapeID := range data.Shapes {
@@ -253,25 +415,192 @@ func loadShapes(path string, data *model.GTFSData) error {
 	return nil
 }
 
-func loadPlaces(path string, data *model.GTFSData) error {
-	records, header, err := readCSV(path)
-	if err != nil {
-		return err
+// indexShapes records which shapes each route uses and their bounds.
+func indexShapes(data *model.GTFSData) {
+	seen := make(map[string]bool)
+	for _, trip := range data.Trips {
+		if trip.ShapeID == "" || seen[trip.RouteID+"|"+trip.ShapeID] {
+			continue
+		}
+		if _, ok := data.Shapes[trip.ShapeID]; !ok {
+			continue
+		}
+		seen[trip.RouteID+"|"+trip.ShapeID] = true
+		data.RouteShapes[trip.RouteID] = append(data.RouteShapes[trip.RouteID], trip.ShapeID)
+	}
+	for _, shapeIDs := range data.RouteShapes {
+		sort.Strings(shapeIDs)
 	}
 
-	for _, r := range records {
+	for shapeID, points := range data.Shapes {
+		coords := make([][2]float64, len(points))
+		for i, p := range points {
+			coords[i] = [2]float64{p.Lon, p.Lat}
+		}
+		data.ShapeBounds[shapeID] = geo.Bounds(coords)
+	}
+}
+
+func loadPlaces(reader *csvReader, data *model.GTFSData) error {
+	reader.header = placeSchema.resolve(reader.header)
+	reader.identify("place_id")
+	var (
+		id           = reader.column("place_id")
+		name         = reader.column("place_name")
+		lat          = reader.column("place_lat")
+		lon          = reader.column("place_lon")
+		placeType    = reader.column("place_type")
+		address      = reader.column("address")
+		district     = reader.column("district")
+		terminalNo   = reader.column("terminal_no")
+		terminalType = reader.column("terminal_type")
+	)
+
+	for reader.Next() {
+		r := reader.Record()
 		place := &model.Place{
-			ID:   getField(r, header, "place_id"),
-			Name: getField(r, header, "place_name"),
-			Lat:  getFieldFloat(r, header, "place_lat"),
-			Lon:  getFieldFloat(r, header, "place_lon"),
-			Type: getField(r, header, "place_type"),
+			ID:           id.text(r),
+			Name:         strings.Clone(name.text(r)),
+			Lat:          lat.float(r),
+			Lon:          lon.float(r),
+			Type:         reader.intern(placeType.text(r)),
+			Address:      strings.Clone(nullable(address.text(r))),
+			District:     reader.intern(nullable(district.text(r))),
+			TerminalNo:   strings.Clone(nullable(terminalNo.text(r))),
+			TerminalType: reader.intern(nullable(terminalType.text(r))),
+		}
+		if place.Type == "" {
+			place.Type = "kiosk"
+		}
+		// One kiosk owner can run many terminals at different locations.
+		if place.TerminalNo != "" {
+			place.ID += "-" + place.TerminalNo
+		} else {
+			place.ID = strings.Clone(place.ID)
 		}
 		if place.ID != "" {
 			data.Places[place.ID] = place
 		}
 	}
+	if err := reader.Err(); err != nil {
+		return err
+	}
 
 	fmt.Printf("Loaded %d places\n", len(data.Places))
 	return nil
 }
+
+// stopTimeBatch is how many stop times loadStopTimes allocates at once.
+const stopTimeBatch = 4096
+
+func loadStopTimes(reader *csvReader, data *model.GTFSData) error {
+	reader.require("trip_id", "stop_id", "stop_sequence")
+	reader.identify("trip_id")
+	var (
+		tripID        = reader.column("trip_id")
+		stopID        = reader.column("stop_id")
+		stopSequence  = reader.column("stop_sequence")
+		arrivalTime   = reader.column("arrival_time")
+		departureTime = reader.column("departure_time")
+		stopHeadsign  = reader.column("stop_headsign")
+		pickupType    = reader.column("pickup_type")
+		dropOffType   = reader.column("drop_off_type")
+		distTraveled  = reader.column("shape_dist_traveled")
+	)
+
+	// Stop times are allocated in batches rather than one by one, and
+	// consecutive rows of the same trip share its slice lookup.
+	var batch []model.StopTime
+	var lastTrip string
+	var times []*model.StopTime
+	count := 0
+	for reader.Next() {
+		r := reader.Record()
+		if len(batch) == cap(batch) {
+			batch = make([]model.StopTime, 0, stopTimeBatch)
+		}
+		batch = append(batch, model.StopTime{
+			TripID:            reader.intern(tripID.text(r)),
+			StopID:            reader.intern(stopID.text(r)),
+			StopSequence:      stopSequence.int(r),
+			ArrivalTime:       arrivalTime.time(r),
+			DepartureTime:     departureTime.time(r),
+			StopHeadsign:      reader.intern(stopHeadsign.text(r)),
+			PickupType:        pickupType.int(r),
+			DropOffType:       dropOffType.int(r),
+			ShapeDistTraveled: distTraveled.float(r),
+		})
+		st := &batch[len(batch)-1]
+
+		if st.TripID != lastTrip {
+			if lastTrip != "" {
+				data.StopTimesByTrip[lastTrip] = times
+			}
+			lastTrip, times = st.TripID, data.StopTimesByTrip[st.TripID]
+		}
+		times = append(times, st)
+		count++
+	}
+	if err := reader.Err(); err != nil {
+		return err
+	}
+	if lastTrip != "" {
+		data.StopTimesByTrip[lastTrip] = times
+	}
+
+	fmt.Printf("Loaded %d stop times\n", count)
+	return nil
+}
+
+// indexStopTimes sorts each trip's stop times, fills in untimed stops,
+// expands frequency-based trips and builds the per-stop index sorted by
+// departure time.
+func indexStopTimes(data *model.GTFSData) {
+	for _, times := range data.StopTimesByTrip {
+		sort.Slice(times, func(i, j int) bool {
+			return times[i].StopSequence < times[j].StopSequence
+		})
+		interpolateStopTimes(times)
+	}
+	expandFrequencies(data)
+
+	for _, times := range data.StopTimesByTrip {
+		for _, st := range times {
+			data.StopTimesByStop[st.StopID] = append(data.StopTimesByStop[st.StopID], st)
+		}
+	}
+
+	for _, times := range data.StopTimesByStop {
+		sort.Slice(times, func(i, j int) bool {
+			return times[i].DepartureTime < times[j].DepartureTime
+		})
+	}
+}
+
+// interpolateStopTimes assigns times to stops that have neither an arrival nor
+// a departure by interpolating linearly between the surrounding timed stops.
+func interpolateStopTimes(times []*model.StopTime) {
+	for _, st := range times {
+		if st.ArrivalTime == model.NoTime {
+			st.ArrivalTime = st.DepartureTime
+		}
+		if st.DepartureTime == model.NoTime {
+			st.DepartureTime = st.ArrivalTime
+		}
+	}
+
+	prev := -1
+	for i, st := range times {
+		if st.DepartureTime == model.NoTime {
+			continue
+		}
+		if prev >= 0 && i-prev > 1 {
+			from, to := times[prev].DepartureTime, st.ArrivalTime
+			for k := prev + 1; k < i; k++ {
+				t := from + (to-from)*model.GTFSTime(k-prev)/model.GTFSTime(i-prev)
+				times[k].ArrivalTime, times[k].DepartureTime = t, t
+			}
+		}
+		prev = i
+	}
+}
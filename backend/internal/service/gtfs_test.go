@@ -0,0 +1,116 @@
+package service
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rfurkan37/transport-app/backend/internal/gtfstest"
+	"github.com/rfurkan37/transport-app/backend/internal/model"
+)
+
+// loadFeed loads a hand-written feed with the default options.
+func loadFeed(t *testing.T, f gtfstest.Feed) *model.GTFSData {
+	t.Helper()
+	data, err := LoadGTFS(gtfstest.Write(t, f), DefaultLoadOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+var benchStopTimes = flag.Int("bench.stoptimes", 2_000_000, "stop_times rows in the synthetic feed of the load benchmarks")
+
+// Shape of the synthetic feed
+const (
+	syntheticStops      = 5000
+	syntheticTripLength = 40
+)
+
+// writeSyntheticFeed writes a feed of syntheticStops stops and as many
+// trips of syntheticTripLength stops as it takes to reach rows stop times.
+// It returns the size of stop_times.txt in bytes.
+func writeSyntheticFeed(tb testing.TB, dir string, rows int) int64 {
+	tb.Helper()
+	write := func(name string, fill func(w *bufio.Writer)) int64 {
+		f, err := os.Create(filepath.Join(dir, name))
+		if err != nil {
+			tb.Fatal(err)
+		}
+		defer f.Close()
+		w := bufio.NewWriterSize(f, 1<<20)
+		fill(w)
+		if err := w.Flush(); err != nil {
+			tb.Fatal(err)
+		}
+		info, err := f.Stat()
+		if err != nil {
+			tb.Fatal(err)
+		}
+		return info.Size()
+	}
+
+	trips := (rows + syntheticTripLength - 1) / syntheticTripLength
+	write("agency.txt", func(w *bufio.Writer) {
+		w.WriteString("agency_id,agency_name,agency_url,agency_timezone\nA,Synthetic,https://example.com,Europe/Istanbul\n")
+	})
+	write("calendar.txt", func(w *bufio.Writer) {
+		w.WriteString("service_id,monday,tuesday,wednesday,thursday,friday,saturday,sunday,start_date,end_date\nALL,1,1,1,1,1,1,1,20260101,20271231\n")
+	})
+	write("shapes.txt", func(w *bufio.Writer) {
+		w.WriteString("shape_id,shape_pt_lat,shape_pt_lon,shape_pt_sequence\n")
+	})
+	write("stops.txt", func(w *bufio.Writer) {
+		w.WriteString("stop_id,stop_name,stop_lat,stop_lon\n")
+		for i := range syntheticStops {
+			fmt.Fprintf(w, "S%d,Stop %d,%.6f,%.6f\n", i, i, 40.70+float64(i/100)*0.002, 29.80+float64(i%100)*0.003)
+		}
+	})
+	write("routes.txt", func(w *bufio.Writer) {
+		w.WriteString("route_id,agency_id,route_short_name,route_type\n")
+		for i := range 100 {
+			fmt.Fprintf(w, "R%d,A,%d,3\n", i, i)
+		}
+	})
+	write("trips.txt", func(w *bufio.Writer) {
+		w.WriteString("route_id,service_id,trip_id,direction_id\n")
+		for i := range trips {
+			fmt.Fprintf(w, "R%d,ALL,T%d,%d\n", i%100, i, i%2)
+		}
+	})
+	return write("stop_times.txt", func(w *bufio.Writer) {
+		w.WriteString("trip_id,arrival_time,departure_time,stop_id,stop_sequence\n")
+		for row := range rows {
+			trip, seq := row/syntheticTripLength, row%syntheticTripLength
+			t := model.GTFSTime(5*3600 + (trip%1000)*60 + seq*90)
+			fmt.Fprintf(w, "T%d,%s,%s,S%d,%d\n", trip, t, t, (trip*7+seq)%syntheticStops, seq+1)
+		}
+	})
+}
+
+func BenchmarkLoadGTFS(b *testing.B) {
+	dir := b.TempDir()
+	b.SetBytes(writeSyntheticFeed(b, dir, *benchStopTimes))
+
+	for b.Loop() {
+		if _, err := LoadGTFS(dir, DefaultLoadOptions()); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(*benchStopTimes)*float64(b.N)/b.Elapsed().Seconds(), "rows/s")
+}
+
+func BenchmarkLoadStopTimes(b *testing.B) {
+	dir := b.TempDir()
+	b.SetBytes(writeSyntheticFeed(b, dir, *benchStopTimes))
+
+	for b.Loop() {
+		if _, err := loadTable(dirSource{dir: dir}, "stop_times", loadStopTimes, model.NewGTFSData()); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(*benchStopTimes)*float64(b.N)/b.Elapsed().Seconds(), "rows/s")
+}
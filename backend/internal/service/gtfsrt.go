@@ -0,0 +1,333 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rfurkan37/transport-app/backend/internal/gtfsrt"
+	"github.com/rfurkan37/transport-app/backend/internal/model"
+)
+
+const (
+	gtfsRTRefresh         = 15 * time.Second // how long a downloaded feed is reused
+	gtfsRTTimeout         = 10 * time.Second // per download
+	gtfsRTMaxSize         = 64 << 20         // bytes
+	gtfsRTArrivalsLimit   = 20
+	gtfsRTPassedTolerance = time.Minute // arrivals this late may still be at the stop
+)
+
+// GTFSRealtime reads arrivals and vehicle positions from GTFS-Realtime
+// TripUpdates and VehiclePositions feeds, matching them to the static feed
+// by trip, route and stop IDs.
+type GTFSRealtime struct {
+	tripUpdates *rtFeed
+	positions   *rtFeed
+}
+
+// NewGTFSRealtime creates a provider for the given feed URLs. Either may be
+// empty, leaving the provider without arrivals or vehicles.
+func NewGTFSRealtime(tripUpdatesURL, vehiclePositionsURL string) *GTFSRealtime {
+	g := &GTFSRealtime{}
+	if tripUpdatesURL != "" {
+		g.tripUpdates = newRTFeed(tripUpdatesURL)
+	}
+	if vehiclePositionsURL != "" {
+		g.positions = newRTFeed(vehiclePositionsURL)
+	}
+	return g
+}
+
+// Provider returns the provider with the parts its URLs allow.
+func (g *GTFSRealtime) Provider() RealtimeProvider {
+	var p RealtimeProvider
+	if g.tripUpdates != nil {
+		p.Arrivals = g
+	}
+	if g.positions != nil {
+		p.Vehicles = g
+	}
+	return p
+}
+
+// rtFeed is a GTFS-Realtime feed URL and its latest download, shared by
+// all requests until it is gtfsRTRefresh old.
+type rtFeed struct {
+	url        string
+	httpClient *http.Client
+
+	mu      sync.Mutex
+	msg     *gtfsrt.FeedMessage
+	fetched time.Time
+}
+
+func newRTFeed(url string) *rtFeed {
+	return &rtFeed{url: url, httpClient: &http.Client{Timeout: gtfsRTTimeout}}
+}
+
+// get returns the feed, downloading it again when the last copy is too old.
+// Requests arriving during a download wait for it.
+func (f *rtFeed) get(ctx context.Context) (*gtfsrt.FeedMessage, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.msg != nil && time.Since(f.fetched) < gtfsRTRefresh {
+		return f.msg, nil
+	}
+
+	msg, err := f.download(ctx)
+	if err != nil {
+		return nil, err
+	}
+	f.msg, f.fetched = msg, time.Now()
+	return msg, nil
+}
+
+func (f *rtFeed) download(ctx context.Context) (*gtfsrt.FeedMessage, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", f.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Accept", "application/x-protobuf")
+
+	resp, err := f.httpClient.Do(req)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) || ctx.Err() != nil {
+			return nil, fmt.Errorf("%w: %v", ErrRealtimeTimeout, err)
+		}
+		return nil, fmt.Errorf("%w: %v", ErrRealtimeUnavailable, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: GTFS-Realtime feed returned status code %d", ErrRealtimeUnavailable, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, gtfsRTMaxSize))
+	if err != nil {
+		return nil, fmt.Errorf("%w: reading GTFS-Realtime feed: %v", ErrRealtimeUnavailable, err)
+	}
+	msg, err := gtfsrt.Decode(body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrRealtimeBadPayload, err)
+	}
+	return msg, nil
+}
+
+// StopArrivals implements ArrivalsProvider from trip updates. Trips the
+// static feed does not know, such as added trips, are left out.
+func (g *GTFSRealtime) StopArrivals(ctx context.Context, q ArrivalsQuery) ([]model.StopArrival, error) {
+	if g.tripUpdates == nil {
+		return nil, fmt.Errorf("%w: no GTFS-Realtime trip updates feed", ErrRealtimeUnsupported)
+	}
+	msg, err := g.tripUpdates.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	data := q.Data
+	loc := AgencyLocation(data)
+	now := time.Now().In(loc)
+	stops := map[string]bool{q.Stop.ID: true}
+	for _, child := range data.StopChildren[q.Stop.ID] {
+		stops[child] = true
+	}
+
+	type predicted struct {
+		at      time.Time
+		arrival model.StopArrival
+	}
+	var found []predicted
+	for _, e := range msg.Entities {
+		tu := e.TripUpdate
+		if tu == nil || e.IsDeleted || tu.Trip.ScheduleRelationship == gtfsrt.TripCanceled {
+			continue
+		}
+		trip, ok := data.Trips[tu.Trip.TripID]
+		if !ok {
+			continue
+		}
+		if route, ok := data.Routes[trip.RouteID]; !ok || !servesAgencies(data, route, q.Agencies) {
+			continue
+		}
+		stopTimes := data.StopTimesByTrip[trip.TripID]
+		idx := -1
+		for i, st := range stopTimes {
+			if stops[st.StopID] {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 {
+			continue
+		}
+
+		day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+		if tu.Trip.StartDate != "" {
+			if d, err := ParseServiceDate(tu.Trip.StartDate, loc); err == nil {
+				day = d
+			}
+		}
+		at, ok := predictedArrival(tu, stopTimes, idx, day)
+		if !ok || at.Before(now.Add(-gtfsRTPassedTolerance)) {
+			continue
+		}
+		found = append(found, predicted{at, newArrival(data, trip, stopTimes[idx].StopHeadsign, q.Lang, at.In(loc), ProviderGTFSRT)})
+	}
+
+	sort.SliceStable(found, func(i, j int) bool { return found[i].at.Before(found[j].at) })
+	arrivals := make([]model.StopArrival, 0, min(len(found), gtfsRTArrivalsLimit))
+	for _, p := range found[:min(len(found), gtfsRTArrivalsLimit)] {
+		arrivals = append(arrivals, p.arrival)
+	}
+	return arrivals, nil
+}
+
+// predictedArrival returns when a trip reaches its idx-th stop: the time
+// predicted for that stop if the update has one, else the timetable shifted
+// by the delay of the closest update before it. ok is false when the trip
+// skips the stop, or has already passed it as its updates only cover later
+// stops.
+func predictedArrival(tu *gtfsrt.TripUpdate, stopTimes []*model.StopTime, idx int, day time.Time) (time.Time, bool) {
+	scheduled := func(st *model.StopTime) time.Time {
+		t := st.ArrivalTime
+		if t < 0 {
+			t = st.DepartureTime
+		}
+		return day.Add(time.Duration(t) * time.Second)
+	}
+
+	var delay time.Duration
+	if tu.HasDelay {
+		delay = time.Duration(tu.Delay) * time.Second
+	}
+	last, lastIdx := (*gtfsrt.StopTimeUpdate)(nil), -1
+	for _, u := range tu.StopTimeUpdates {
+		i := updateIndex(u, stopTimes)
+		if i < 0 || i > idx {
+			continue
+		}
+		if i >= lastIdx {
+			last, lastIdx = u, i
+		}
+	}
+	if last == nil && len(tu.StopTimeUpdates) > 0 {
+		return time.Time{}, false
+	}
+
+	if last != nil {
+		if lastIdx == idx && last.ScheduleRelationship == gtfsrt.StopSkipped {
+			return time.Time{}, false
+		}
+		// At the stop itself the arrival counts; before it the departure
+		// is what carries over.
+		ev := cmp.Or(last.Departure, last.Arrival)
+		if lastIdx == idx {
+			ev = cmp.Or(last.Arrival, last.Departure)
+		}
+		switch {
+		case ev == nil || last.ScheduleRelationship == gtfsrt.StopNoData:
+			delay = 0
+		case ev.Time > 0 && lastIdx == idx:
+			return time.Unix(ev.Time, 0), true
+		case ev.Time > 0:
+			delay = time.Unix(ev.Time, 0).Sub(scheduled(stopTimes[lastIdx]))
+		default:
+			delay = time.Duration(ev.Delay) * time.Second
+		}
+	}
+	return scheduled(stopTimes[idx]).Add(delay), true
+}
+
+// updateIndex finds the stop time a stop time update is for, by stop
+// sequence or else by stop ID, or returns -1.
+func updateIndex(u *gtfsrt.StopTimeUpdate, stopTimes []*model.StopTime) int {
+	for i, st := range stopTimes {
+		if u.HasStopSequence && st.StopSequence == u.StopSequence ||
+			!u.HasStopSequence && st.StopID == u.StopID {
+			return i
+		}
+	}
+	return -1
+}
+
+// Vehicles implements VehiclesProvider from vehicle positions. Vehicles
+// heading to a stop are those on a trip that still has the stop ahead.
+func (g *GTFSRealtime) Vehicles(ctx context.Context, q VehiclesQuery) ([]model.Vehicle, error) {
+	if g.positions == nil {
+		return nil, fmt.Errorf("%w: no GTFS-Realtime vehicle positions feed", ErrRealtimeUnsupported)
+	}
+	msg, err := g.positions.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	data := q.Data
+	vehicles := []model.Vehicle{}
+	for _, e := range msg.Entities {
+		vp := e.Vehicle
+		if vp == nil || vp.Position == nil || e.IsDeleted {
+			continue
+		}
+		trip := data.Trips[vp.Trip.TripID]
+		routeID := vp.Trip.RouteID
+		if routeID == "" && trip != nil {
+			routeID = trip.RouteID
+		}
+		route := data.Routes[routeID]
+		if q.Agencies != nil && (route == nil || !servesAgencies(data, route, q.Agencies)) {
+			continue
+		}
+		if q.RouteID != "" && routeID != q.RouteID {
+			continue
+		}
+		if q.StopID != "" && (trip == nil || !stopAhead(vp, data.StopTimesByTrip[trip.TripID], q.StopID)) {
+			continue
+		}
+
+		v := model.Vehicle{
+			ID:      cmp.Or(vp.Vehicle.ID, e.ID),
+			Label:   vp.Vehicle.Label,
+			RouteID: routeID,
+			TripID:  vp.Trip.TripID,
+			Lat:     vp.Position.Latitude,
+			Lon:     vp.Position.Longitude,
+			Bearing: vp.Position.Bearing,
+			Speed:   vp.Position.Speed,
+			StopID:  vp.StopID,
+			Source:  ProviderGTFSRT,
+		}
+		if route != nil {
+			v.RouteCode = route.ShortName
+		}
+		if vp.Timestamp > 0 {
+			v.Timestamp = time.Unix(vp.Timestamp, 0).UTC()
+		}
+		vehicles = append(vehicles, v)
+	}
+
+	sort.Slice(vehicles, func(i, j int) bool { return vehicles[i].ID < vehicles[j].ID })
+	return vehicles, nil
+}
+
+// stopAhead reports whether a vehicle has yet to reach a stop on its trip.
+func stopAhead(vp *gtfsrt.VehiclePosition, stopTimes []*model.StopTime, stopID string) bool {
+	current := -1
+	for i, st := range stopTimes {
+		if vp.CurrentStopSequence > 0 && st.StopSequence == vp.CurrentStopSequence ||
+			vp.CurrentStopSequence == 0 && vp.StopID != "" && st.StopID == vp.StopID {
+			current = i
+			break
+		}
+	}
+	for i := max(current, 0); i < len(stopTimes); i++ {
+		if stopTimes[i].StopID == stopID {
+			return true
+		}
+	}
+	return false
+}
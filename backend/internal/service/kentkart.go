@@ -1,33 +1,92 @@
 package service
 
 import (
+	"cmp"
+	"context"
 	"encoding/json"
+	"errors"
+	"expvar"
 	"fmt"
 	"io"
+	"log"
+	"math/rand/v2"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/rfurkan37/transport-app/backend/internal/model"
 )
 
+// Kentkart API defaults
 const (
-	kentkartBaseURL = "https://service.kentkart.com/rl1/web"
-	kocaeliRegion   = "004"
+	DefaultKentkartBaseURL = "https://service.kentkart.com/rl1/web"
+	DefaultKentkartRegion  = "004" // Kocaeli
+	kentkartLang           = "tr"  // used when the request names no language
 )
 
-// KentkartClient handles communication with the Kentkart API.
+// Retry and circuit breaker settings
+const (
+	kentkartAttempts       = 3                      // tries per call
+	kentkartAttemptTimeout = 4 * time.Second        // per try
+	kentkartBackoff        = 250 * time.Millisecond // before the first retry, doubling after
+	breakerThreshold       = 5                      // consecutive failed calls that open the breaker
+	breakerCooldown        = 30 * time.Second       // before a trial call is let through
+)
+
+// Errors returned by KentkartClient. Errors for a failed call wrap one of
+// ErrKentkartUnavailable, ErrKentkartTimeout or ErrKentkartBadPayload, or
+// are a *KentkartResultError. Each wraps the matching ErrRealtime error.
+var (
+	ErrKentkartUnavailable = fmt.Errorf("kentkart unavailable: %w", ErrRealtimeUnavailable)
+	ErrKentkartTimeout     = fmt.Errorf("kentkart timed out: %w", ErrRealtimeTimeout)
+	ErrKentkartBadPayload  = fmt.Errorf("kentkart returned an unreadable response: %w", ErrRealtimeBadPayload)
+)
+
+// KentkartResultError is a response whose result code reports a failure.
+type KentkartResultError struct {
+	Code    int
+	Message string
+}
+
+func (e *KentkartResultError) Error() string {
+	return fmt.Sprintf("kentkart result code %d: %s", e.Code, e.Message)
+}
+
+// Unwrap makes a result error match ErrRealtimeBadPayload: Kentkart
+// answered, but not with arrivals.
+func (e *KentkartResultError) Unwrap() error {
+	return ErrRealtimeBadPayload
+}
+
+// kentkartMetrics counts calls to Kentkart, published at /debug/vars.
+var kentkartMetrics = publishedMap("kentkart")
+
+// KentkartClient handles communication with the Kentkart API. Failed
+// requests are retried with jittered backoff, and after repeated failures
+// a circuit breaker fails calls at once until Kentkart recovers.
 type KentkartClient struct {
 	httpClient *http.Client
+	baseURL    string
 	region     string
+	breaker    *breaker
 }
 
-// NewKentkartClient creates a new Kentkart API client.
-func NewKentkartClient() *KentkartClient {
-	return &KentkartClient{
-		httpClient: &http.Client{Timeout: 10 * time.Second},
-		region:     kocaeliRegion,
+// NewKentkartClient creates a new Kentkart API client for a region, calling
+// the API at baseURL. Empty values select DefaultKentkartBaseURL and
+// DefaultKentkartRegion.
+func NewKentkartClient(baseURL, region string) *KentkartClient {
+	c := &KentkartClient{
+		httpClient: &http.Client{},
+		baseURL:    strings.TrimSuffix(cmp.Or(baseURL, DefaultKentkartBaseURL), "/"),
+		region:     cmp.Or(region, DefaultKentkartRegion),
+		breaker:    newBreaker(breakerThreshold, breakerCooldown),
 	}
+	// The breaker shown is that of the latest client.
+	kentkartMetrics.Set("breaker", expvar.Func(func() any { return c.breaker.current() }))
+	return c
 }
 
 // Kentkart API response types
@@ -72,9 +131,16 @@ type routeInfo struct {
 	NextTripArrivalTime string `json:"nextTripArrivalTime"`
 }
 
-// GetStopArrivals fetches real-time arrivals for a stop.
-func (c *KentkartClient) GetStopArrivals(stopID string, lat, lon float64) ([]model.StopArrival, error) {
-	resp, err := c.getNearestBus(stopID, lat, lon)
+// StopArrivals implements ArrivalsProvider. Kentkart answers for every
+// route at the stop, whatever the query's agencies.
+func (c *KentkartClient) StopArrivals(ctx context.Context, q ArrivalsQuery) ([]model.StopArrival, error) {
+	return c.GetStopArrivals(ctx, q.Stop.ID, q.Stop.Lat, q.Stop.Lon, q.Lang)
+}
+
+// GetStopArrivals fetches real-time arrivals for a stop, with route names
+// in lang where Kentkart has them.
+func (c *KentkartClient) GetStopArrivals(ctx context.Context, stopID string, lat, lon float64, lang string) ([]model.StopArrival, error) {
+	resp, err := c.getNearestBus(ctx, stopID, lat, lon, lang)
 	if err != nil {
 		return nil, err
 	}
@@ -94,27 +160,197 @@ func (c *KentkartClient) GetStopArrivals(stopID string, lat, lon float64) ([]mod
 			RouteType:   route.RouteType,
 			ArrivalTime: arrivalTime,
 			Headsign:    route.HeadSign,
+			Source:      ProviderKentkart,
 		})
 	}
 
 	return arrivals, nil
 }
 
-func (c *KentkartClient) getNearestBus(stopID string, lat, lon float64) (*nearestBusResponse, error) {
+// Vehicles implements VehiclesProvider from the buses Kentkart lists as
+// approaching a stop. Kentkart cannot list the buses of a route, so for a
+// route without a stop this is best effort: the buses approaching a few
+// stops along each of its directions are listed (see routeStops), without
+// their arrival times at those stops. Stops that fail are skipped unless
+// all of them do.
+func (c *KentkartClient) Vehicles(ctx context.Context, q VehiclesQuery) ([]model.Vehicle, error) {
+	stopIDs := []string{q.StopID}
+	if q.StopID == "" {
+		stopIDs = routeStops(q.Data, q.RouteID)
+	}
+	route := q.Data.Routes[q.RouteID]
+
+	found := make([][]model.Vehicle, len(stopIDs))
+	errs := make([]error, len(stopIDs))
+	var wg sync.WaitGroup
+	for i, stopID := range stopIDs {
+		stop, ok := q.Data.Stops[stopID]
+		if !ok {
+			continue
+		}
+		wg.Go(func() {
+			var resp *nearestBusResponse
+			if resp, errs[i] = c.getNearestBus(ctx, stop.ID, stop.Lat, stop.Lon, ""); errs[i] == nil {
+				found[i] = busVehicles(q.Data, resp, stop.ID)
+			}
+		})
+	}
+	wg.Wait()
+
+	vehicles := []model.Vehicle{}
+	seen := make(map[string]bool)
+	var firstErr error
+	failed := 0
+	for i := range stopIDs {
+		if errs[i] != nil {
+			firstErr = cmp.Or(firstErr, errs[i])
+			failed++
+			continue
+		}
+		for _, v := range found[i] {
+			if route != nil && v.RouteCode != route.ShortName || seen[v.ID] {
+				continue
+			}
+			seen[v.ID] = true
+			if q.StopID == "" {
+				v.StopID, v.ArrivalTime = "", ""
+			}
+			if route != nil {
+				v.RouteID = route.ID
+			}
+			vehicles = append(vehicles, v)
+		}
+	}
+	if failed > 0 && failed == len(stopIDs) {
+		return nil, firstErr
+	}
+	if failed > 0 {
+		log.Printf("Kentkart vehicles of route %s: %d of %d stops failed, first: %v", q.RouteID, failed, len(stopIDs), firstErr)
+	}
+	return vehicles, nil
+}
+
+// busVehicles maps the buses of a nearest/bus response to vehicles. Buses
+// carry Kentkart's internal route code, translated to the displayed one
+// through the response's route list and matched to a route calling at the
+// stop by short name.
+func busVehicles(data *model.GTFSData, resp *nearestBusResponse, stopID string) []model.Vehicle {
+	display := make(map[string]string, len(resp.RouteList))
+	for _, r := range resp.RouteList {
+		display[r.RouteCode] = r.DisplayRouteCode
+	}
+	routeIDs := make(map[string]string)
+	for _, st := range data.StopTimesByStop[stopID] {
+		if trip, ok := data.Trips[st.TripID]; ok {
+			if route, ok := data.Routes[trip.RouteID]; ok {
+				routeIDs[route.ShortName] = route.ID
+			}
+		}
+	}
+
+	vehicles := make([]model.Vehicle, 0, len(resp.BusList))
+	for _, bus := range resp.BusList {
+		lat, latErr := strconv.ParseFloat(strings.TrimSpace(bus.Lat), 64)
+		lon, lonErr := strconv.ParseFloat(strings.TrimSpace(bus.Lng), 64)
+		if bus.BusID == "" || latErr != nil || lonErr != nil || (lat == 0 && lon == 0) {
+			continue
+		}
+		code := cmp.Or(display[bus.RouteCode], bus.RouteCode)
+		vehicles = append(vehicles, model.Vehicle{
+			ID:          bus.BusID,
+			RouteID:     routeIDs[code],
+			RouteCode:   code,
+			Lat:         lat,
+			Lon:         lon,
+			StopID:      stopID,
+			ArrivalTime: bus.ArrivalTime,
+			Source:      ProviderKentkart,
+		})
+	}
+	return vehicles
+}
+
+func (c *KentkartClient) getNearestBus(ctx context.Context, stopID string, lat, lon float64, lang string) (*nearestBusResponse, error) {
+	if lang = baseLanguage(lang); lang == "" {
+		lang = kentkartLang
+	}
+
 	params := url.Values{}
 	params.Set("region", c.region)
-	params.Set("lang", "tr")
+	params.Set("lang", lang)
 	params.Set("authType", "4")
 	params.Set("accuracy", "0")
 	params.Set("lat", fmt.Sprintf("%f", lat))
 	params.Set("lng", fmt.Sprintf("%f", lon))
 	params.Set("busStopId", stopID)
 
-	reqURL := fmt.Sprintf("%s/nearest/bus?%s", kentkartBaseURL, params.Encode())
+	reqURL := fmt.Sprintf("%s/nearest/bus?%s", c.baseURL, params.Encode())
+
+	var result nearestBusResponse
+	if err := c.call(ctx, reqURL, &result); err != nil {
+		return nil, err
+	}
+	if result.Result.Code != 0 {
+		return nil, &KentkartResultError{Code: result.Result.Code, Message: result.Result.Message}
+	}
+	return &result, nil
+}
+
+// call GETs a Kentkart URL into result through the circuit breaker,
+// retrying requests that failed because Kentkart was unreachable, slow or
+// overloaded.
+func (c *KentkartClient) call(ctx context.Context, reqURL string, result any) error {
+	if !c.breaker.allow() {
+		kentkartMetrics.Add("rejected", 1)
+		return fmt.Errorf("%w: circuit breaker open", ErrKentkartUnavailable)
+	}
 
-	req, err := http.NewRequest("GET", reqURL, nil)
+	var err error
+	for attempt := range kentkartAttempts {
+		if attempt > 0 {
+			kentkartMetrics.Add("retries", 1)
+			// Jitter spreads out clients that failed together: sleep
+			// between half and one and a half times the backoff.
+			backoff := kentkartBackoff << (attempt - 1)
+			timer := time.NewTimer(rand.N(backoff) + backoff/2)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				c.breaker.abandon()
+				return contextError(ctx)
+			case <-timer.C:
+			}
+		}
+
+		kentkartMetrics.Add("requests", 1)
+		err = c.attempt(ctx, reqURL, result)
+		if err == nil || !retryable(err) {
+			break
+		}
+		if ctx.Err() != nil {
+			c.breaker.abandon()
+			return contextError(ctx)
+		}
+	}
+
+	if err != nil && retryable(err) {
+		kentkartMetrics.Add("failures", 1)
+		c.breaker.failure()
+	} else {
+		// Kentkart answered, even if with an error of its own.
+		c.breaker.success()
+	}
+	return err
+}
+
+// attempt makes a single request.
+func (c *KentkartClient) attempt(ctx context.Context, reqURL string, result any) error {
+	ctx, cancel := context.WithTimeout(ctx, kentkartAttemptTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", reqURL, nil)
 	if err != nil {
-		return nil, fmt.Errorf("creating request: %w", err)
+		return fmt.Errorf("creating request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
@@ -125,23 +361,34 @@ func (c *KentkartClient) getNearestBus(stopID string, lat, lon float64) (*neares
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("executing request: %w", err)
+		if errors.Is(err, context.DeadlineExceeded) {
+			return fmt.Errorf("%w: %v", ErrKentkartTimeout, err)
+		}
+		return fmt.Errorf("%w: %v", ErrKentkartUnavailable, err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return fmt.Errorf("%w: status code %d", ErrKentkartUnavailable, resp.StatusCode)
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("%w: unexpected status code %d", ErrKentkartBadPayload, resp.StatusCode)
 	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("reading response: %w", err)
+		if errors.Is(err, context.DeadlineExceeded) {
+			return fmt.Errorf("%w: reading response: %v", ErrKentkartTimeout, err)
+		}
+		return fmt.Errorf("%w: reading response: %v", ErrKentkartUnavailable, err)
 	}
-
-	var result nearestBusResponse
-	if err := json.Unmarshal(body, &result); err != nil {
-		return nil, fmt.Errorf("parsing response: %w", err)
+	if err := json.Unmarshal(body, result); err != nil {
+		return fmt.Errorf("%w: %v", ErrKentkartBadPayload, err)
 	}
+	return nil
+}
 
-	return &result, nil
+// retryable reports whether a failed request may succeed if tried again.
+func retryable(err error) bool {
+	return errors.Is(err, ErrKentkartUnavailable) || errors.Is(err, ErrKentkartTimeout)
 }
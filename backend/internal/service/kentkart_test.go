@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rfurkan37/transport-app/backend/internal/kentkartfake"
+)
+
+func TestKentkartClientRetries(t *testing.T) {
+	route := kentkartfake.Arrivals(kentkartfake.Route{DisplayRouteCode: "80", StopArrivalTime: "5"})
+	tests := []struct {
+		name         string
+		script       []kentkartfake.Response
+		wantErr      error
+		wantRequests int
+	}{
+		{"answer", []kentkartfake.Response{route}, nil, 1},
+		{"retried after an overload", []kentkartfake.Response{kentkartfake.HTTPError(http.StatusServiceUnavailable), route}, nil, 2},
+		{"gives up after three tries", []kentkartfake.Response{kentkartfake.HTTPError(http.StatusBadGateway)}, ErrRealtimeUnavailable, kentkartAttempts},
+		{"result codes are not retried", []kentkartfake.Response{kentkartfake.ResultCode(3, "unknown stop")}, ErrRealtimeBadPayload, 1},
+		{"malformed answers are not retried", []kentkartfake.Response{kentkartfake.Malformed()}, ErrRealtimeBadPayload, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := kentkartfake.New()
+			fake.Script("S1", tt.script...)
+			srv := httptest.NewServer(fake)
+			defer srv.Close()
+			c := NewKentkartClient(srv.URL, "")
+
+			_, err := c.GetStopArrivals(context.Background(), "S1", 40.76, 29.90, "")
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+			if n := len(fake.Requests()); n != tt.wantRequests {
+				t.Errorf("%d requests, want %d", n, tt.wantRequests)
+			}
+			if got := c.breaker.current(); got != breakerClosed {
+				t.Errorf("breaker %s after one call", got)
+			}
+		})
+	}
+}
+
+func TestKentkartClientCanceled(t *testing.T) {
+	fake := kentkartfake.New()
+	fake.SetDefault(kentkartfake.Slow(time.Second, kentkartfake.Arrivals()))
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	c := NewKentkartClient(srv.URL, "")
+
+	// Callers giving up do not count as Kentkart failing.
+	for range breakerThreshold {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		_, err := c.GetStopArrivals(ctx, "S1", 40.76, 29.90, "")
+		cancel()
+		if !errors.Is(err, ErrRealtimeTimeout) {
+			t.Fatalf("error = %v, want %v", err, ErrRealtimeTimeout)
+		}
+	}
+	if got := c.breaker.current(); got != breakerClosed {
+		t.Errorf("breaker %s after canceled calls", got)
+	}
+}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"expvar"
+	"log"
+	"sync"
+	"time"
+)
+
+// Counters in the metrics of a LiveCache
+const (
+	metricHits      = "hits"      // answered from a fresh entry
+	metricMisses    = "misses"    // fetched from the provider
+	metricCoalesced = "coalesced" // waited for a fetch another request started
+	metricStale     = "stale"     // the provider failed, answered from an old entry
+	metricErrors    = "errors"    // the provider failed with nothing to fall back on
+)
+
+var publishMu sync.Mutex
+
+// publishedMap returns the expvar map published under name, publishing a
+// new one the first time. expvar.NewMap panics when a name is reused, as it
+// would by building a second cache or client.
+func publishedMap(name string) *expvar.Map {
+	publishMu.Lock()
+	defer publishMu.Unlock()
+	if m, ok := expvar.Get(name).(*expvar.Map); ok {
+		return m
+	}
+	return expvar.NewMap(name)
+}
+
+// LiveCache keeps the latest answers of real-time providers for a short
+// time, so a stop opened by many people at once costs one upstream call.
+// Concurrent requests for an answer that is not cached share a single
+// fetch. When the provider fails, answers up to maxStale old are served
+// instead, marked as stale.
+type LiveCache[V any] struct {
+	name     string
+	metrics  *expvar.Map
+	ttl      time.Duration
+	maxStale time.Duration
+
+	mu        sync.Mutex
+	entries   map[CacheKey]*cacheEntry[V]
+	inflight  map[CacheKey]*cacheCall[V]
+	lastSweep time.Time
+}
+
+// CacheKey identifies a cached answer: the provider, the stop or route it
+// is about and, as route names depend on it, the language.
+type CacheKey struct {
+	Provider string
+	ID       string
+	Lang     string
+}
+
+type cacheEntry[V any] struct {
+	value   V
+	fetched time.Time
+}
+
+// cacheCall is a provider fetch in progress. done is closed once the result
+// fields are set.
+type cacheCall[V any] struct {
+	done    chan struct{}
+	value   V
+	fetched time.Time
+	err     error
+}
+
+// Cached is an answer from the cache.
+type Cached[V any] struct {
+	Value     V
+	FetchedAt time.Time
+	Stale     bool // the provider could not be reached; this is an older answer
+}
+
+// NewLiveCache creates a cache of what name describes, such as "arrivals",
+// keeping answers fresh for ttl and usable as a fallback for maxStale. Its
+// counters are published at /debug/vars as name + "_cache", and shared with
+// earlier caches of the same name.
+func NewLiveCache[V any](name string, ttl, maxStale time.Duration) *LiveCache[V] {
+	c := &LiveCache[V]{
+		name:     name,
+		metrics:  publishedMap(name + "_cache"),
+		ttl:      ttl,
+		maxStale: max(maxStale, ttl),
+		entries:  make(map[CacheKey]*cacheEntry[V]),
+		inflight: make(map[CacheKey]*cacheCall[V]),
+	}
+	c.metrics.Set("entries", expvar.Func(func() any {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return len(c.entries)
+	}))
+	return c
+}
+
+// Get returns the answer for key, from the cache while it is fresh or else
+// from fetch. It gives up waiting for the provider when ctx ends, falling
+// back to a stale answer like any other failure.
+func (c *LiveCache[V]) Get(ctx context.Context, key CacheKey, fetch func(context.Context) (V, error)) (Cached[V], error) {
+	c.mu.Lock()
+	if e, ok := c.entries[key]; ok && time.Since(e.fetched) < c.ttl {
+		c.mu.Unlock()
+		c.metrics.Add(metricHits, 1)
+		return Cached[V]{Value: e.value, FetchedAt: e.fetched}, nil
+	}
+	call, ok := c.inflight[key]
+	if ok {
+		c.metrics.Add(metricCoalesced, 1)
+	} else {
+		c.metrics.Add(metricMisses, 1)
+		call = &cacheCall[V]{done: make(chan struct{})}
+		c.inflight[key] = call
+		go c.fetch(context.WithoutCancel(ctx), key, call, fetch)
+	}
+	c.mu.Unlock()
+
+	var err error
+	select {
+	case <-call.done:
+		if call.err == nil {
+			return Cached[V]{Value: call.value, FetchedAt: call.fetched}, nil
+		}
+		err = call.err
+	case <-ctx.Done():
+		err = contextError(ctx)
+	}
+
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && time.Since(e.fetched) < c.maxStale {
+		c.metrics.Add(metricStale, 1)
+		log.Printf("Serving stale %s %s for %s: %v", key.Provider, c.name, key.ID, err)
+		return Cached[V]{Value: e.value, FetchedAt: e.fetched, Stale: true}, nil
+	}
+	c.metrics.Add(metricErrors, 1)
+	return Cached[V]{}, err
+}
+
+// fetch calls the provider for one key and hands the result to every
+// request waiting on call. It runs on its own goroutine and is not canceled
+// with the request that started it, as other requests may be waiting too;
+// the provider's own timeouts bound how long it takes.
+func (c *LiveCache[V]) fetch(ctx context.Context, key CacheKey, call *cacheCall[V], fetch func(context.Context) (V, error)) {
+	value, err := fetch(ctx)
+	now := time.Now()
+
+	c.mu.Lock()
+	call.value, call.fetched, call.err = value, now, err
+	delete(c.inflight, key)
+	if err == nil {
+		c.entries[key] = &cacheEntry[V]{value: value, fetched: now}
+	}
+	c.sweep(now)
+	c.mu.Unlock()
+
+	close(call.done)
+}
+
+// sweep drops entries too old to be served even as stale. It runs at most
+// once per maxStale and must be called with c.mu held.
+func (c *LiveCache[V]) sweep(now time.Time) {
+	if now.Sub(c.lastSweep) < c.maxStale {
+		return
+	}
+	c.lastSweep = now
+	for key, e := range c.entries {
+		if now.Sub(e.fetched) >= c.maxStale {
+			delete(c.entries, key)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNewLiveCacheSameName(t *testing.T) {
+	a := NewLiveCache[int]("test_same_name", time.Minute, time.Minute)
+	b := NewLiveCache[int]("test_same_name", time.Minute, time.Minute)
+	NewKentkartClient("", "")
+	NewKentkartClient("", "")
+
+	fetch := func(context.Context) (int, error) { return 1, nil }
+	a.Get(context.Background(), CacheKey{ID: "a"}, fetch)
+	b.Get(context.Background(), CacheKey{ID: "b"}, fetch)
+	if got := publishedMap("test_same_name_cache").Get(metricMisses).String(); got != "2" {
+		t.Errorf("misses = %s, want 2 shared by both caches", got)
+	}
+}
+
+func TestLiveCacheCoalescesConcurrentCalls(t *testing.T) {
+	c := NewLiveCache[int]("test_coalesce", time.Minute, time.Minute)
+	release := make(chan struct{})
+	var fetches atomic.Int32
+	fetch := func(context.Context) (int, error) {
+		fetches.Add(1)
+		<-release
+		return 42, nil
+	}
+
+	const callers = 10
+	var wg sync.WaitGroup
+	results := make([]int, callers)
+	for i := range callers {
+		wg.Go(func() {
+			res, err := c.Get(context.Background(), CacheKey{ID: "S1"}, fetch)
+			if err != nil {
+				t.Error(err)
+			}
+			results[i] = res.Value
+		})
+	}
+	// Let the fetch finish once every caller waits on it.
+	for c.metrics.Get(metricCoalesced) == nil || c.metrics.Get(metricCoalesced).String() != fmt.Sprint(callers-1) {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("%d fetches, want 1", n)
+	}
+	for i, v := range results {
+		if v != 42 {
+			t.Errorf("caller %d got %d", i, v)
+		}
+	}
+}
+
+func TestLiveCacheGet(t *testing.T) {
+	errDown := fmt.Errorf("%w: down", ErrRealtimeUnavailable)
+	tests := []struct {
+		name      string
+		ttl       time.Duration
+		maxStale  time.Duration
+		age       time.Duration // of the cached answer when asked again
+		second    error         // result of the second fetch
+		want      int
+		wantStale bool
+		wantErr   error
+	}{
+		{"fresh", time.Minute, time.Hour, 0, errDown, 1, false, nil},
+		{"refreshed", time.Millisecond, time.Hour, 5 * time.Millisecond, nil, 2, false, nil},
+		{"stale on failure", time.Millisecond, time.Hour, 5 * time.Millisecond, errDown, 1, true, nil},
+		{"too old to serve", time.Millisecond, 2 * time.Millisecond, 5 * time.Millisecond, errDown, 0, false, ErrRealtimeUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewLiveCache[int]("test_get", tt.ttl, tt.maxStale)
+			key := CacheKey{Provider: "test", ID: "S1"}
+			calls := 0
+			fetch := func(context.Context) (int, error) {
+				calls++
+				if calls > 1 && tt.second != nil {
+					return 0, tt.second
+				}
+				return calls, nil
+			}
+
+			if _, err := c.Get(context.Background(), key, fetch); err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(tt.age)
+			res, err := c.Get(context.Background(), key, fetch)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if res.Value != tt.want || res.Stale != tt.wantStale {
+				t.Errorf("got %d, stale %v; want %d, stale %v", res.Value, res.Stale, tt.want, tt.wantStale)
+			}
+		})
+	}
+}
+
+func TestLiveCacheGivesUpWithContext(t *testing.T) {
+	c := NewLiveCache[int]("test_context", time.Minute, time.Minute)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	release := make(chan struct{})
+	defer close(release)
+	_, err := c.Get(ctx, CacheKey{ID: "S1"}, func(context.Context) (int, error) {
+		<-release
+		return 1, nil
+	})
+	if !errors.Is(err, ErrRealtimeTimeout) {
+		t.Errorf("error = %v, want %v", err, ErrRealtimeTimeout)
+	}
+}
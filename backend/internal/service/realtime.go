@@ -0,0 +1,428 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rfurkan37/transport-app/backend/internal/model"
+)
+
+// Real-time provider names, as used in the provider configuration
+const (
+	ProviderKentkart = "kentkart"
+	ProviderGTFSRT   = "gtfs-rt"
+	ProviderSchedule = "schedule"
+)
+
+// Errors returned by real-time providers. Provider errors wrap one of
+// these, so callers need not know which provider answered.
+var (
+	ErrRealtimeUnavailable = errors.New("real-time provider unavailable")
+	ErrRealtimeTimeout     = errors.New("real-time provider timed out")
+	ErrRealtimeBadPayload  = errors.New("real-time provider returned an unreadable response")
+	ErrRealtimeUnsupported = errors.New("real-time provider does not support this request")
+)
+
+// ArrivalsQuery asks a provider for the next arrivals at a stop.
+type ArrivalsQuery struct {
+	Data *model.GTFSData
+	Stop *model.Stop
+	Lang string
+
+	// Agencies the provider answers for at this stop; nil means all. A
+	// provider that cannot tell agencies apart may ignore it.
+	Agencies []string
+}
+
+// ArrivalsProvider predicts arrivals at stops.
+type ArrivalsProvider interface {
+	StopArrivals(ctx context.Context, q ArrivalsQuery) ([]model.StopArrival, error)
+}
+
+// VehiclesQuery asks a provider for the vehicles heading to a stop,
+// running a route, or running a route towards a stop; at least one of
+// StopID and RouteID is set.
+type VehiclesQuery struct {
+	Data     *model.GTFSData
+	StopID   string
+	RouteID  string
+	Agencies []string // as in ArrivalsQuery
+}
+
+// VehiclesProvider reports live vehicle positions.
+type VehiclesProvider interface {
+	Vehicles(ctx context.Context, q VehiclesQuery) ([]model.Vehicle, error)
+}
+
+// RealtimeProvider is a source of real-time data. Either part may be nil
+// when the source does not offer it.
+type RealtimeProvider struct {
+	Arrivals ArrivalsProvider
+	Vehicles VehiclesProvider
+}
+
+// Arrivals is the answer to an arrivals request.
+type Arrivals struct {
+	Arrivals  []model.StopArrival
+	FetchedAt time.Time
+	Stale     bool // a provider could not be reached; these are older arrivals
+}
+
+// Vehicles is the answer to a vehicles request.
+type Vehicles struct {
+	Vehicles  []model.Vehicle
+	FetchedAt time.Time
+	Stale     bool // a provider could not be reached; these are older positions
+	Partial   bool // a provider failed; its vehicles are missing
+}
+
+// Realtime picks the real-time provider of each agency and merges their
+// answers for stops served by several agencies.
+type Realtime struct {
+	providers map[string]RealtimeProvider
+	agencies  map[string]string // agency ID to provider name
+	fallback  string            // provider of agencies not listed
+	arrivals  *LiveCache[[]model.StopArrival]
+	vehicles  *LiveCache[[]model.Vehicle]
+}
+
+// NewRealtime creates the provider selection from a configuration such as
+// "kentkart" or "gtfs-rt,KOC=kentkart,ADA=schedule": a comma-separated list
+// of agency_id=provider entries and at most one bare provider name used for
+// all other agencies. Agencies left without a provider use the schedule.
+// Answers from every provider are kept in the given caches.
+func NewRealtime(config string, providers map[string]RealtimeProvider, arrivals *LiveCache[[]model.StopArrival], vehicles *LiveCache[[]model.Vehicle]) (*Realtime, error) {
+	r := &Realtime{
+		providers: providers,
+		agencies:  make(map[string]string),
+		fallback:  ProviderSchedule,
+		arrivals:  arrivals,
+		vehicles:  vehicles,
+	}
+	if _, ok := providers[ProviderSchedule]; !ok {
+		return nil, fmt.Errorf("%s provider missing", ProviderSchedule)
+	}
+
+	fallbackSet := false
+	for entry := range strings.SplitSeq(config, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		agency, name, isAgency := strings.Cut(entry, "=")
+		if !isAgency {
+			agency, name = "", entry
+		}
+		agency, name = strings.TrimSpace(agency), strings.TrimSpace(name)
+		if _, ok := providers[name]; !ok {
+			return nil, fmt.Errorf("unknown or unconfigured provider %q (available: %s)",
+				name, strings.Join(sortedKeys(providers), ", "))
+		}
+
+		switch {
+		case !isAgency && fallbackSet:
+			return nil, fmt.Errorf("more than one default provider in %q", config)
+		case !isAgency:
+			r.fallback, fallbackSet = name, true
+		case agency == "":
+			return nil, fmt.Errorf("missing agency in %q", entry)
+		default:
+			r.agencies[agency] = name
+		}
+	}
+	return r, nil
+}
+
+// providerGroup is a provider and the agencies it answers for.
+type providerGroup struct {
+	name     string
+	agencies []string
+}
+
+// groups splits agencies by provider, in order of the first agency of each.
+// Without agencies, as for a stop no trip serves, the default provider
+// answers for all.
+func (r *Realtime) groups(agencies []string) []providerGroup {
+	if len(agencies) == 0 {
+		return []providerGroup{{name: r.fallback}}
+	}
+	var groups []providerGroup
+	for _, agency := range agencies {
+		name, ok := r.agencies[agency]
+		if !ok {
+			name = r.fallback
+		}
+		i := slices.IndexFunc(groups, func(g providerGroup) bool { return g.name == name })
+		if i < 0 {
+			groups = append(groups, providerGroup{name: name})
+			i = len(groups) - 1
+		}
+		groups[i].agencies = append(groups[i].agencies, agency)
+	}
+	return groups
+}
+
+// Arrivals returns the arrivals at a stop from the providers of the
+// agencies serving it, through the cache. When some providers fail the
+// others' arrivals are returned; the error of the first is returned only
+// when all fail.
+func (r *Realtime) Arrivals(ctx context.Context, data *model.GTFSData, stop *model.Stop, lang string) (Arrivals, error) {
+	groups := r.groups(stopAgencies(data, stop.ID))
+	results := make([]Arrivals, len(groups))
+	errs := make([]error, len(groups))
+
+	var wg sync.WaitGroup
+	for i, g := range groups {
+		q := ArrivalsQuery{Data: data, Stop: stop, Lang: lang, Agencies: g.agencies}
+		provider := r.providers[g.name].Arrivals
+		if provider == nil {
+			errs[i] = fmt.Errorf("%w: %s has no arrivals", ErrRealtimeUnsupported, g.name)
+			continue
+		}
+		wg.Go(func() {
+			key := CacheKey{Provider: g.name, ID: stop.ID, Lang: lang}
+			var res Cached[[]model.StopArrival]
+			res, errs[i] = r.arrivals.Get(ctx, key, func(ctx context.Context) ([]model.StopArrival, error) {
+				return provider.StopArrivals(ctx, q)
+			})
+			results[i] = Arrivals{Arrivals: res.Value, FetchedAt: res.FetchedAt, Stale: res.Stale}
+		})
+	}
+	wg.Wait()
+
+	var merged Arrivals
+	var firstErr error
+	answered := false
+	for i, res := range results {
+		if errs[i] != nil {
+			if firstErr == nil {
+				firstErr = errs[i]
+			}
+			if len(groups) > 1 {
+				log.Printf("Arrivals from %s for stop %s failed: %v", groups[i].name, stop.ID, errs[i])
+			}
+			continue
+		}
+		merged.Arrivals = append(merged.Arrivals, res.Arrivals...)
+		if !answered || res.FetchedAt.Before(merged.FetchedAt) {
+			merged.FetchedAt = res.FetchedAt
+		}
+		merged.Stale = merged.Stale || res.Stale
+		answered = true
+	}
+	if !answered {
+		return Arrivals{}, firstErr
+	}
+	if merged.Arrivals == nil {
+		merged.Arrivals = []model.StopArrival{}
+	}
+	return merged, nil
+}
+
+// Vehicles returns the vehicles heading to a stop or running a route from
+// the providers of the agencies concerned, through the cache. With a stop,
+// each vehicle gets its distance to the stop and they are ordered nearest
+// first. It fails with ErrRealtimeUnsupported when none of the providers
+// reports vehicles. When some providers fail the others' vehicles are
+// returned, marked as partial; the error of the first is returned only
+// when all fail.
+func (r *Realtime) Vehicles(ctx context.Context, q VehiclesQuery) (Vehicles, error) {
+	var agencies []string
+	if q.RouteID != "" {
+		if route, ok := q.Data.Routes[q.RouteID]; ok {
+			agencies = []string{routeAgency(q.Data, route)}
+		}
+	} else {
+		agencies = stopAgencies(q.Data, q.StopID)
+	}
+
+	var groups []providerGroup
+	for _, g := range r.groups(agencies) {
+		if r.providers[g.name].Vehicles != nil {
+			groups = append(groups, g)
+		}
+	}
+	if len(groups) == 0 {
+		return Vehicles{}, fmt.Errorf("%w: no provider reports vehicles", ErrRealtimeUnsupported)
+	}
+
+	results := make([]Cached[[]model.Vehicle], len(groups))
+	errs := make([]error, len(groups))
+	var wg sync.WaitGroup
+	for i, g := range groups {
+		provider := r.providers[g.name].Vehicles
+		gq := q
+		gq.Agencies = g.agencies
+		wg.Go(func() {
+			key := CacheKey{Provider: g.name, ID: q.StopID + "|" + q.RouteID}
+			results[i], errs[i] = r.vehicles.Get(ctx, key, func(ctx context.Context) ([]model.Vehicle, error) {
+				return provider.Vehicles(ctx, gq)
+			})
+		})
+	}
+	wg.Wait()
+
+	res := Vehicles{Vehicles: []model.Vehicle{}}
+	var firstErr error
+	answered := false
+	for i, found := range results {
+		if errs[i] != nil {
+			if firstErr == nil {
+				firstErr = errs[i]
+			}
+			if len(groups) > 1 {
+				log.Printf("Vehicles from %s for stop %q route %q failed: %v", groups[i].name, q.StopID, q.RouteID, errs[i])
+			}
+			res.Partial = true
+			continue
+		}
+		res.Vehicles = append(res.Vehicles, found.Value...)
+		if !answered || found.FetchedAt.Before(res.FetchedAt) {
+			res.FetchedAt = found.FetchedAt
+		}
+		res.Stale = res.Stale || found.Stale
+		answered = true
+	}
+	if !answered {
+		return Vehicles{}, firstErr
+	}
+
+	if stop, ok := q.Data.Stops[q.StopID]; ok {
+		// The cached vehicles are shared; measure copies.
+		res.Vehicles = slices.Clone(res.Vehicles)
+		for i := range res.Vehicles {
+			measureToStop(q.Data, &res.Vehicles[i], stop)
+		}
+		sort.SliceStable(res.Vehicles, func(i, j int) bool {
+			return *res.Vehicles[i].DistanceToStop < *res.Vehicles[j].DistanceToStop
+		})
+	}
+	return res, nil
+}
+
+// contextError converts the end of the caller's context into a provider
+// error.
+func contextError(ctx context.Context) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %v", ErrRealtimeTimeout, ctx.Err())
+	}
+	return ctx.Err()
+}
+
+// routeAgency returns the agency running a route. agency_id may be left
+// out of routes when the feed has a single agency.
+func routeAgency(data *model.GTFSData, route *model.Route) string {
+	if route.AgencyID == "" && len(data.Agencies) == 1 {
+		for id := range data.Agencies {
+			return id
+		}
+	}
+	return route.AgencyID
+}
+
+// stopAgencies returns the agencies whose trips call at a stop or, for a
+// station, at its platforms.
+func stopAgencies(data *model.GTFSData, stopID string) []string {
+	seen := make(map[string]bool)
+	routes := make(map[string]bool)
+	for _, id := range append([]string{stopID}, data.StopChildren[stopID]...) {
+		for _, st := range data.StopTimesByStop[id] {
+			trip, ok := data.Trips[st.TripID]
+			if !ok || routes[trip.RouteID] {
+				continue
+			}
+			routes[trip.RouteID] = true
+			if route, ok := data.Routes[trip.RouteID]; ok {
+				seen[routeAgency(data, route)] = true
+			}
+		}
+	}
+	return sortedKeys(seen)
+}
+
+// servesAgencies reports whether a route belongs to one of the agencies a
+// query is for.
+func servesAgencies(data *model.GTFSData, route *model.Route, agencies []string) bool {
+	return agencies == nil || slices.Contains(agencies, routeAgency(data, route))
+}
+
+// newArrival describes an arrival of a trip from the feed's own route data,
+// for providers that identify trips by GTFS IDs.
+func newArrival(data *model.GTFSData, trip *model.Trip, headsign, lang string, at time.Time, source string) model.StopArrival {
+	a := model.StopArrival{
+		Direction:   fmt.Sprint(trip.DirectionID),
+		ArrivalTime: at.Format("15:04"),
+		Headsign:    headsign,
+		RouteID:     trip.RouteID,
+		TripID:      trip.TripID,
+		Source:      source,
+	}
+	if a.Headsign == "" {
+		a.Headsign = trip.Headsign
+	}
+	recordID := trip.TripID
+	if trip.Frequency != nil {
+		recordID = trip.Frequency.TripID // translations name the template
+	}
+	a.Headsign = Translate(data, lang, "trips", "trip_headsign", recordID, a.Headsign)
+	if route, ok := data.Routes[trip.RouteID]; ok {
+		route = LocalizeRoute(data, route, lang)
+		a.RouteCode = route.ShortName
+		a.RouteName = route.LongName
+		a.RouteColor = route.Color
+		a.RouteType = fmt.Sprint(route.Type)
+	}
+	return a
+}
+
+// scheduleArrivalsLimit is how many timetabled arrivals ScheduleProvider
+// returns.
+const scheduleArrivalsLimit = 20
+
+// ScheduleProvider answers from the timetable, for agencies without a
+// real-time feed. It reports no vehicles.
+type ScheduleProvider struct{}
+
+// StopArrivals returns the next timetabled arrivals at a stop. Headway-based
+// trips without exact times are listed once per window, with their headway
+// and, once the window has started, no arrival time.
+func (ScheduleProvider) StopArrivals(ctx context.Context, q ArrivalsQuery) ([]model.StopArrival, error) {
+	loc := AgencyLocation(q.Data)
+	now := time.Now().In(loc)
+	date := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	from := model.GTFSTime(now.Hour()*3600 + now.Minute()*60 + now.Second())
+
+	arrivals := []model.StopArrival{}
+	for _, dep := range ScheduledDepartures(q.Data, q.Stop.ID, date, from, 0) {
+		trip, ok := q.Data.Trips[dep.TripID]
+		if !ok {
+			continue
+		}
+		if route, ok := q.Data.Routes[trip.RouteID]; !ok || !servesAgencies(q.Data, route, q.Agencies) {
+			continue
+		}
+		t := dep.ArrivalTime
+		if t < 0 {
+			t = dep.DepartureTime
+		}
+		a := newArrival(q.Data, trip, dep.Headsign, q.Lang, date.Add(time.Duration(t)*time.Second), ProviderSchedule)
+		if dep.Approximate {
+			a.HeadwayMin, a.Approximate = dep.HeadwayMin, true
+			if t < 0 {
+				a.ArrivalTime = ""
+			}
+		}
+		arrivals = append(arrivals, a)
+		if len(arrivals) == scheduleArrivalsLimit {
+			break
+		}
+	}
+	return arrivals, nil
+}
@@ -0,0 +1,213 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rfurkan37/transport-app/backend/internal/model"
+)
+
+// gtfsDateLayout is the YYYYMMDD date format used throughout GTFS.
+const gtfsDateLayout = "20060102"
+
+// AgencyLocation returns the time zone of the feed's agencies, falling back
+// to UTC when none is set or it cannot be loaded.
+func AgencyLocation(data *model.GTFSData) *time.Location {
+	if data.Location != nil {
+		return data.Location
+	}
+	return agencyTimezone(data)
+}
+
+func agencyTimezone(data *model.GTFSData) *time.Location {
+	for _, agency := range data.Agencies {
+		if agency.Timezone == "" {
+			continue
+		}
+		if loc, err := time.LoadLocation(agency.Timezone); err == nil {
+			return loc
+		}
+	}
+	return time.UTC
+}
+
+// ParseServiceDate parses a date in YYYYMMDD or YYYY-MM-DD form in loc.
+func ParseServiceDate(s string, loc *time.Location) (time.Time, error) {
+	layout := gtfsDateLayout
+	if strings.Contains(s, "-") {
+		layout = time.DateOnly
+	}
+	date, err := time.ParseInLocation(layout, s, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q", s)
+	}
+	return date, nil
+}
+
+// ServiceResolution lists the services running on a date and which of
+// them calendar_dates added or removed.
+type ServiceResolution struct {
+	Date    time.Time
+	Active  map[string]bool
+	Added   []string // running only because of a calendar_dates exception
+	Removed []string // scheduled by the calendar but cancelled by an exception
+}
+
+// ResolveServices works out which services run on the service day that
+// contains date in the agency time zone: those whose weekly calendar covers
+// it, plus services added and minus those removed by calendar_dates.
+func ResolveServices(data *model.GTFSData, date time.Time) ServiceResolution {
+	loc := AgencyLocation(data)
+	date = date.In(loc)
+	date = time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, loc)
+
+	res := ServiceResolution{Date: date, Active: make(map[string]bool)}
+	for id, cal := range data.Calendars {
+		if calendarRunsOn(cal, date) {
+			res.Active[id] = true
+		}
+	}
+
+	for _, cd := range data.CalendarDates[date.Format(gtfsDateLayout)] {
+		switch cd.ExceptionType {
+		case model.ServiceAdded:
+			if !res.Active[cd.ServiceID] {
+				res.Active[cd.ServiceID] = true
+				res.Added = append(res.Added, cd.ServiceID)
+			}
+		case model.ServiceRemoved:
+			if res.Active[cd.ServiceID] {
+				delete(res.Active, cd.ServiceID)
+				res.Removed = append(res.Removed, cd.ServiceID)
+			}
+		}
+	}
+	sort.Strings(res.Added)
+	sort.Strings(res.Removed)
+	return res
+}
+
+// ActiveServices returns the set of service IDs running on date.
+func ActiveServices(data *model.GTFSData, date time.Time) map[string]bool {
+	return ResolveServices(data, date).Active
+}
+
+func calendarRunsOn(cal *model.Calendar, date time.Time) bool {
+	day := date.Format(gtfsDateLayout)
+	if day < cal.StartDate || (cal.EndDate != "" && day > cal.EndDate) {
+		return false
+	}
+
+	switch date.Weekday() {
+	case time.Monday:
+		return cal.Monday == 1
+	case time.Tuesday:
+		return cal.Tuesday == 1
+	case time.Wednesday:
+		return cal.Wednesday == 1
+	case time.Thursday:
+		return cal.Thursday == 1
+	case time.Friday:
+		return cal.Friday == 1
+	case time.Saturday:
+		return cal.Saturday == 1
+	case time.Sunday:
+		return cal.Sunday == 1
+	}
+	return false
+}
+
+// ScheduledDepartures returns up to limit timetabled departures from a stop
+// at or after from on the given date. Trips of the previous service day that
+// run past midnight are included with their times shifted back by 24 hours.
+// Headway windows without exact times are listed once, as running every
+// HeadwayMin minutes: without times, and first, once the window has started,
+// or at the time of its first departure when it starts later.
+func ScheduledDepartures(data *model.GTFSData, stopID string, date time.Time, from model.GTFSTime, limit int) []model.ScheduledDeparture {
+	const day = model.GTFSTime(24 * 3600)
+
+	days := []struct {
+		date   time.Time
+		offset model.GTFSTime
+	}{
+		{date, 0},
+		{date.AddDate(0, 0, -1), day},
+	}
+
+	type window struct {
+		f      *model.Frequency
+		offset model.GTFSTime
+	}
+	listed := make(map[window]bool)
+
+	var departures []model.ScheduledDeparture
+	for _, d := range days {
+		active := ActiveServices(data, d.date)
+		serviceDate := d.date.Format(gtfsDateLayout)
+
+		for _, st := range data.StopTimesByStop[stopID] {
+			if st.DepartureTime-d.offset < from || st.PickupType == 1 {
+				continue
+			}
+
+			trip, ok := data.Trips[st.TripID]
+			if !ok || !active[trip.ServiceID] || isLastStop(data, st) {
+				continue
+			}
+
+			dep := newScheduledDeparture(data, trip, st, d.offset, serviceDate)
+			if dep.Approximate {
+				// Stop times are sorted, so the first one seen is the next
+				// departure of its window. The window has started unless
+				// that is its first instance.
+				w := window{trip.Frequency, d.offset}
+				if listed[w] {
+					continue
+				}
+				listed[w] = true
+				if data.StopTimesByTrip[trip.TripID][0].DepartureTime > trip.Frequency.StartTime {
+					dep.ArrivalTime, dep.DepartureTime = model.NoTime, model.NoTime
+				}
+			}
+			departures = append(departures, dep)
+		}
+	}
+
+	sort.SliceStable(departures, func(i, j int) bool {
+		return departures[i].DepartureTime < departures[j].DepartureTime
+	})
+	if limit > 0 && len(departures) > limit {
+		departures = departures[:limit]
+	}
+	return departures
+}
+
+func newScheduledDeparture(data *model.GTFSData, trip *model.Trip, st *model.StopTime, offset model.GTFSTime, serviceDate string) model.ScheduledDeparture {
+	dep := model.ScheduledDeparture{
+		TripID:        trip.TripID,
+		RouteID:       trip.RouteID,
+		Headsign:      trip.Headsign,
+		StopSequence:  st.StopSequence,
+		ArrivalTime:   st.ArrivalTime - offset,
+		DepartureTime: st.DepartureTime - offset,
+		ServiceDate:   serviceDate,
+		HeadwayMin:    HeadwayMinutes(trip),
+		Approximate:   Approximate(trip),
+	}
+	if st.StopHeadsign != "" {
+		dep.Headsign = st.StopHeadsign
+	}
+	if route, ok := data.Routes[trip.RouteID]; ok {
+		dep.RouteShortName = route.ShortName
+		dep.RouteLongName = route.LongName
+		dep.RouteColor = route.Color
+	}
+	return dep
+}
+
+func isLastStop(data *model.GTFSData, st *model.StopTime) bool {
+	times := data.StopTimesByTrip[st.TripID]
+	return len(times) > 0 && times[len(times)-1] == st
+}
@@ -0,0 +1,49 @@
+package service
+
+import "strings"
+
+// schema maps the canonical column names a loader reads to the alternative
+// names used by providers of non-GTFS auxiliary files.
+type schema map[string][]string
+
+// placeSchema covers both GTFS-style places and the Kentkart kiosk export
+// (kiosk_no, owner, distirict, term_no, lat, lon, title, term_type, address).
+var placeSchema = schema{
+	"place_id":      {"kiosk_no"},
+	"place_name":    {"title"},
+	"place_lat":     {"lat"},
+	"place_lon":     {"lon"},
+	"district":      {"distirict"},
+	"terminal_no":   {"term_no"},
+	"terminal_type": {"term_type"},
+}
+
+// resolve returns a header in which every canonical column is present,
+// pointing at the first alias found when the file does not use the
+// canonical name itself.
+func (s schema) resolve(header map[string]int) map[string]int {
+	resolved := make(map[string]int, len(header)+len(s))
+	for col, idx := range header {
+		resolved[col] = idx
+	}
+	for canonical, aliases := range s {
+		if _, ok := resolved[canonical]; ok {
+			continue
+		}
+		for _, alias := range aliases {
+			if idx, ok := header[alias]; ok {
+				resolved[canonical] = idx
+				break
+			}
+		}
+	}
+	return resolved
+}
+
+// nullable treats the "null" placeholder some exports use as empty.
+func nullable(s string) string {
+	if strings.EqualFold(strings.TrimSpace(s), "null") {
+		return ""
+	}
+	return s
+}
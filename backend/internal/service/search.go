@@ -0,0 +1,129 @@
+package service
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/rfurkan37/transport-app/backend/internal/model"
+)
+
+// asciiFold maps Turkish letters to the ASCII letters people type instead.
+var asciiFold = strings.NewReplacer("ç", "c", "ğ", "g", "ı", "i", "ö", "o", "ş", "s", "ü", "u", "â", "a", "î", "i", "û", "u")
+
+// foldName lowercases a name with Turkish casing rules and strips accents,
+// so "İZMİT" and "izmit" compare equal.
+func foldName(s string) string {
+	return asciiFold.Replace(strings.ToLowerSpecial(unicode.TurkishCase, s))
+}
+
+// matchScore rates how well a name matches the query words, lower is
+// better: 0 for the whole name, 1 when the name starts with the query, 2
+// when every word starts a word of the name, 3 when every word appears
+// somewhere in it. ok is false when some word is missing.
+func matchScore(name, query string, words []string) (score int, ok bool) {
+	name = foldName(name)
+	switch {
+	case name == query:
+		return 0, true
+	case strings.HasPrefix(name, query):
+		return 1, true
+	}
+
+	nameWords := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	score = 2
+	for _, w := range words {
+		prefix := false
+		for _, nw := range nameWords {
+			if strings.HasPrefix(nw, w) {
+				prefix = true
+				break
+			}
+		}
+		if !prefix {
+			if !strings.Contains(name, w) {
+				return 0, false
+			}
+			score = 3
+		}
+	}
+	return score, true
+}
+
+// SearchResults holds the stops and routes matching a search, best first.
+type SearchResults struct {
+	Stops  []*model.Stop
+	Routes []*model.Route
+}
+
+// Search finds stops and routes whose names match a query, in the feed's
+// own names or their translations to lang. Matching ignores case and
+// Turkish accents. Results are returned translated to lang, at most limit
+// of each.
+func Search(data *model.GTFSData, query, lang string, limit int) SearchResults {
+	query = foldName(strings.TrimSpace(query))
+	words := strings.Fields(query)
+	if len(words) == 0 {
+		return SearchResults{Stops: []*model.Stop{}, Routes: []*model.Route{}}
+	}
+	query = strings.Join(words, " ")
+
+	type hit struct {
+		score int
+		name  string
+		index int
+	}
+	best := func(names ...string) (hit, bool) {
+		h := hit{score: -1}
+		for _, name := range names {
+			if s, ok := matchScore(name, query, words); ok && (h.score < 0 || s < h.score) {
+				h = hit{score: s, name: name}
+			}
+		}
+		return h, h.score >= 0
+	}
+	rank := func(hits []hit) {
+		sort.Slice(hits, func(i, j int) bool {
+			if hits[i].score != hits[j].score {
+				return hits[i].score < hits[j].score
+			}
+			if len(hits[i].name) != len(hits[j].name) {
+				return len(hits[i].name) < len(hits[j].name)
+			}
+			return hits[i].name < hits[j].name
+		})
+	}
+
+	var stopHits []hit
+	for i, stop := range data.StopsList {
+		localized := LocalizeStop(data, stop, lang)
+		if h, ok := best(stop.Name, localized.Name); ok {
+			h.index = i
+			stopHits = append(stopHits, h)
+		}
+	}
+	var routeHits []hit
+	for i, route := range data.RoutesList {
+		localized := LocalizeRoute(data, route, lang)
+		if h, ok := best(route.ShortName, route.LongName, localized.ShortName, localized.LongName); ok {
+			h.index = i
+			routeHits = append(routeHits, h)
+		}
+	}
+	rank(stopHits)
+	rank(routeHits)
+
+	results := SearchResults{
+		Stops:  make([]*model.Stop, 0, min(len(stopHits), limit)),
+		Routes: make([]*model.Route, 0, min(len(routeHits), limit)),
+	}
+	for _, h := range stopHits[:min(len(stopHits), limit)] {
+		results.Stops = append(results.Stops, LocalizeStop(data, data.StopsList[h.index], lang))
+	}
+	for _, h := range routeHits[:min(len(routeHits), limit)] {
+		results.Routes = append(results.Routes, LocalizeRoute(data, data.RoutesList[h.index], lang))
+	}
+	return results
+}
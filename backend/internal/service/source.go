@@ -0,0 +1,329 @@
+package service
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/rfurkan37/transport-app/backend/internal/model"
+)
+
+// feedSource gives access to the tables of a GTFS feed, whether it is an
+// unpacked directory or a zip archive.
+type feedSource interface {
+	// open returns the table with the given name (e.g. "stops"), stored as
+	// either name.txt or name.csv. It returns an error wrapping
+	// fs.ErrNotExist when the feed has no such table.
+	open(name string) (io.ReadCloser, error)
+	Close() error
+}
+
+// tableExtensions are the file extensions accepted for feed tables.
+var tableExtensions = []string{".txt", ".csv"}
+
+// openFeed opens a feed directory or a .zip archive.
+func openFeed(feedPath string) (feedSource, error) {
+	info, err := os.Stat(feedPath)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return dirSource{dir: feedPath}, nil
+	}
+
+	zr, err := zip.OpenReader(feedPath)
+	if err != nil {
+		return nil, fmt.Errorf("opening %s: %w", feedPath, err)
+	}
+	return newZipSource(&zr.Reader, zr), nil
+}
+
+type dirSource struct {
+	dir string
+}
+
+func (d dirSource) open(name string) (io.ReadCloser, error) {
+	for _, ext := range tableExtensions {
+		f, err := os.Open(filepath.Join(d.dir, name+ext))
+		if err == nil {
+			return f, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("%s: %w", name, fs.ErrNotExist)
+}
+
+func (d dirSource) Close() error {
+	return nil
+}
+
+// zipSource reads tables from a zip archive. Tables may sit at the root of
+// the archive or inside a single top-level folder.
+type zipSource struct {
+	files  map[string]*zip.File
+	closer io.Closer
+}
+
+func newZipSource(zr *zip.Reader, closer io.Closer) *zipSource {
+	z := &zipSource{files: make(map[string]*zip.File), closer: closer}
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		base := path.Base(f.Name)
+		if _, ok := z.files[base]; !ok {
+			z.files[base] = f
+		}
+	}
+	return z
+}
+
+func (z *zipSource) open(name string) (io.ReadCloser, error) {
+	for _, ext := range tableExtensions {
+		if f, ok := z.files[name+ext]; ok {
+			return f.Open()
+		}
+	}
+	return nil, fmt.Errorf("%s: %w", name, fs.ErrNotExist)
+}
+
+func (z *zipSource) Close() error {
+	if z.closer == nil {
+		return nil
+	}
+	return z.closer.Close()
+}
+
+// utf8BOM is the byte order mark some exporters put at the start of files.
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// maxTableIssues bounds how many problems are recorded for one table, so a
+// systematically broken file does not fill memory with issues.
+const maxTableIssues = 1000
+
+// csvReader streams the records of a CSV table one at a time. Values that
+// are missing or fail to parse are recorded as validation issues.
+type csvReader struct {
+	table    string
+	reader   *csv.Reader
+	header   map[string]int
+	required []column
+	key      *column // identifies records in issues
+	record   []string
+	err      error
+	interned map[string]string
+	issues   []model.ValidationIssue
+	dropped  int
+}
+
+// newCSVReader reads the header row of a CSV table, skipping a leading
+// byte order mark.
+func newCSVReader(src io.Reader, table string) (*csvReader, error) {
+	br := bufio.NewReader(src)
+	if prefix, err := br.Peek(len(utf8BOM)); err == nil && bytes.Equal(prefix, utf8BOM) {
+		br.Discard(len(utf8BOM))
+	}
+
+	reader := csv.NewReader(br)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	columns, err := reader.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("empty CSV file")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	header := make(map[string]int, len(columns))
+	for i, col := range columns {
+		header[strings.TrimSpace(col)] = i
+	}
+	return &csvReader{
+		table:    table,
+		reader:   reader,
+		header:   header,
+		interned: make(map[string]string),
+	}, nil
+}
+
+// Next advances to the next record, returning false at the end of the
+// table or on error.
+func (c *csvReader) Next() bool {
+	c.record, c.err = c.reader.Read()
+	if c.err == io.EOF {
+		c.err = nil
+		return false
+	}
+	if c.err != nil {
+		return false
+	}
+	for _, col := range c.required {
+		if col.text(c.record) == "" {
+			c.issue(model.SeverityError, "missing_value", "", col.name, "required field %s is empty", col.name)
+		}
+	}
+	return true
+}
+
+// Record returns the current record. The slice is reused by the next call
+// to Next, so callers must not keep it.
+func (c *csvReader) Record() []string {
+	return c.record
+}
+
+// Err returns the first error encountered while reading.
+func (c *csvReader) Err() error {
+	return c.err
+}
+
+// require marks columns that every record must fill in.
+func (c *csvReader) require(names ...string) {
+	for _, name := range names {
+		col := c.column(name)
+		if col.idx < 0 {
+			c.issue(model.SeverityError, "missing_column", "", name, "required column %s is missing", name)
+			continue
+		}
+		c.required = append(c.required, col)
+	}
+}
+
+// identify sets the column whose value names a record in its issues.
+func (c *csvReader) identify(name string) {
+	col := c.column(name)
+	c.key = &col
+}
+
+// issue records a problem with the current record.
+func (c *csvReader) issue(severity, code, id, field, format string, args ...any) {
+	if len(c.issues) >= maxTableIssues {
+		c.dropped++
+		return
+	}
+	if id == "" && c.key != nil {
+		id = c.key.text(c.record)
+	}
+	line, _ := c.reader.FieldPos(0)
+	c.issues = append(c.issues, model.ValidationIssue{
+		Severity: severity,
+		Code:     code,
+		Table:    c.table,
+		ID:       id,
+		Field:    field,
+		Line:     line,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// duplicate records an ID seen earlier in the table; the later row wins.
+func (c *csvReader) duplicate(field, id string) {
+	c.issue(model.SeverityError, "duplicate_id", id, field, "duplicate %s %q", field, id)
+}
+
+// Issues returns the problems recorded while reading the table.
+func (c *csvReader) Issues() []model.ValidationIssue {
+	if c.dropped == 0 {
+		return c.issues
+	}
+	return append(c.issues, model.ValidationIssue{
+		Severity: model.SeverityWarning,
+		Code:     "too_many_issues",
+		Table:    c.table,
+		Message:  fmt.Sprintf("%d more problems in this table were not recorded", c.dropped),
+	})
+}
+
+// column is a CSV column resolved once per table, so records can be read
+// without looking up the header for every field. idx is -1 if the table
+// lacks the column.
+type column struct {
+	name string
+	idx  int
+	c    *csvReader
+}
+
+// column resolves a column by name.
+func (c *csvReader) column(name string) column {
+	idx, ok := c.header[name]
+	if !ok {
+		idx = -1
+	}
+	return column{name: name, idx: idx, c: c}
+}
+
+func (col column) text(record []string) string {
+	if col.idx >= 0 && col.idx < len(record) {
+		return record[col.idx]
+	}
+	return ""
+}
+
+func (col column) float(record []string) float64 {
+	s := col.text(record)
+	if s == "" {
+		return 0
+	}
+	f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		col.invalid(s)
+		return 0
+	}
+	return f
+}
+
+func (col column) int(record []string) int {
+	s := col.text(record)
+	if s == "" {
+		return 0
+	}
+	i, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		col.invalid(s)
+		return 0
+	}
+	return i
+}
+
+func (col column) time(record []string) model.GTFSTime {
+	s := col.text(record)
+	if s == "" {
+		return model.NoTime
+	}
+	t, err := model.ParseGTFSTime(s)
+	if err != nil {
+		col.invalid(s)
+	}
+	return t
+}
+
+func (col column) invalid(value string) {
+	col.c.issue(model.SeverityError, "invalid_value", "", col.name, "invalid %s %q", col.name, value)
+}
+
+// intern returns a canonical copy of s shared by every row of the table.
+// IDs repeat across millions of rows in large feeds, and a field kept as
+// read would also pin the whole line it was sliced from.
+func (c *csvReader) intern(s string) string {
+	if s == "" {
+		return ""
+	}
+	if v, ok := c.interned[s]; ok {
+		return v
+	}
+	s = strings.Clone(s)
+	c.interned[s] = s
+	return s
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rfurkan37/transport-app/backend/internal/gtfstest"
+)
+
+// sourceFeed is a two-stop feed whose stop names are checked after loading
+// it from each kind of source.
+func sourceFeed() gtfstest.Feed {
+	f := gtfstest.Base()
+	f["stops"] = "stop_id,stop_name,stop_lat,stop_lon\nS1,Çarşı,40.76,29.90\nS2,İzmit,40.77,29.91\n"
+	f["routes"] = "route_id,agency_id,route_short_name,route_type\nR1,A,1,3\n"
+	f["trips"] = "route_id,service_id,trip_id\nR1,ALL,T1\n"
+	f["stop_times"] = "trip_id,arrival_time,departure_time,stop_id,stop_sequence\nT1,08:00:00,08:00:00,S1,1\nT1,08:05:00,08:05:00,S2,2\n"
+	return f
+}
+
+// writeZip writes a feed into a zip archive, each table as prefix + name +
+// ext, and returns the archive's path.
+func writeZip(t *testing.T, f gtfstest.Feed, prefix, ext string) string {
+	t.Helper()
+	name := filepath.Join(t.TempDir(), "feed.zip")
+	out, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+
+	zw := zip.NewWriter(out)
+	if prefix != "" {
+		if _, err := zw.Create(prefix); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for table, content := range f {
+		w, err := zw.Create(prefix + table + ext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+// renameTables changes the extension of every table in dir.
+func renameTables(t *testing.T, dir, ext string) string {
+	t.Helper()
+	files, _ := filepath.Glob(filepath.Join(dir, "*.txt"))
+	for _, file := range files {
+		if err := os.Rename(file, strings.TrimSuffix(file, ".txt")+ext); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadGTFSSources(t *testing.T) {
+	withBOM := sourceFeed()
+	withBOM["stops"] = "\xEF\xBB\xBF" + withBOM["stops"]
+
+	tests := []struct {
+		name string
+		path func(t *testing.T) string
+	}{
+		{"directory", func(t *testing.T) string { return gtfstest.Write(t, sourceFeed()) }},
+		{"directory of .csv", func(t *testing.T) string { return renameTables(t, gtfstest.Write(t, sourceFeed()), ".csv") }},
+		{"zip", func(t *testing.T) string { return writeZip(t, sourceFeed(), "", ".txt") }},
+		{"zip of .csv", func(t *testing.T) string { return writeZip(t, sourceFeed(), "", ".csv") }},
+		{"zip with a top-level folder", func(t *testing.T) string { return writeZip(t, sourceFeed(), "gtfs/", ".txt") }},
+		{"byte order mark", func(t *testing.T) string { return writeZip(t, withBOM, "", ".txt") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := LoadGTFS(tt.path(t), DefaultLoadOptions())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(data.Stops) != 2 || len(data.StopTimesByTrip["T1"]) != 2 {
+				t.Fatalf("loaded %d stops and %d stop times, want 2 and 2", len(data.Stops), len(data.StopTimesByTrip["T1"]))
+			}
+			if got := data.Stops["S1"].Name; got != "Çarşı" {
+				t.Errorf("stop name = %q, want %q", got, "Çarşı")
+			}
+		})
+	}
+}
+
+func TestLoadGTFSMissingTable(t *testing.T) {
+	f := sourceFeed()
+	delete(f, "stop_times")
+	if _, err := LoadGTFS(writeZip(t, f, "", ".txt"), DefaultLoadOptions()); err == nil || !strings.Contains(err.Error(), "stop_times") {
+		t.Errorf("LoadGTFS() error = %v, want one naming stop_times", err)
+	}
+}
@@ -0,0 +1,237 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/rfurkan37/transport-app/backend/internal/model"
+)
+
+func loadLevels(reader *csvReader, data *model.GTFSData) error {
+	reader.require("level_id", "level_index")
+	reader.identify("level_id")
+	var (
+		id    = reader.column("level_id")
+		index = reader.column("level_index")
+		name  = reader.column("level_name")
+	)
+
+	for reader.Next() {
+		r := reader.Record()
+		level := &model.Level{
+			ID:    reader.intern(id.text(r)),
+			Index: index.float(r),
+			Name:  strings.Clone(name.text(r)),
+		}
+		if _, ok := data.Levels[level.ID]; ok {
+			reader.duplicate("level_id", level.ID)
+		}
+		data.Levels[level.ID] = level
+	}
+	if err := reader.Err(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Loaded %d levels\n", len(data.Levels))
+	return nil
+}
+
+func loadPathways(reader *csvReader, data *model.GTFSData) error {
+	reader.require("pathway_id", "from_stop_id", "to_stop_id", "pathway_mode", "is_bidirectional")
+	reader.identify("pathway_id")
+	var (
+		id                   = reader.column("pathway_id")
+		fromStopID           = reader.column("from_stop_id")
+		toStopID             = reader.column("to_stop_id")
+		mode                 = reader.column("pathway_mode")
+		bidirectional        = reader.column("is_bidirectional")
+		length               = reader.column("length")
+		traversalTime        = reader.column("traversal_time")
+		stairCount           = reader.column("stair_count")
+		maxSlope             = reader.column("max_slope")
+		minWidth             = reader.column("min_width")
+		signpostedAs         = reader.column("signposted_as")
+		reversedSignpostedAs = reader.column("reversed_signposted_as")
+	)
+
+	for reader.Next() {
+		r := reader.Record()
+		p := &model.Pathway{
+			ID:                   reader.intern(id.text(r)),
+			FromStopID:           reader.intern(fromStopID.text(r)),
+			ToStopID:             reader.intern(toStopID.text(r)),
+			Mode:                 mode.int(r),
+			IsBidirectional:      bidirectional.int(r) == 1,
+			Length:               length.float(r),
+			TraversalTime:        traversalTime.int(r),
+			StairCount:           stairCount.int(r),
+			MaxSlope:             maxSlope.float(r),
+			MinWidth:             minWidth.float(r),
+			SignpostedAs:         strings.Clone(signpostedAs.text(r)),
+			ReversedSignpostedAs: strings.Clone(reversedSignpostedAs.text(r)),
+		}
+		if _, ok := data.Pathways[p.ID]; ok {
+			reader.duplicate("pathway_id", p.ID)
+		}
+		data.Pathways[p.ID] = p
+		data.PathwaysByStop[p.FromStopID] = append(data.PathwaysByStop[p.FromStopID], p)
+		if p.ToStopID != p.FromStopID {
+			data.PathwaysByStop[p.ToStopID] = append(data.PathwaysByStop[p.ToStopID], p)
+		}
+	}
+	if err := reader.Err(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Loaded %d pathways\n", len(data.Pathways))
+	return nil
+}
+
+// indexStations builds the station tree from parent_station: the platforms,
+// entrances and nodes of each station and the boarding areas of each
+// platform, ordered by platform code and name.
+func indexStations(data *model.GTFSData) {
+	stations := 0
+	for _, stop := range data.StopsList {
+		if stop.ParentStation == "" {
+			continue
+		}
+		if _, ok := data.Stops[stop.ParentStation]; !ok {
+			continue
+		}
+		if len(data.StopChildren[stop.ParentStation]) == 0 {
+			stations++
+		}
+		data.StopChildren[stop.ParentStation] = append(data.StopChildren[stop.ParentStation], stop.ID)
+	}
+
+	for _, children := range data.StopChildren {
+		sort.Slice(children, func(i, j int) bool {
+			a, b := data.Stops[children[i]], data.Stops[children[j]]
+			if a.LocationType != b.LocationType {
+				return a.LocationType < b.LocationType
+			}
+			if a.PlatformCode != b.PlatformCode {
+				return a.PlatformCode < b.PlatformCode
+			}
+			if a.Name != b.Name {
+				return a.Name < b.Name
+			}
+			return a.ID < b.ID
+		})
+	}
+
+	if stations > 0 {
+		fmt.Printf("Indexed %d stations and platforms with children\n", stations)
+	}
+}
+
+// StationOf returns the station a stop belongs to: its parent station, the
+// station of its platform for a boarding area, or "" for a stop outside any
+// station.
+func StationOf(data *model.GTFSData, stopID string) string {
+	stop, ok := data.Stops[stopID]
+	if !ok {
+		return ""
+	}
+	if stop.LocationType == model.LocationStation {
+		return stop.ID
+	}
+	if stop.LocationType == model.LocationBoardingArea {
+		if platform, ok := data.Stops[stop.ParentStation]; ok {
+			return platform.ParentStation
+		}
+		return ""
+	}
+	return stop.ParentStation
+}
+
+// pathwayTime estimates the seconds needed to traverse a pathway when the
+// feed gives no traversal_time: its length at walking speed, slower on
+// stairs, or a fixed allowance for stairs and elevators of unknown size.
+func pathwayTime(p *model.Pathway, walkSpeed float64) float64 {
+	if p.TraversalTime > 0 {
+		return float64(p.TraversalTime)
+	}
+	switch {
+	case p.Length > 0 && p.Mode == 2:
+		return 2 * p.Length / walkSpeed
+	case p.Length > 0:
+		return p.Length / walkSpeed
+	case p.Mode == 2 && p.StairCount > 0:
+		return float64(p.StairCount) * 0.6
+	case p.Mode == 2:
+		return 30
+	case p.Mode == 5:
+		return 60
+	}
+	return 0
+}
+
+// StationPath finds the quickest way through a station's pathways between
+// two of its locations. Platforms are entered and left through any of their
+// boarding areas. It returns the time in seconds and the distance in meters
+// walked, or false if the pathways do not connect the two.
+func StationPath(data *model.GTFSData, fromID, toID string, walkSpeed float64) (seconds, meters float64, ok bool) {
+	if len(data.Pathways) == 0 || walkSpeed <= 0 {
+		return 0, 0, false
+	}
+
+	// withAreas returns a location and, for a platform, its boarding areas.
+	withAreas := func(id string) []string {
+		ids := []string{id}
+		for _, child := range data.StopChildren[id] {
+			if stop := data.Stops[child]; stop.LocationType == model.LocationBoardingArea {
+				ids = append(ids, child)
+			}
+		}
+		return ids
+	}
+	targets := make(map[string]bool)
+	for _, id := range withAreas(toID) {
+		targets[id] = true
+	}
+
+	// Dijkstra over the station's pathway graph; stations are small, so the
+	// closest unvisited location is found by a linear scan.
+	type cost struct{ time, dist float64 }
+	best := make(map[string]cost)
+	done := make(map[string]bool)
+	for _, id := range withAreas(fromID) {
+		best[id] = cost{}
+	}
+	for {
+		current, found := "", false
+		for id, c := range best {
+			if !done[id] && (!found || c.time < best[current].time) {
+				current, found = id, true
+			}
+		}
+		if !found {
+			return 0, 0, false
+		}
+		if targets[current] {
+			c := best[current]
+			return c.time, c.dist, true
+		}
+		done[current] = true
+
+		for _, p := range data.PathwaysByStop[current] {
+			next := p.ToStopID
+			if p.ToStopID == current {
+				if !p.IsBidirectional && p.FromStopID != current {
+					continue
+				}
+				next = p.FromStopID
+			}
+			if done[next] {
+				continue
+			}
+			c := cost{best[current].time + pathwayTime(p, walkSpeed), best[current].dist + p.Length}
+			if prev, seen := best[next]; !seen || c.time < prev.time {
+				best[next] = c
+			}
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/rfurkan37/transport-app/backend/internal/model"
+)
+
+func loadTranslations(reader *csvReader, data *model.GTFSData) error {
+	reader.require("table_name", "field_name", "language", "translation")
+	reader.identify("record_id")
+	var (
+		tableName   = reader.column("table_name")
+		fieldName   = reader.column("field_name")
+		language    = reader.column("language")
+		translation = reader.column("translation")
+		recordID    = reader.column("record_id")
+		recordSubID = reader.column("record_sub_id")
+		fieldValue  = reader.column("field_value")
+	)
+
+	languages := make(map[string]bool)
+	for reader.Next() {
+		r := reader.Record()
+		key := model.TranslationKey{
+			Table:       reader.intern(tableName.text(r)),
+			Field:       reader.intern(fieldName.text(r)),
+			Language:    reader.intern(NormalizeLanguage(language.text(r))),
+			RecordID:    reader.intern(recordID.text(r)),
+			RecordSubID: reader.intern(recordSubID.text(r)),
+			FieldValue:  strings.Clone(fieldValue.text(r)),
+		}
+		if key.RecordID == "" && key.FieldValue == "" {
+			reader.issue(model.SeverityError, "missing_value", "", "record_id", "translation of %s.%s needs a record_id or field_value", key.Table, key.Field)
+			continue
+		}
+		data.Translations[key] = strings.Clone(translation.text(r))
+		languages[key.Language] = true
+	}
+	if err := reader.Err(); err != nil {
+		return err
+	}
+
+	data.Languages = sortedKeys(languages)
+	fmt.Printf("Loaded %d translations in %d languages\n", len(data.Translations), len(data.Languages))
+	return nil
+}
+
+// NormalizeLanguage returns a language tag in the lowercase, hyphenated form
+// translations are indexed by.
+func NormalizeLanguage(tag string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(tag)), "_", "-")
+}
+
+// baseLanguage returns the primary subtag of a language tag, "en" for "en-us".
+func baseLanguage(tag string) string {
+	base, _, _ := strings.Cut(tag, "-")
+	return base
+}
+
+// FeedLanguage returns the language the feed's names are written in, taken
+// from agency_lang.
+func FeedLanguage(data *model.GTFSData) string {
+	for _, id := range sortedKeys(data.Agencies) {
+		if lang := data.Agencies[id].Lang; lang != "" {
+			return NormalizeLanguage(lang)
+		}
+	}
+	return ""
+}
+
+// MatchLanguage picks the first of the preferred languages, most preferred
+// first, that the feed is written in or has translations for. It returns ""
+// when none matches, meaning the feed's own names.
+func MatchLanguage(data *model.GTFSData, prefs []string) string {
+	feedLang := FeedLanguage(data)
+	for _, pref := range prefs {
+		pref = NormalizeLanguage(pref)
+		if pref == "" || pref == "*" {
+			continue
+		}
+		for _, lang := range languageFallbacks(pref) {
+			if lang == feedLang || slices.Contains(data.Languages, lang) {
+				return lang
+			}
+		}
+	}
+	return ""
+}
+
+// Translate returns the translation of a field of a record, looked up by
+// record ID and then by the original value, falling back from a regional
+// tag such as "en-gb" to "en". Without a translation the value is returned
+// unchanged.
+func Translate(data *model.GTFSData, lang, table, field, recordID, value string) string {
+	if lang == "" || len(data.Translations) == 0 {
+		return value
+	}
+	for _, l := range languageFallbacks(lang) {
+		if t, ok := data.Translations[model.TranslationKey{Table: table, Field: field, Language: l, RecordID: recordID}]; ok {
+			return t
+		}
+		if t, ok := data.Translations[model.TranslationKey{Table: table, Field: field, Language: l, FieldValue: value}]; ok && value != "" {
+			return t
+		}
+	}
+	return value
+}
+
+// languageFallbacks returns a tag followed by its primary subtag, if any.
+func languageFallbacks(tag string) []string {
+	if base := baseLanguage(tag); base != tag {
+		return []string{tag, base}
+	}
+	return []string{tag}
+}
+
+// LocalizeStop returns the stop with its name translated to lang. The stop
+// itself is shared with other requests and left untouched.
+func LocalizeStop(data *model.GTFSData, stop *model.Stop, lang string) *model.Stop {
+	if lang == "" || len(data.Translations) == 0 {
+		return stop
+	}
+	localized := *stop
+	localized.Name = Translate(data, lang, "stops", "stop_name", stop.ID, stop.Name)
+	localized.URL = Translate(data, lang, "stops", "stop_url", stop.ID, stop.URL)
+	return &localized
+}
+
+// LocalizeRoute returns the route with its names translated to lang.
+func LocalizeRoute(data *model.GTFSData, route *model.Route, lang string) *model.Route {
+	if lang == "" || len(data.Translations) == 0 {
+		return route
+	}
+	localized := *route
+	localized.ShortName = Translate(data, lang, "routes", "route_short_name", route.ID, route.ShortName)
+	localized.LongName = Translate(data, lang, "routes", "route_long_name", route.ID, route.LongName)
+	localized.Desc = Translate(data, lang, "routes", "route_desc", route.ID, route.Desc)
+	localized.URL = Translate(data, lang, "routes", "route_url", route.ID, route.URL)
+	return &localized
+}
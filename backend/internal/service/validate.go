@@ -0,0 +1,555 @@
+package service
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/rfurkan37/transport-app/backend/internal/model"
+)
+
+// maxIssuesPerCode bounds how many examples of one problem a report lists.
+// Every occurrence is still counted in the report totals.
+const maxIssuesPerCode = 50
+
+// validator accumulates the issues found in a feed.
+type validator struct {
+	data     *model.GTFSData
+	report   *model.ValidationReport
+	services map[string]bool // defined by calendar or calendar_dates
+}
+
+// ValidateGTFS checks a loaded feed for problems the loaders let through:
+// missing required fields, broken references between tables, impossible
+// coordinates, duplicate IDs, badly ordered stop times, calendar ranges and
+// fare and translation references.
+// The report is valid when no error-level issue was found.
+func ValidateGTFS(data *model.GTFSData) *model.ValidationReport {
+	v := &validator{
+		data: data,
+		report: &model.ValidationReport{
+			Codes:  make(map[string]int),
+			Issues: []model.ValidationIssue{},
+		},
+	}
+
+	v.services = make(map[string]bool)
+	for id := range data.Calendars {
+		v.services[id] = true
+	}
+	for _, exceptions := range data.CalendarDates {
+		for _, cd := range exceptions {
+			v.services[cd.ServiceID] = true
+		}
+	}
+
+	for _, issue := range data.LoadIssues {
+		v.add(issue)
+	}
+	v.agencies()
+	v.stops()
+	v.routes()
+	v.trips()
+	v.stopTimes()
+	v.calendars()
+	v.calendarDates()
+	v.frequencies()
+	v.shapes()
+	v.transfers()
+	v.pathways()
+	v.fares()
+	v.translations()
+
+	v.report.Valid = v.report.Errors == 0
+	return v.report
+}
+
+func (v *validator) add(issue model.ValidationIssue) {
+	switch issue.Severity {
+	case model.SeverityError:
+		v.report.Errors++
+	case model.SeverityWarning:
+		v.report.Warnings++
+	}
+	v.report.Codes[issue.Code]++
+	if v.report.Codes[issue.Code] <= maxIssuesPerCode {
+		v.report.Issues = append(v.report.Issues, issue)
+	}
+}
+
+func (v *validator) errorf(code, table, id, field, format string, args ...any) {
+	v.add(model.ValidationIssue{
+		Severity: model.SeverityError, Code: code, Table: table, ID: id, Field: field,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (v *validator) warnf(code, table, id, field, format string, args ...any) {
+	v.add(model.ValidationIssue{
+		Severity: model.SeverityWarning, Code: code, Table: table, ID: id, Field: field,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// sortedKeys returns the keys of m in order, so reports are stable.
+func sortedKeys[V any](m map[string]V) []string {
+	return slices.Sorted(maps.Keys(m))
+}
+
+func (v *validator) agencies() {
+	if len(v.data.Agencies) == 0 {
+		v.errorf("missing_agency", "agency", "", "", "feed has no agencies")
+		return
+	}
+
+	timezone := ""
+	for _, id := range sortedKeys(v.data.Agencies) {
+		agency := v.data.Agencies[id]
+		if id == "" && len(v.data.Agencies) > 1 {
+			v.errorf("missing_value", "agency", id, "agency_id", "agency_id is required when the feed has several agencies")
+		}
+		if agency.Timezone == "" {
+			continue
+		}
+		if _, err := time.LoadLocation(agency.Timezone); err != nil {
+			v.errorf("invalid_value", "agency", id, "agency_timezone", "unknown time zone %q", agency.Timezone)
+		}
+		if timezone == "" {
+			timezone = agency.Timezone
+		} else if agency.Timezone != timezone {
+			v.errorf("inconsistent_timezone", "agency", id, "agency_timezone", "time zone %s differs from %s used by other agencies", agency.Timezone, timezone)
+		}
+	}
+}
+
+// checkCoordinates flags locations off the globe and the 0,0 left behind by
+// an empty or unparseable field.
+func (v *validator) checkCoordinates(table, id string, lat, lon float64) {
+	switch {
+	case lat == 0 && lon == 0:
+		v.errorf("zero_coordinates", table, id, "", "location is 0,0")
+	case lat < -90 || lat > 90 || lon < -180 || lon > 180:
+		v.errorf("invalid_coordinates", table, id, "", "location %f,%f is out of range", lat, lon)
+	}
+}
+
+func (v *validator) stops() {
+	for _, id := range sortedKeys(v.data.Stops) {
+		stop := v.data.Stops[id]
+		if stop.LocationType < 0 || stop.LocationType > 4 {
+			v.errorf("invalid_value", "stops", id, "location_type", "unknown location_type %d", stop.LocationType)
+		}
+
+		// Stops, stations and entrances must be named and located;
+		// generic nodes and boarding areas may omit both.
+		if stop.LocationType <= 2 {
+			if stop.Name == "" {
+				v.errorf("missing_value", "stops", id, "stop_name", "stop_name is required for location_type %d", stop.LocationType)
+			}
+			v.checkCoordinates("stops", id, stop.Lat, stop.Lon)
+		}
+
+		if stop.ParentStation == "" {
+			if stop.LocationType >= 2 {
+				v.errorf("missing_value", "stops", id, "parent_station", "parent_station is required for location_type %d", stop.LocationType)
+			}
+			continue
+		}
+		parent, ok := v.data.Stops[stop.ParentStation]
+		switch {
+		case !ok:
+			v.errorf("unknown_reference", "stops", id, "parent_station", "parent_station %q does not exist", stop.ParentStation)
+		case stop.LocationType == 1:
+			v.errorf("invalid_value", "stops", id, "parent_station", "a station cannot have a parent_station")
+		case stop.LocationType == 4 && parent.LocationType != 0:
+			v.errorf("invalid_reference", "stops", id, "parent_station", "parent of a boarding area must be a platform")
+		case stop.LocationType != 4 && parent.LocationType != 1:
+			v.errorf("invalid_reference", "stops", id, "parent_station", "parent_station %q is not a station", stop.ParentStation)
+		}
+	}
+}
+
+// validRouteType reports whether t is a basic GTFS route type or one of the
+// extended route types.
+func validRouteType(t int) bool {
+	return (t >= 0 && t <= 7) || t == 11 || t == 12 || (t >= 100 && t <= 1702)
+}
+
+func validColor(c string) bool {
+	if len(c) != 6 {
+		return false
+	}
+	for _, ch := range c {
+		if !(ch >= '0' && ch <= '9' || ch >= 'a' && ch <= 'f' || ch >= 'A' && ch <= 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+func (v *validator) routes() {
+	for _, id := range sortedKeys(v.data.Routes) {
+		route := v.data.Routes[id]
+		if route.AgencyID == "" {
+			if len(v.data.Agencies) > 1 {
+				v.errorf("missing_value", "routes", id, "agency_id", "agency_id is required when the feed has several agencies")
+			}
+		} else if _, ok := v.data.Agencies[route.AgencyID]; !ok {
+			v.errorf("unknown_reference", "routes", id, "agency_id", "agency %q does not exist", route.AgencyID)
+		}
+
+		if route.ShortName == "" && route.LongName == "" {
+			v.errorf("missing_value", "routes", id, "route_short_name", "route needs a route_short_name or route_long_name")
+		}
+		if !validRouteType(route.Type) {
+			v.errorf("invalid_value", "routes", id, "route_type", "unknown route_type %d", route.Type)
+		}
+		if route.Color != "" && !validColor(route.Color) {
+			v.errorf("invalid_value", "routes", id, "route_color", "route_color %q is not a hex color", route.Color)
+		}
+		if route.TextColor != "" && !validColor(route.TextColor) {
+			v.errorf("invalid_value", "routes", id, "route_text_color", "route_text_color %q is not a hex color", route.TextColor)
+		}
+	}
+}
+
+func (v *validator) trips() {
+	for _, id := range sortedKeys(v.data.Trips) {
+		trip := v.data.Trips[id]
+		if _, ok := v.data.Routes[trip.RouteID]; !ok {
+			v.errorf("unknown_reference", "trips", id, "route_id", "route %q does not exist", trip.RouteID)
+		}
+		if !v.services[trip.ServiceID] {
+			v.errorf("unknown_reference", "trips", id, "service_id", "service %q does not exist", trip.ServiceID)
+		}
+		if trip.ShapeID != "" {
+			if _, ok := v.data.Shapes[trip.ShapeID]; !ok {
+				v.errorf("unknown_reference", "trips", id, "shape_id", "shape %q does not exist", trip.ShapeID)
+			}
+		}
+		if _, ok := v.data.StopTimesByTrip[id]; !ok {
+			v.warnf("unused_trip", "trips", id, "", "trip has no stop times")
+		}
+	}
+}
+
+// tripExists reports whether a trip is in the feed, as a scheduled trip or
+// as the template of a frequency-based one.
+func (v *validator) tripExists(id string) bool {
+	_, scheduled := v.data.Trips[id]
+	_, template := v.data.TripTemplates[id]
+	return scheduled || template
+}
+
+func (v *validator) stopTimes() {
+	for _, tripID := range sortedKeys(v.data.StopTimesByTrip) {
+		times := v.data.StopTimesByTrip[tripID]
+		if _, ok := v.data.Trips[tripID]; !ok {
+			v.errorf("unknown_reference", "stop_times", tripID, "trip_id", "trip %q does not exist", tripID)
+		}
+		if len(times) < 2 {
+			v.warnf("too_few_stops", "stop_times", tripID, "", "trip visits fewer than two stops")
+		}
+
+		for i, st := range times {
+			if _, ok := v.data.Stops[st.StopID]; !ok {
+				v.errorf("unknown_reference", "stop_times", tripID, "stop_id", "stop %q does not exist", st.StopID)
+			}
+			if st.ArrivalTime > st.DepartureTime {
+				v.errorf("decreasing_time", "stop_times", tripID, "departure_time", "departure %s precedes arrival %s at stop_sequence %d", st.DepartureTime, st.ArrivalTime, st.StopSequence)
+			}
+			if i == 0 {
+				continue
+			}
+			prev := times[i-1]
+			if st.StopSequence == prev.StopSequence {
+				v.errorf("duplicate_id", "stop_times", tripID, "stop_sequence", "stop_sequence %d appears twice", st.StopSequence)
+			}
+			if prev.DepartureTime != model.NoTime && st.ArrivalTime != model.NoTime && st.ArrivalTime < prev.DepartureTime {
+				v.errorf("decreasing_time", "stop_times", tripID, "arrival_time", "arrival %s at stop_sequence %d precedes departure %s from the previous stop", st.ArrivalTime, st.StopSequence, prev.DepartureTime)
+			}
+		}
+
+		if len(times) > 0 {
+			first, last := times[0], times[len(times)-1]
+			if first.DepartureTime == model.NoTime {
+				v.errorf("missing_value", "stop_times", tripID, "departure_time", "first stop of the trip has no time")
+			}
+			if last.ArrivalTime == model.NoTime {
+				v.errorf("missing_value", "stop_times", tripID, "arrival_time", "last stop of the trip has no time")
+			}
+		}
+	}
+}
+
+func (v *validator) calendars() {
+	used := make(map[string]bool)
+	for _, trip := range v.data.Trips {
+		used[trip.ServiceID] = true
+	}
+	added := make(map[string]bool)
+	for _, exceptions := range v.data.CalendarDates {
+		for _, cd := range exceptions {
+			if cd.ExceptionType == model.ServiceAdded {
+				added[cd.ServiceID] = true
+			}
+		}
+	}
+	today := time.Now().In(AgencyLocation(v.data)).Format(gtfsDateLayout)
+
+	for _, id := range sortedKeys(v.data.Calendars) {
+		cal := v.data.Calendars[id]
+		start, errStart := time.Parse(gtfsDateLayout, cal.StartDate)
+		end, errEnd := time.Parse(gtfsDateLayout, cal.EndDate)
+		if errStart != nil {
+			v.errorf("invalid_value", "calendar", id, "start_date", "invalid start_date %q", cal.StartDate)
+		}
+		if errEnd != nil {
+			v.errorf("invalid_value", "calendar", id, "end_date", "invalid end_date %q", cal.EndDate)
+		}
+		if errStart == nil && errEnd == nil {
+			if end.Before(start) {
+				v.errorf("invalid_date_range", "calendar", id, "end_date", "end_date %s is before start_date %s", cal.EndDate, cal.StartDate)
+			} else if cal.EndDate < today {
+				v.warnf("expired_service", "calendar", id, "end_date", "service ended on %s", cal.EndDate)
+			}
+		}
+
+		if cal.Monday+cal.Tuesday+cal.Wednesday+cal.Thursday+cal.Friday+cal.Saturday+cal.Sunday == 0 && !added[id] {
+			v.warnf("no_service_days", "calendar", id, "", "service runs on no day of the week")
+		}
+		if !used[id] {
+			v.warnf("unused_service", "calendar", id, "", "no trip uses this service")
+		}
+	}
+}
+
+func (v *validator) calendarDates() {
+	for _, date := range sortedKeys(v.data.CalendarDates) {
+		if _, err := time.Parse(gtfsDateLayout, date); err != nil {
+			v.errorf("invalid_value", "calendar_dates", date, "date", "invalid date %q", date)
+		}
+		seen := make(map[string]bool)
+		for _, cd := range v.data.CalendarDates[date] {
+			if cd.ExceptionType != model.ServiceAdded && cd.ExceptionType != model.ServiceRemoved {
+				v.errorf("invalid_value", "calendar_dates", cd.ServiceID, "exception_type", "unknown exception_type %d on %s", cd.ExceptionType, date)
+			}
+			if seen[cd.ServiceID] {
+				v.errorf("duplicate_id", "calendar_dates", cd.ServiceID, "date", "service has several exceptions on %s", date)
+			}
+			seen[cd.ServiceID] = true
+		}
+	}
+}
+
+func (v *validator) frequencies() {
+	for _, tripID := range sortedKeys(v.data.Frequencies) {
+		if !v.tripExists(tripID) {
+			v.errorf("unknown_reference", "frequencies", tripID, "trip_id", "trip %q does not exist", tripID)
+		}
+		for _, f := range v.data.Frequencies[tripID] {
+			if f.HeadwaySecs <= 0 {
+				v.errorf("invalid_value", "frequencies", tripID, "headway_secs", "headway_secs must be positive")
+			}
+			if f.EndTime <= f.StartTime {
+				v.errorf("invalid_date_range", "frequencies", tripID, "end_time", "end_time %s is not after start_time %s", f.EndTime, f.StartTime)
+			}
+			if f.ExactTimes != 0 && f.ExactTimes != 1 {
+				v.errorf("invalid_value", "frequencies", tripID, "exact_times", "unknown exact_times %d", f.ExactTimes)
+			}
+		}
+	}
+}
+
+func (v *validator) shapes() {
+	for _, id := range sortedKeys(v.data.Shapes) {
+		points := v.data.Shapes[id]
+		if len(points) < 2 {
+			v.warnf("too_few_points", "shapes", id, "", "shape has fewer than two points")
+		}
+		for i, p := range points {
+			v.checkCoordinates("shapes", id, p.Lat, p.Lon)
+			if i > 0 && p.Sequence == points[i-1].Sequence {
+				v.errorf("duplicate_id", "shapes", id, "shape_pt_sequence", "shape_pt_sequence %d appears twice", p.Sequence)
+			}
+		}
+	}
+}
+
+func (v *validator) transfers() {
+	for _, fromID := range sortedKeys(v.data.Transfers) {
+		for _, t := range v.data.Transfers[fromID] {
+			if t.Source != "gtfs" {
+				continue
+			}
+			if _, ok := v.data.Stops[t.FromStopID]; !ok {
+				v.errorf("unknown_reference", "transfers", t.FromStopID, "from_stop_id", "stop %q does not exist", t.FromStopID)
+			}
+			if _, ok := v.data.Stops[t.ToStopID]; !ok {
+				v.errorf("unknown_reference", "transfers", t.FromStopID, "to_stop_id", "stop %q does not exist", t.ToStopID)
+			}
+			if t.Type < 0 || t.Type > 5 {
+				v.errorf("invalid_value", "transfers", t.FromStopID, "transfer_type", "unknown transfer_type %d", t.Type)
+			}
+		}
+	}
+}
+
+func (v *validator) pathways() {
+	for _, id := range sortedKeys(v.data.Stops) {
+		if level := v.data.Stops[id].LevelID; level != "" {
+			if _, ok := v.data.Levels[level]; !ok {
+				v.errorf("unknown_reference", "stops", id, "level_id", "level %q does not exist", level)
+			}
+		}
+	}
+
+	for _, id := range sortedKeys(v.data.Pathways) {
+		p := v.data.Pathways[id]
+		for _, f := range [][2]string{{"from_stop_id", p.FromStopID}, {"to_stop_id", p.ToStopID}} {
+			stop, ok := v.data.Stops[f[1]]
+			switch {
+			case !ok:
+				v.errorf("unknown_reference", "pathways", id, f[0], "stop %q does not exist", f[1])
+			case stop.LocationType == model.LocationStation:
+				v.errorf("invalid_reference", "pathways", id, f[0], "pathways cannot start or end at station %q", f[1])
+			}
+		}
+		if p.FromStopID == p.ToStopID {
+			v.warnf("invalid_reference", "pathways", id, "to_stop_id", "pathway leads from a stop to itself")
+		}
+		if p.Mode < 1 || p.Mode > 7 {
+			v.errorf("invalid_value", "pathways", id, "pathway_mode", "unknown pathway_mode %d", p.Mode)
+		}
+		if p.Mode == 7 && p.IsBidirectional {
+			v.errorf("invalid_value", "pathways", id, "is_bidirectional", "exit gates must be one-way")
+		}
+		if p.Length < 0 || p.TraversalTime < 0 {
+			v.errorf("invalid_value", "pathways", id, "length", "length and traversal_time must not be negative")
+		}
+	}
+}
+
+func (v *validator) fares() {
+	for _, id := range sortedKeys(v.data.FareAttributes) {
+		fare := v.data.FareAttributes[id]
+		if fare.Price < 0 {
+			v.errorf("invalid_value", "fare_attributes", id, "price", "price must not be negative")
+		}
+		if fare.PaymentMethod != 0 && fare.PaymentMethod != 1 {
+			v.errorf("invalid_value", "fare_attributes", id, "payment_method", "unknown payment_method %d", fare.PaymentMethod)
+		}
+		if fare.Transfers < -1 || fare.Transfers > 2 {
+			v.errorf("invalid_value", "fare_attributes", id, "transfers", "unknown transfers %d", fare.Transfers)
+		}
+		if fare.AgencyID != "" {
+			if _, ok := v.data.Agencies[fare.AgencyID]; !ok {
+				v.errorf("unknown_reference", "fare_attributes", id, "agency_id", "agency %q does not exist", fare.AgencyID)
+			}
+		}
+	}
+
+	zones := make(map[string]bool)
+	for _, stop := range v.data.Stops {
+		zones[stop.ZoneID] = true
+	}
+	for _, id := range sortedKeys(v.data.FareRules) {
+		if _, ok := v.data.FareAttributes[id]; !ok {
+			v.errorf("unknown_reference", "fare_rules", id, "fare_id", "fare %q does not exist", id)
+		}
+		for _, rule := range v.data.FareRules[id] {
+			if rule.RouteID != "" {
+				if _, ok := v.data.Routes[rule.RouteID]; !ok {
+					v.errorf("unknown_reference", "fare_rules", id, "route_id", "route %q does not exist", rule.RouteID)
+				}
+			}
+			for _, f := range [][2]string{{"origin_id", rule.OriginID}, {"destination_id", rule.DestinationID}, {"contains_id", rule.ContainsID}} {
+				if f[1] != "" && !zones[f[1]] {
+					v.errorf("unknown_reference", "fare_rules", id, f[0], "no stop has zone_id %q", f[1])
+				}
+			}
+		}
+	}
+
+	areas := make(map[string]bool)
+	for _, stopID := range sortedKeys(v.data.StopAreas) {
+		if _, ok := v.data.Stops[stopID]; !ok {
+			v.errorf("unknown_reference", "stop_areas", stopID, "stop_id", "stop %q does not exist", stopID)
+		}
+		for _, area := range v.data.StopAreas[stopID] {
+			areas[area] = true
+		}
+	}
+	networks := make(map[string]bool)
+	for _, routeID := range sortedKeys(v.data.RouteNetworks) {
+		if _, ok := v.data.Routes[routeID]; !ok {
+			v.errorf("unknown_reference", "route_networks", routeID, "route_id", "route %q does not exist", routeID)
+		}
+		networks[v.data.RouteNetworks[routeID]] = true
+	}
+	for _, route := range v.data.Routes {
+		networks[route.NetworkID] = true
+	}
+
+	for _, rule := range v.data.FareLegRules {
+		if _, ok := v.data.FareProducts[rule.FareProductID]; !ok {
+			v.errorf("unknown_reference", "fare_leg_rules", rule.LegGroupID, "fare_product_id", "fare product %q does not exist", rule.FareProductID)
+		}
+		if rule.NetworkID != "" && !networks[rule.NetworkID] {
+			v.warnf("unknown_reference", "fare_leg_rules", rule.LegGroupID, "network_id", "no route belongs to network %q", rule.NetworkID)
+		}
+		for _, f := range [][2]string{{"from_area_id", rule.FromAreaID}, {"to_area_id", rule.ToAreaID}} {
+			if f[1] != "" && !areas[f[1]] {
+				v.errorf("unknown_reference", "fare_leg_rules", rule.LegGroupID, f[0], "area %q has no stops", f[1])
+			}
+		}
+	}
+	for _, rule := range v.data.FareTransferRules {
+		if rule.FareProductID != "" {
+			if _, ok := v.data.FareProducts[rule.FareProductID]; !ok {
+				v.errorf("unknown_reference", "fare_transfer_rules", rule.FromLegGroupID, "fare_product_id", "fare product %q does not exist", rule.FareProductID)
+			}
+		}
+		if rule.FareTransferType < 0 || rule.FareTransferType > 2 {
+			v.errorf("invalid_value", "fare_transfer_rules", rule.FromLegGroupID, "fare_transfer_type", "unknown fare_transfer_type %d", rule.FareTransferType)
+		}
+		if rule.DurationLimitType < 0 || rule.DurationLimitType > 3 {
+			v.errorf("invalid_value", "fare_transfer_rules", rule.FromLegGroupID, "duration_limit_type", "unknown duration_limit_type %d", rule.DurationLimitType)
+		}
+	}
+}
+
+func (v *validator) translations() {
+	keys := slices.SortedFunc(maps.Keys(v.data.Translations), func(a, b model.TranslationKey) int {
+		return cmp.Or(
+			cmp.Compare(a.Table, b.Table), cmp.Compare(a.RecordID, b.RecordID), cmp.Compare(a.Field, b.Field),
+			cmp.Compare(a.Language, b.Language), cmp.Compare(a.FieldValue, b.FieldValue),
+		)
+	})
+	for _, key := range keys {
+		if key.Language == "" {
+			v.errorf("missing_value", "translations", key.RecordID, "language", "translation of %s.%s has no language", key.Table, key.Field)
+		}
+		if key.RecordID == "" {
+			continue
+		}
+		var ok bool
+		switch key.Table {
+		case "agency":
+			_, ok = v.data.Agencies[key.RecordID]
+		case "stops":
+			_, ok = v.data.Stops[key.RecordID]
+		case "routes":
+			_, ok = v.data.Routes[key.RecordID]
+		case "trips":
+			ok = v.tripExists(key.RecordID)
+		default:
+			continue
+		}
+		if !ok {
+			v.warnf("unknown_reference", "translations", key.RecordID, "record_id", "%s record %q does not exist", key.Table, key.RecordID)
+		}
+	}
+}
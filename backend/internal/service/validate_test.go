@@ -0,0 +1,74 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rfurkan37/transport-app/backend/internal/gtfstest"
+	"github.com/rfurkan37/transport-app/backend/internal/model"
+)
+
+func TestValidateGTFS(t *testing.T) {
+	tests := []struct {
+		name      string
+		change    func(f gtfstest.Feed)
+		wantCode  string // empty for a feed without issues
+		wantTable string
+		wantValid bool
+	}{
+		{"valid", func(gtfstest.Feed) {}, "", "", true},
+		{"unknown route", func(f gtfstest.Feed) {
+			f["trips"] = "route_id,service_id,trip_id\nR9,ALL,T1\n"
+		}, "unknown_reference", "trips", false},
+		{"unknown stop", func(f gtfstest.Feed) {
+			f["stop_times"] += "T1,08:10:00,08:10:00,S9,3\n"
+		}, "unknown_reference", "stop_times", false},
+		{"impossible coordinates", func(f gtfstest.Feed) {
+			f["stops"] += "S3,Nowhere,91,29.90\n"
+		}, "invalid_coordinates", "stops", false},
+		{"duplicate stop", func(f gtfstest.Feed) {
+			f["stops"] += "S1,Again,40.78,29.92\n"
+		}, "duplicate_id", "stops", false},
+		{"decreasing times", func(f gtfstest.Feed) {
+			f["stop_times"] += "T1,07:55:00,07:55:00,S1,3\n"
+		}, "decreasing_time", "stop_times", false},
+		{"calendar ends before it starts", func(f gtfstest.Feed) {
+			f["calendar"] = strings.Replace(f["calendar"], "20200101,20991231", "20991231,20200101", 1)
+		}, "invalid_date_range", "calendar", false},
+		{"route color", func(f gtfstest.Feed) {
+			f["routes"] = "route_id,agency_id,route_short_name,route_type,route_color\nR1,A,1,3,red\n"
+		}, "invalid_value", "routes", false},
+		{"trip without stop times", func(f gtfstest.Feed) {
+			f["trips"] += "R1,ALL,T2\n"
+		}, "unused_trip", "trips", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := sourceFeed()
+			tt.change(f)
+			report := ValidateGTFS(loadFeed(t, f))
+
+			if report.Valid != tt.wantValid {
+				t.Errorf("valid = %v, want %v (%d errors)", report.Valid, tt.wantValid, report.Errors)
+			}
+			if tt.wantCode == "" {
+				if len(report.Issues) > 0 {
+					t.Errorf("unexpected issues: %+v", report.Issues)
+				}
+				return
+			}
+			if !hasIssue(report, tt.wantCode, tt.wantTable) {
+				t.Errorf("no %s issue in %s, got %+v", tt.wantCode, tt.wantTable, report.Issues)
+			}
+		})
+	}
+}
+
+func hasIssue(report *model.ValidationReport, code, table string) bool {
+	for _, issue := range report.Issues {
+		if issue.Code == code && issue.Table == table {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"math"
+	"sort"
+
+	"github.com/rfurkan37/transport-app/backend/internal/geo"
+	"github.com/rfurkan37/transport-app/backend/internal/model"
+)
+
+// Shape matching tolerances in meters
+const (
+	shapeMatchRadius = 150 // from the shape to a vehicle or stop; GPS fixes and stops are rarely on the line
+	stopOvershoot    = 30  // a vehicle at the stop may project this far past it
+)
+
+// measureToStop sets how far a vehicle has left to go to a stop. The
+// distance is measured along the shape of the vehicle's trip or, when the
+// trip is unknown, of the route's trips calling at the stop, taking the
+// shortest on which the stop is still ahead. Without such a shape it is the
+// straight-line distance.
+func measureToStop(data *model.GTFSData, v *model.Vehicle, stop *model.Stop) {
+	best, bestShape := math.Inf(1), ""
+	for _, shapeID := range vehicleShapes(data, v, stop.ID) {
+		points := data.Shapes[shapeID]
+		coords := make([][2]float64, len(points))
+		for i, p := range points {
+			coords[i] = [2]float64{p.Lon, p.Lat}
+		}
+
+		stopAlong, stopOffset := geo.ProjectOnLine(coords, stop.Lat, stop.Lon)
+		vehicleAlong, vehicleOffset := geo.ProjectOnLine(coords, v.Lat, v.Lon)
+		if stopOffset > shapeMatchRadius || vehicleOffset > shapeMatchRadius {
+			continue
+		}
+		if d := stopAlong - vehicleAlong; d > -stopOvershoot && d < best {
+			best, bestShape = max(d, 0), shapeID
+		}
+	}
+
+	if bestShape == "" {
+		best = geo.HaversineDistance(v.Lat, v.Lon, stop.Lat, stop.Lon)
+	}
+	best = math.Round(best)
+	v.DistanceToStop = &best
+	v.ShapeID = bestShape
+	v.AlongShape = bestShape != ""
+}
+
+// vehicleShapes returns the shapes a vehicle may be following to a stop:
+// that of its trip if known, else those of its route's trips calling at the
+// stop or, for a station, its platforms.
+func vehicleShapes(data *model.GTFSData, v *model.Vehicle, stopID string) []string {
+	if trip, ok := data.Trips[v.TripID]; ok && trip.ShapeID != "" {
+		if _, ok := data.Shapes[trip.ShapeID]; ok {
+			return []string{trip.ShapeID}
+		}
+	}
+	if v.RouteID == "" {
+		return nil
+	}
+
+	shapes := make(map[string]bool)
+	for _, id := range append([]string{stopID}, data.StopChildren[stopID]...) {
+		for _, st := range data.StopTimesByStop[id] {
+			trip, ok := data.Trips[st.TripID]
+			if !ok || trip.RouteID != v.RouteID || trip.ShapeID == "" {
+				continue
+			}
+			if _, ok := data.Shapes[trip.ShapeID]; ok {
+				shapes[trip.ShapeID] = true
+			}
+		}
+	}
+	return sortedKeys(shapes)
+}
+
+// routeSampleStops is how many stops of each direction of a route are
+// asked for the buses approaching them.
+const routeSampleStops = 4
+
+// routeStops returns up to routeSampleStops stops of each direction of a
+// route, evenly spaced along its trip with the most stops in that direction
+// and ending with its last stop. The first stop is left out: buses
+// approaching it have not started the trip yet.
+func routeStops(data *model.GTFSData, routeID string) []string {
+	longest := make(map[int]*model.Trip)
+	for _, trip := range data.Trips {
+		if trip.RouteID != routeID {
+			continue
+		}
+		n := len(data.StopTimesByTrip[trip.TripID])
+		if n == 0 {
+			continue
+		}
+		best, ok := longest[trip.DirectionID]
+		if !ok {
+			longest[trip.DirectionID] = trip
+			continue
+		}
+		if bn := len(data.StopTimesByTrip[best.TripID]); n > bn || n == bn && trip.TripID < best.TripID {
+			longest[trip.DirectionID] = trip
+		}
+	}
+
+	directions := make([]int, 0, len(longest))
+	for dir := range longest {
+		directions = append(directions, dir)
+	}
+	sort.Ints(directions)
+
+	var stops []string
+	seen := make(map[string]bool)
+	for _, dir := range directions {
+		stopTimes := data.StopTimesByTrip[longest[dir].TripID]
+		rest := max(len(stopTimes)-1, 1)
+		for k := 1; k <= routeSampleStops; k++ {
+			i := len(stopTimes) - 1 - (routeSampleStops-k)*rest/routeSampleStops
+			if id := stopTimes[i].StopID; !seen[id] {
+				seen[id] = true
+				stops = append(stops, id)
+			}
+		}
+	}
+	return stops
+}
@@ -0,0 +1,218 @@
+// Package spatial provides a grid index for geographic point lookups.
+package spatial
+
+import (
+	"math"
+	"sort"
+
+	"github.com/rfurkan37/transport-app/backend/internal/geo"
+)
+
+// metersPerDegreeLat is the approximate length of one degree of latitude.
+const metersPerDegreeLat = 111320.0
+
+// Result is an indexed item with its distance in meters from a query point.
+type Result[T any] struct {
+	Item     T
+	Distance float64
+}
+
+type entry[T any] struct {
+	item     T
+	lat, lon float64
+}
+
+type cell struct {
+	x, y int
+}
+
+// Grid buckets points into roughly square cells of a fixed size, so radius,
+// nearest-neighbour and bounding-box queries only visit nearby cells.
+type Grid[T any] struct {
+	latStep, lonStep float64 // cell size in degrees
+	cellMeters       float64
+	cells            map[cell][]entry[T]
+	minX, maxX       int
+	minY, maxY       int
+	bounds           geo.BBox
+	size             int
+}
+
+// NewGrid indexes items located by locate into cells of cellMeters.
+func NewGrid[T any](items []T, locate func(T) (lat, lon float64), cellMeters float64) *Grid[T] {
+	var sumLat float64
+	for _, item := range items {
+		lat, _ := locate(item)
+		sumLat += lat
+	}
+	meanLat := 0.0
+	if len(items) > 0 {
+		meanLat = sumLat / float64(len(items))
+	}
+
+	g := &Grid[T]{
+		latStep:    cellMeters / metersPerDegreeLat,
+		lonStep:    cellMeters / (metersPerDegreeLat * math.Max(math.Cos(meanLat*math.Pi/180), 0.01)),
+		cellMeters: cellMeters,
+		cells:      make(map[cell][]entry[T]),
+		minX:       math.MaxInt, maxX: math.MinInt,
+		minY: math.MaxInt, maxY: math.MinInt,
+		bounds: geo.BBox{MinLon: math.Inf(1), MinLat: math.Inf(1), MaxLon: math.Inf(-1), MaxLat: math.Inf(-1)},
+	}
+
+	for _, item := range items {
+		lat, lon := locate(item)
+		c := g.cellOf(lat, lon)
+		g.cells[c] = append(g.cells[c], entry[T]{item: item, lat: lat, lon: lon})
+		g.minX, g.maxX = min(g.minX, c.x), max(g.maxX, c.x)
+		g.minY, g.maxY = min(g.minY, c.y), max(g.maxY, c.y)
+		g.bounds.MinLon, g.bounds.MaxLon = min(g.bounds.MinLon, lon), max(g.bounds.MaxLon, lon)
+		g.bounds.MinLat, g.bounds.MaxLat = min(g.bounds.MinLat, lat), max(g.bounds.MaxLat, lat)
+		g.size++
+	}
+	return g
+}
+
+// Len returns the number of indexed items.
+func (g *Grid[T]) Len() int {
+	return g.size
+}
+
+// Bounds returns the bounding box of the indexed items. It is empty, with
+// infinite edges, when there are none.
+func (g *Grid[T]) Bounds() geo.BBox {
+	return g.bounds
+}
+
+func (g *Grid[T]) cellOf(lat, lon float64) cell {
+	return cell{x: int(math.Floor(lon / g.lonStep)), y: int(math.Floor(lat / g.latStep))}
+}
+
+// Radius returns the items within meters of a point, nearest first.
+func (g *Grid[T]) Radius(lat, lon, meters float64) []Result[T] {
+	latSpan := meters / metersPerDegreeLat
+	lonSpan := latSpan / math.Max(math.Cos(lat*math.Pi/180), 0.01)
+	lo := g.cellOf(lat-latSpan, lon-lonSpan)
+	hi := g.cellOf(lat+latSpan, lon+lonSpan)
+
+	var results []Result[T]
+	for x := max(lo.x, g.minX); x <= min(hi.x, g.maxX); x++ {
+		for y := max(lo.y, g.minY); y <= min(hi.y, g.maxY); y++ {
+			for _, e := range g.cells[cell{x, y}] {
+				if d := geo.HaversineDistance(lat, lon, e.lat, e.lon); d <= meters {
+					results = append(results, Result[T]{Item: e.item, Distance: d})
+				}
+			}
+		}
+	}
+	sortByDistance(results)
+	return results
+}
+
+// Nearest returns the k items closest to a point within maxMeters of it,
+// nearest first. It searches square rings of cells outward from the point,
+// starting at the first ring that reaches an indexed cell, until no cell
+// further out can hold a closer item or the rings pass maxMeters.
+func (g *Grid[T]) Nearest(lat, lon float64, k int, maxMeters float64) []Result[T] {
+	if k <= 0 || g.size == 0 {
+		return nil
+	}
+
+	center := g.cellOf(lat, lon)
+	// A ring's cells are at least this far from the point per ring inside
+	// it, along whichever axis the cells are shorter at this latitude.
+	step := min(g.cellMeters, g.lonStep*metersPerDegreeLat*math.Cos(lat*math.Pi/180))
+	first := max(g.minX-center.x, center.x-g.maxX, g.minY-center.y, center.y-g.maxY, 0)
+	last := max(abs(center.x-g.minX), abs(center.x-g.maxX), abs(center.y-g.minY), abs(center.y-g.maxY))
+	if step > 0 {
+		last = min(last, int(math.Ceil(maxMeters/step))+1)
+	}
+
+	var results []Result[T]
+	for ring := first; ring <= last; ring++ {
+		g.visitRing(center, ring, func(entries []entry[T]) {
+			for _, e := range entries {
+				if d := geo.HaversineDistance(lat, lon, e.lat, e.lon); d <= maxMeters {
+					results = append(results, Result[T]{Item: e.item, Distance: d})
+				}
+			}
+		})
+
+		// Everything beyond this ring is at least ring cells away.
+		if len(results) >= k {
+			sortByDistance(results)
+			if results[k-1].Distance <= float64(ring)*step {
+				break
+			}
+		}
+	}
+
+	sortByDistance(results)
+	if len(results) > k {
+		results = results[:k]
+	}
+	return results
+}
+
+// visitRing calls visit for the indexed cells on the perimeter of the
+// square ring of cells r cells from center.
+func (g *Grid[T]) visitRing(center cell, r int, visit func([]entry[T])) {
+	if r == 0 {
+		visit(g.cells[center])
+		return
+	}
+	x0, x1 := center.x-r, center.x+r
+	y0, y1 := center.y-r, center.y+r
+
+	// Bottom and top rows, then the sides between them
+	for _, y := range [2]int{y0, y1} {
+		if y < g.minY || y > g.maxY {
+			continue
+		}
+		for x := max(x0, g.minX); x <= min(x1, g.maxX); x++ {
+			visit(g.cells[cell{x, y}])
+		}
+	}
+	for _, x := range [2]int{x0, x1} {
+		if x < g.minX || x > g.maxX {
+			continue
+		}
+		for y := max(y0+1, g.minY); y <= min(y1-1, g.maxY); y++ {
+			visit(g.cells[cell{x, y}])
+		}
+	}
+}
+
+// BBox returns the items inside a bounding box, nearest to its center first.
+func (g *Grid[T]) BBox(minLat, minLon, maxLat, maxLon float64) []Result[T] {
+	lo := g.cellOf(minLat, minLon)
+	hi := g.cellOf(maxLat, maxLon)
+	centerLat, centerLon := (minLat+maxLat)/2, (minLon+maxLon)/2
+
+	var results []Result[T]
+	for x := max(lo.x, g.minX); x <= min(hi.x, g.maxX); x++ {
+		for y := max(lo.y, g.minY); y <= min(hi.y, g.maxY); y++ {
+			for _, e := range g.cells[cell{x, y}] {
+				if e.lat < minLat || e.lat > maxLat || e.lon < minLon || e.lon > maxLon {
+					continue
+				}
+				results = append(results, Result[T]{Item: e.item, Distance: geo.HaversineDistance(centerLat, centerLon, e.lat, e.lon)})
+			}
+		}
+	}
+	sortByDistance(results)
+	return results
+}
+
+func sortByDistance[T any](results []Result[T]) {
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Distance < results[j].Distance
+	})
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,131 @@
+package spatial
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/rfurkan37/transport-app/backend/internal/geo"
+)
+
+type point struct {
+	id       int
+	lat, lon float64
+}
+
+func locate(p point) (float64, float64) { return p.lat, p.lon }
+
+// randomPoints scatters n points over roughly 20 km around Izmit.
+func randomPoints(n int) []point {
+	rng := rand.New(rand.NewSource(1))
+	points := make([]point, n)
+	for i := range points {
+		points[i] = point{id: i, lat: 40.70 + rng.Float64()*0.2, lon: 29.80 + rng.Float64()*0.25}
+	}
+	return points
+}
+
+func TestNearestMatchesBruteForce(t *testing.T) {
+	points := randomPoints(2000)
+	g := NewGrid(points, locate, 250)
+
+	tests := []struct {
+		name      string
+		lat, lon  float64
+		k         int
+		maxMeters float64
+	}{
+		{"inside", 40.80, 29.90, 5, 20000},
+		{"edge", 40.70, 29.80, 3, 20000},
+		{"outside within cap", 40.65, 29.90, 2, 20000},
+		{"small cap", 40.80, 29.90, 50, 300},
+		{"many", 40.76, 29.93, 100, 20000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var want []float64
+			for _, p := range points {
+				if d := geo.HaversineDistance(tt.lat, tt.lon, p.lat, p.lon); d <= tt.maxMeters {
+					want = append(want, d)
+				}
+			}
+			sort.Float64s(want)
+			want = want[:min(len(want), tt.k)]
+
+			got := g.Nearest(tt.lat, tt.lon, tt.k, tt.maxMeters)
+			if len(got) != len(want) {
+				t.Fatalf("got %d results, want %d", len(got), len(want))
+			}
+			for i := range got {
+				if got[i].Distance != want[i] {
+					t.Errorf("result %d at %.1f m, want %.1f m", i, got[i].Distance, want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestNearestFarFromData(t *testing.T) {
+	g := NewGrid(randomPoints(2000), locate, 250)
+
+	for _, q := range [][2]float64{{0, 0}, {42.5, 29.9}, {-89.9, 179.9}} {
+		start := time.Now()
+		if got := g.Nearest(q[0], q[1], 1, 20000); len(got) != 0 {
+			t.Errorf("Nearest(%v) = %d results beyond the cap", q, len(got))
+		}
+		if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+			t.Errorf("Nearest(%v) took %v", q, elapsed)
+		}
+	}
+}
+
+func TestBBoxMatchesBruteForce(t *testing.T) {
+	points := randomPoints(2000)
+	g := NewGrid(points, locate, 250)
+
+	tests := []struct {
+		name                           string
+		minLat, minLon, maxLat, maxLon float64
+	}{
+		{"inside", 40.75, 29.85, 40.80, 29.95},
+		{"overlapping an edge", 40.60, 29.70, 40.72, 29.83},
+		{"covering everything", 40, 29, 42, 31},
+		{"outside", 41.5, 29.85, 41.6, 29.95},
+		{"thin", 40.70, 29.90, 40.90, 29.9005},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := 0
+			for _, p := range points {
+				if p.lat >= tt.minLat && p.lat <= tt.maxLat && p.lon >= tt.minLon && p.lon <= tt.maxLon {
+					want++
+				}
+			}
+			got := g.BBox(tt.minLat, tt.minLon, tt.maxLat, tt.maxLon)
+			if len(got) != want {
+				t.Errorf("got %d points, want %d", len(got), want)
+			}
+			for i := 1; i < len(got); i++ {
+				if got[i].Distance < got[i-1].Distance {
+					t.Fatalf("result %d is nearer the center than result %d", i, i-1)
+				}
+			}
+		})
+	}
+}
+
+func TestBounds(t *testing.T) {
+	g := NewGrid([]point{{0, 40.7, 29.8}, {1, 40.9, 30.1}, {2, 40.8, 29.9}}, locate, 250)
+	want := geo.BBox{MinLon: 29.8, MinLat: 40.7, MaxLon: 30.1, MaxLat: 40.9}
+	if got := g.Bounds(); got != want {
+		t.Errorf("Bounds() = %+v, want %+v", got, want)
+	}
+}
+
+func BenchmarkNearestFar(b *testing.B) {
+	g := NewGrid(randomPoints(10000), locate, 250)
+	for b.Loop() {
+		g.Nearest(42.5, 29.9, 1, 20000)
+	}
+}
@@ -29,35 +29,81 @@ export async function checkHealth(): Promise<string> {
   return fetchApi<string>("/health");
 }
 
-// Get all stops
-export async function getStops(): Promise<Stop[]> {
-  const response = await fetchApi<{ stops: Array<{ stop_id: string; stop_name: string; stop_lat: number; stop_lon: number }> }>("/stops");
-  // Transform backend format to frontend format
-  return response.stops.map((stop) => ({
+// Backend stop format
+interface BackendStop {
+  stop_id: string;
+  stop_name: string;
+  stop_lat: number;
+  stop_lon: number;
+}
+
+// Transform backend format to frontend format
+function toStop(stop: BackendStop): Stop {
+  return {
     id: stop.stop_id,
     name: stop.stop_name,
     coordinates: {
       lat: stop.stop_lat,
       lon: stop.stop_lon,
     },
-  }));
+  };
+}
+
+// Get all stops
+export async function getStops(): Promise<Stop[]> {
+  const response = await fetchApi<{ stops: BackendStop[] }>("/stops");
+  return response.stops.map(toStop);
 }
 
 // Get nearest stop
 export async function getNearestStop(lat: number, lon: number): Promise<Stop> {
-  return fetchApi<Stop>(`/stops/nearest?lat=${lat}&lon=${lon}`);
+  const response = await fetchApi<{ stops: BackendStop[]; count: number }>(
+    `/stops/nearest?lat=${lat}&lon=${lon}`
+  );
+  if (response.stops.length === 0) {
+    throw new Error("No stop near this location");
+  }
+  return toStop(response.stops[0]);
+}
+
+// Backend journey format, only the fields the map draws
+interface BackendLeg {
+  mode: "walk" | "ride";
+  route_short_name?: string;
+  route_color?: string;
+  geometry: {
+    type: "LineString";
+    coordinates: Array<[number, number]>;
+  };
 }
 
-// Calculate route
+interface BackendRouteResponse {
+  journeys: Array<{ legs: BackendLeg[] }>;
+}
+
+// Calculate route, as one line feature per leg of the best journey
 export async function getRoute(
   fromLat: number,
   fromLon: number,
   toLat: number,
   toLon: number
 ): Promise<RouteResponse> {
-  return fetchApi<RouteResponse>(
+  const response = await fetchApi<BackendRouteResponse>(
     `/route?from=${fromLat},${fromLon}&to=${toLat},${toLon}`
   );
+  const legs = response.journeys[0]?.legs ?? [];
+  return {
+    type: "FeatureCollection",
+    features: legs.map((leg) => ({
+      type: "Feature",
+      geometry: leg.geometry,
+      properties: {
+        mode: leg.mode,
+        route_short_name: leg.route_short_name,
+        route_color: leg.route_color,
+      },
+    })),
+  };
 }
 
 // Get all bus routes